@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/yourorg/context-hydrator/internal/api"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/config"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"github.com/yourorg/context-hydrator/internal/registry"
+)
+
+// cmd/app-registry runs the app registry service only (/platform/apps).
+// This is an internal service used by app teams to onboard — Redis only.
+// For local development use cmd/server (combined).
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config load error: %v\n", err)
+		os.Exit(1)
+	}
+
+	log := observability.NewLogger(cfg.LogLevel, cfg.LogFormat)
+	slog.SetDefault(log)
+
+	shutdownTracing, err := observability.SetupTracing(context.Background(), cfg.TracingExporter, "app-registry", cfg.TracingSampleRatio)
+	if err != nil {
+		log.Error("tracing setup failed", "error", err)
+		os.Exit(1)
+	}
+
+	if cfg.RedisMode == config.RedisMemory {
+		log.Error("REDIS_MODE=memory is only supported by cmd/server")
+		os.Exit(1)
+	}
+	redisClient, err := redisc.NewClient(cfg.RedisOptions())
+	if err != nil {
+		log.Error("redis connect failed", "error", err)
+		os.Exit(1)
+	}
+	log.Info("redis connected", "mode", cfg.RedisMode, "addrs", cfg.RedisOptions().Addrs)
+
+	store := cache.NewStore(cache.NewRedisBackend(redisClient))
+	apps := registry.New(store, cfg.RegistryCacheTTL, cfg.DefaultAppConfig())
+
+	// Registry has no backend or cookie dependency — Redis only.
+	srv := api.NewServer(store, nil, nil, apps, log, api.WithRegistryToken(cfg.RegistryToken))
+
+	httpServer := &http.Server{
+		Addr:         ":" + cfg.RegistryPort,
+		Handler:      srv.RegistryHandler(),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		log.Info("app registry starting", "port", cfg.RegistryPort, "app_id", apps.DefaultAppID())
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error("server error", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-quit
+	log.Info("shutdown signal received")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Error("server shutdown error", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("tracing shutdown error", "error", err)
+	}
+	log.Info("app registry stopped")
+}
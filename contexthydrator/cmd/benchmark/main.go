@@ -41,11 +41,14 @@ func main() {
 
 	// ── Step 1: Warm-up hydration ────────────────────────────────────────────
 	fmt.Print("[ 1/3 ] Triggering hydration ... ")
-	if err := triggerHydration(client, *hydratorURL, *userID); err != nil {
+	jobID, err := triggerHydration(client, *hydratorURL, *userID)
+	if err != nil {
+		fatalf("hydration failed: %v\n", err)
+	}
+	// Wait for the background hydration to finish (backend latency + Redis write)
+	if err := waitForJob(client, *hydratorURL, jobID, 10*time.Second); err != nil {
 		fatalf("hydration failed: %v\n", err)
 	}
-	// Wait for fire-and-forget goroutine to finish (backend latency + Redis write)
-	time.Sleep(500 * time.Millisecond)
 	fmt.Println("done")
 
 	// Confirm cache is warm
@@ -70,7 +73,7 @@ func main() {
 
 // ── HTTP helpers ──────────────────────────────────────────────────────────────
 
-func triggerHydration(client *http.Client, baseURL, userID string) error {
+func triggerHydration(client *http.Client, baseURL, userID string) (string, error) {
 	payload := map[string]string{
 		"user_id":       userID,
 		"session_token": "bench-session",
@@ -81,14 +84,62 @@ func triggerHydration(client *http.Client, baseURL, userID string) error {
 	body := fmt.Sprintf(`{"cookie":"%s"}`, encoded)
 	resp, err := client.Post(baseURL+"/hydrate", "application/json", strings.NewReader(body))
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
-	io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusAccepted {
-		return fmt.Errorf("status %d", resp.StatusCode)
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var accepted struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&accepted); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	return accepted.JobID, nil
+}
+
+// waitForJob polls GET /hydrate/{jobID} until the hydration has completed
+// (or was skipped because an identical one just ran).
+func waitForJob(client *http.Client, baseURL, jobID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		resp, err := client.Get(baseURL + "/hydrate/" + jobID)
+		if err != nil {
+			return err
+		}
+		var status struct {
+			State     string `json:"state"`
+			Resources map[string]struct {
+				Status string `json:"status"`
+				Error  string `json:"error"`
+			} `json:"resources"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("job %s: status %d", jobID, resp.StatusCode)
+		}
+		if err != nil {
+			return fmt.Errorf("decode job status: %w", err)
+		}
+
+		switch status.State {
+		case "complete":
+			for name, r := range status.Resources {
+				if r.Status != "ok" {
+					fmt.Fprintf(os.Stderr, "\n        %s: %s", name, r.Error)
+				}
+			}
+			return nil
+		case "skipped":
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("job %s still %s after %s", jobID, status.State, timeout)
+		}
+		time.Sleep(20 * time.Millisecond)
 	}
-	return nil
 }
 
 func verifyCache(client *http.Client, baseURL, userID, resource string) error {
@@ -10,16 +10,22 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/yourorg/context-hydrator/internal/accesspattern"
 	"github.com/yourorg/context-hydrator/internal/api"
 	"github.com/yourorg/context-hydrator/internal/cache"
 	"github.com/yourorg/context-hydrator/internal/config"
-	"github.com/yourorg/context-hydrator/internal/cookie"
+	"github.com/yourorg/context-hydrator/internal/events"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
 	"github.com/yourorg/context-hydrator/internal/observability"
 	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"github.com/yourorg/context-hydrator/internal/registry"
+	"github.com/yourorg/context-hydrator/internal/services"
 )
 
 // cmd/context-reader runs the context reader service only (GET /data, GET /context).
-// This is the authenticated post-auth service — reads from Redis only.
+// This is the authenticated post-auth service — reads from Redis only, calling
+// backends only to refresh entries that are about to expire and, for resources
+// with read-through enabled, to fill cache misses.
 // For local development use cmd/server (combined).
 func main() {
 	cfg, err := config.Load()
@@ -31,21 +37,82 @@ func main() {
 	log := observability.NewLogger(cfg.LogLevel, cfg.LogFormat)
 	slog.SetDefault(log)
 
-	redisClient, err := redisc.NewClient(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+	shutdownTracing, err := observability.SetupTracing(context.Background(), cfg.TracingExporter, "context-reader", cfg.TracingSampleRatio)
+	if err != nil {
+		log.Error("tracing setup failed", "error", err)
+		os.Exit(1)
+	}
+
+	if cfg.RedisMode == config.RedisMemory {
+		log.Error("REDIS_MODE=memory is only supported by cmd/server")
+		os.Exit(1)
+	}
+	redisClient, err := redisc.NewClient(cfg.RedisOptions())
 	if err != nil {
 		log.Error("redis connect failed", "error", err)
 		os.Exit(1)
 	}
-	log.Info("redis connected", "addr", cfg.RedisAddr)
+	log.Info("redis connected", "mode", cfg.RedisMode, "addrs", cfg.RedisOptions().Addrs)
+
+	keys, err := cfg.KeyProvider()
+	if err != nil {
+		log.Error("encryption keys load failed", "error", err)
+		os.Exit(1)
+	}
+	// Optional in-process tier in front of Redis for resource reads.
+	store := cache.NewStore(cache.NewRedisBackend(redisClient),
+		cache.WithLocalCache(cfg.LocalCacheConfig()),
+		cache.WithEncryption(keys),
+		cache.WithCompression(cfg.CacheCompressionThreshold),
+		cache.WithLogger(log))
+	apps := registry.New(store, cfg.RegistryCacheTTL, cfg.DefaultAppConfig())
 
-	store := cache.NewStore(redisClient)
-	appConfig := cfg.DefaultAppConfig()
+	// Reads are served from Redis only. The backend is used solely to
+	// re-hydrate entries close to expiry (stale-while-revalidate) and for
+	// read-through misses.
+	httpClient := services.NewHTTPClient()
+	backend := services.NewBackend(services.BackendConfig{
+		ProfileURL:     cfg.ProfileServiceURL,
+		PreferencesURL: cfg.PreferencesServiceURL,
+		PermissionsURL: cfg.PermissionsServiceURL,
+		ResourcesURL:   cfg.ResourcesServiceURL,
+	}, httpClient)
+	backendTimeout := time.Duration(cfg.BackendTimeoutSecs) * time.Second
+	hyd := hydrator.New(store, backend, log, backendTimeout,
+		hydrator.WithPublisher(events.NewPublisher(redisClient)))
 
-	// Context reader has no backend dependency — Redis only.
 	// decoder is still needed if you add auth middleware later.
-	decoder := cookie.NewDecoder(cfg.CookieEncoding, cfg.CookieSecret)
+	decoder, err := cfg.CookieDecoder(httpClient)
+	if err != nil {
+		log.Error("cookie keys load failed", "error", err)
+		os.Exit(1)
+	}
+
+	// Fans hydration progress from any hydration-server instance out to
+	// GET /context/{contextKey}/events streams on this instance.
+	hub := events.NewHub(redisClient, log)
 
-	srv := api.NewServer(store, nil, decoder, appConfig, log)
+	verifier, err := cfg.SessionVerifier(httpClient)
+	if err != nil {
+		log.Error("session auth config invalid", "error", err)
+		os.Exit(1)
+	}
+	if verifier == nil {
+		log.Warn("session auth disabled, reader routes are unauthenticated")
+	}
+
+	opts := []api.Option{
+		api.WithInvalidationToken(cfg.InvalidationToken),
+		api.WithEventHub(hub, cfg.SSEMaxWait),
+		api.WithSessionVerifier(verifier),
+	}
+	var tracker *accesspattern.Tracker
+	if cfg.AccessPatternsEnabled {
+		tracker = accesspattern.NewTracker(redisClient, store, apps, log, cfg.AccessPatternConfig())
+		opts = append(opts, api.WithAccessTracker(tracker))
+	}
+
+	srv := api.NewServer(store, hyd, decoder, apps, log, opts...)
 
 	httpServer := &http.Server{
 		Addr:         ":" + cfg.ReaderPort,
@@ -54,11 +121,21 @@ func main() {
 		WriteTimeout: cfg.WriteTimeout,
 	}
 
+	// Background workers stop when bgCtx is cancelled at shutdown.
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	go hub.Run(bgCtx)
+	go store.RunEviction(bgCtx)
+	if tracker != nil {
+		go tracker.Run(bgCtx)
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		log.Info("context reader starting", "port", cfg.ReaderPort, "app_id", appConfig.AppID)
+		log.Info("context reader starting", "port", cfg.ReaderPort, "app_id", apps.DefaultAppID())
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Error("server error", "error", err)
 			os.Exit(1)
@@ -67,6 +144,7 @@ func main() {
 
 	<-quit
 	log.Info("shutdown signal received")
+	stopBackground()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
@@ -74,5 +152,13 @@ func main() {
 	if err := httpServer.Shutdown(ctx); err != nil {
 		log.Error("server shutdown error", "error", err)
 	}
+	if tracker != nil {
+		if err := tracker.Flush(ctx); err != nil {
+			log.Error("access pattern flush failed", "error", err)
+		}
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("tracing shutdown error", "error", err)
+	}
 	log.Info("context reader stopped")
 }
@@ -13,10 +13,14 @@ import (
 	"github.com/yourorg/context-hydrator/internal/api"
 	"github.com/yourorg/context-hydrator/internal/cache"
 	"github.com/yourorg/context-hydrator/internal/config"
-	"github.com/yourorg/context-hydrator/internal/cookie"
+	"github.com/yourorg/context-hydrator/internal/events"
 	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/invalidation"
+	"github.com/yourorg/context-hydrator/internal/jobs"
 	"github.com/yourorg/context-hydrator/internal/observability"
+	"github.com/yourorg/context-hydrator/internal/ratelimit"
 	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"github.com/yourorg/context-hydrator/internal/registry"
 	"github.com/yourorg/context-hydrator/internal/services"
 )
 
@@ -33,15 +37,31 @@ func main() {
 	log := observability.NewLogger(cfg.LogLevel, cfg.LogFormat)
 	slog.SetDefault(log)
 
-	redisClient, err := redisc.NewClient(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+	shutdownTracing, err := observability.SetupTracing(context.Background(), cfg.TracingExporter, "hydration-server", cfg.TracingSampleRatio)
+	if err != nil {
+		log.Error("tracing setup failed", "error", err)
+		os.Exit(1)
+	}
+
+	if cfg.RedisMode == config.RedisMemory {
+		log.Error("REDIS_MODE=memory is only supported by cmd/server")
+		os.Exit(1)
+	}
+	redisClient, err := redisc.NewClient(cfg.RedisOptions())
 	if err != nil {
 		log.Error("redis connect failed", "error", err)
 		os.Exit(1)
 	}
-	log.Info("redis connected", "addr", cfg.RedisAddr)
+	log.Info("redis connected", "mode", cfg.RedisMode, "addrs", cfg.RedisOptions().Addrs)
 
-	store := cache.NewStore(redisClient)
-	appConfig := cfg.DefaultAppConfig()
+	keys, err := cfg.KeyProvider()
+	if err != nil {
+		log.Error("encryption keys load failed", "error", err)
+		os.Exit(1)
+	}
+	store := cache.NewStore(cache.NewRedisBackend(redisClient), cache.WithEncryption(keys),
+		cache.WithCompression(cfg.CacheCompressionThreshold), cache.WithLogger(log))
+	apps := registry.New(store, cfg.RegistryCacheTTL, cfg.DefaultAppConfig())
 
 	httpClient := services.NewHTTPClient()
 	backend := services.NewBackend(services.BackendConfig{
@@ -52,10 +72,42 @@ func main() {
 	}, httpClient)
 
 	backendTimeout := time.Duration(cfg.BackendTimeoutSecs) * time.Second
-	hyd := hydrator.New(store, backend, log, backendTimeout)
-	decoder := cookie.NewDecoder(cfg.CookieEncoding, cfg.CookieSecret)
+	hyd := hydrator.New(store, backend, log, backendTimeout,
+		hydrator.WithPublisher(events.NewPublisher(redisClient)),
+		hydrator.WithSuppressWindow(cfg.HydrationSuppressWindow),
+		hydrator.WithJobStatusTTL(cfg.HydrationStatusTTL))
+	decoder, err := cfg.CookieDecoder(httpClient)
+	if err != nil {
+		log.Error("cookie keys load failed", "error", err)
+		os.Exit(1)
+	}
 
-	srv := api.NewServer(store, hyd, decoder, appConfig, log)
+	// Hydrations run on this process's pool, or are queued for
+	// cmd/hydration-worker with HYDRATION_DISPATCH=stream.
+	var pool *hydrator.Pool
+	var opts []api.Option
+	if cfg.HydrationDispatch == config.DispatchStream {
+		opts = append(opts, api.WithJobQueue(jobs.NewQueue(redisClient, store, cfg.HydrationJobStreamMaxLen)))
+	} else {
+		pool = hydrator.NewPool(hyd, cfg.HydrationWorkers, cfg.HydrationQueueSize)
+		opts = append(opts, api.WithHydrationPool(pool))
+	}
+	// POST /hydrate?wait=true returns the hydrated context, so it takes the
+	// same session token as the reader.
+	verifier, err := cfg.SessionVerifier(httpClient)
+	if err != nil {
+		log.Error("session auth config invalid", "error", err)
+		os.Exit(1)
+	}
+	if verifier == nil {
+		log.Warn("session auth disabled, POST /hydrate?wait=true is unauthenticated")
+	}
+	opts = append(opts, api.WithSessionVerifier(verifier))
+	if cfg.RateLimitEnabled {
+		opts = append(opts, api.WithRateLimiter(ratelimit.New(redisClient), cfg.RateLimitTrustForwarded))
+	}
+
+	srv := api.NewServer(store, hyd, decoder, apps, log, opts...)
 
 	httpServer := &http.Server{
 		Addr:         ":" + cfg.Port,
@@ -63,20 +115,47 @@ func main() {
 		ReadTimeout:  cfg.ReadTimeout,
 		WriteTimeout: cfg.WriteTimeout,
 	}
+	// Metrics name apps, resources and upstream statuses, so they are kept
+	// off the internet-facing listener.
+	metricsServer := &http.Server{
+		Addr:         ":" + cfg.MetricsPort,
+		Handler:      srv.InternalHandler(),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
+
+	// Background workers stop when bgCtx is cancelled at shutdown.
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	if cfg.InvalidationEnabled {
+		hostname, _ := os.Hostname()
+		consumer := invalidation.NewConsumer(redisClient, store, apps, log, hostname,
+			cfg.InvalidationApps, cfg.InvalidationDiscoveryInterval)
+		go consumer.Run(bgCtx)
+	}
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		log.Info("hydration server starting", "port", cfg.Port, "app_id", appConfig.AppID)
+		log.Info("hydration server starting", "port", cfg.Port, "app_id", apps.DefaultAppID())
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Error("server error", "error", err)
 			os.Exit(1)
 		}
 	}()
+	go func() {
+		log.Info("metrics listening", "port", cfg.MetricsPort)
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error("metrics server error", "error", err)
+			os.Exit(1)
+		}
+	}()
 
 	<-quit
 	log.Info("shutdown signal received")
+	stopBackground()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
@@ -84,5 +163,28 @@ func main() {
 	if err := httpServer.Shutdown(ctx); err != nil {
 		log.Error("server shutdown error", "error", err)
 	}
+	if err := metricsServer.Shutdown(ctx); err != nil {
+		log.Error("metrics server shutdown error", "error", err)
+	}
+
+	// No new hydrations are accepted once the listener is closed; let the
+	// queued and running ones finish.
+	if pool != nil {
+		queued, busy, _ := pool.Stats()
+		log.Info("draining hydrations", "queued", queued, "running", busy)
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.HydrationDrainTimeout)
+		defer cancelDrain()
+		if err := pool.Drain(drainCtx); err != nil {
+			queued, busy, _ = pool.Stats()
+			log.Error("hydration drain incomplete", "queued", queued, "running", busy, "error", err)
+		}
+	}
+
+	// The drain may have used up ctx; flush spans with a fresh deadline.
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Error("tracing shutdown error", "error", err)
+	}
 	log.Info("hydration server stopped")
 }
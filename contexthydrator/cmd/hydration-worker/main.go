@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/yourorg/context-hydrator/internal/api"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/config"
+	"github.com/yourorg/context-hydrator/internal/events"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/jobs"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"github.com/yourorg/context-hydrator/internal/registry"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+// cmd/hydration-worker consumes hydration jobs queued by cmd/hydration-server
+// running with HYDRATION_DISPATCH=stream, and fans out to the backends.
+// It scales independently of the internet-facing hydration tier; only
+// /health and /metrics are served.
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config load error: %v\n", err)
+		os.Exit(1)
+	}
+
+	log := observability.NewLogger(cfg.LogLevel, cfg.LogFormat)
+	slog.SetDefault(log)
+
+	shutdownTracing, err := observability.SetupTracing(context.Background(), cfg.TracingExporter, "hydration-worker", cfg.TracingSampleRatio)
+	if err != nil {
+		log.Error("tracing setup failed", "error", err)
+		os.Exit(1)
+	}
+
+	if cfg.RedisMode == config.RedisMemory {
+		log.Error("REDIS_MODE=memory is only supported by cmd/server")
+		os.Exit(1)
+	}
+	redisClient, err := redisc.NewClient(cfg.RedisOptions())
+	if err != nil {
+		log.Error("redis connect failed", "error", err)
+		os.Exit(1)
+	}
+	log.Info("redis connected", "mode", cfg.RedisMode, "addrs", cfg.RedisOptions().Addrs)
+
+	keys, err := cfg.KeyProvider()
+	if err != nil {
+		log.Error("encryption keys load failed", "error", err)
+		os.Exit(1)
+	}
+	store := cache.NewStore(cache.NewRedisBackend(redisClient), cache.WithEncryption(keys),
+		cache.WithCompression(cfg.CacheCompressionThreshold), cache.WithLogger(log))
+	apps := registry.New(store, cfg.RegistryCacheTTL, cfg.DefaultAppConfig())
+
+	httpClient := services.NewHTTPClient()
+	backend := services.NewBackend(services.BackendConfig{
+		ProfileURL:     cfg.ProfileServiceURL,
+		PreferencesURL: cfg.PreferencesServiceURL,
+		PermissionsURL: cfg.PermissionsServiceURL,
+		ResourcesURL:   cfg.ResourcesServiceURL,
+	}, httpClient)
+
+	backendTimeout := time.Duration(cfg.BackendTimeoutSecs) * time.Second
+	hyd := hydrator.New(store, backend, log, backendTimeout,
+		hydrator.WithPublisher(events.NewPublisher(redisClient)),
+		hydrator.WithSuppressWindow(cfg.HydrationSuppressWindow),
+		hydrator.WithJobStatusTTL(cfg.HydrationStatusTTL))
+
+	hostname, _ := os.Hostname()
+	worker := jobs.NewWorker(redisClient, store, apps, hyd, log, hostname, cfg.JobWorkerConfig())
+
+	srv := api.NewServer(store, hyd, nil, apps, log)
+	httpServer := &http.Server{
+		Addr:         ":" + cfg.WorkerPort,
+		Handler:      srv.InternalHandler(),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
+
+	// The worker stops taking jobs when bgCtx is cancelled at shutdown.
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	workerDone := make(chan struct{})
+	go func() {
+		worker.Run(bgCtx)
+		close(workerDone)
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		log.Info("hydration worker listening", "port", cfg.WorkerPort, "concurrency", worker.Concurrency())
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error("server error", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-quit
+	log.Info("shutdown signal received")
+	stopBackground()
+
+	// Running hydrations finish and are acknowledged; unstarted jobs stay on
+	// the stream for other workers.
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.HydrationDrainTimeout)
+	defer cancelDrain()
+	select {
+	case <-workerDone:
+	case <-drainCtx.Done():
+		log.Error("hydration worker did not stop before the drain timeout")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Error("server shutdown error", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("tracing shutdown error", "error", err)
+	}
+	log.Info("hydration worker stopped")
+}
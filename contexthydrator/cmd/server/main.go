@@ -10,13 +10,19 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/accesspattern"
 	"github.com/yourorg/context-hydrator/internal/api"
 	"github.com/yourorg/context-hydrator/internal/cache"
 	"github.com/yourorg/context-hydrator/internal/config"
-	"github.com/yourorg/context-hydrator/internal/cookie"
+	"github.com/yourorg/context-hydrator/internal/events"
 	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/invalidation"
+	"github.com/yourorg/context-hydrator/internal/jobs"
 	"github.com/yourorg/context-hydrator/internal/observability"
+	"github.com/yourorg/context-hydrator/internal/ratelimit"
 	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"github.com/yourorg/context-hydrator/internal/registry"
 	"github.com/yourorg/context-hydrator/internal/services"
 )
 
@@ -32,15 +38,45 @@ func main() {
 	log := observability.NewLogger(cfg.LogLevel, cfg.LogFormat)
 	slog.SetDefault(log)
 
-	redisClient, err := redisc.NewClient(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+	shutdownTracing, err := observability.SetupTracing(context.Background(), cfg.TracingExporter, "context-hydrator", cfg.TracingSampleRatio)
 	if err != nil {
-		log.Error("redis connect failed", "error", err)
+		log.Error("tracing setup failed", "error", err)
 		os.Exit(1)
 	}
-	log.Info("redis connected", "addr", cfg.RedisAddr)
 
-	store := cache.NewStore(redisClient)
-	appConfig := cfg.DefaultAppConfig()
+	// REDIS_MODE=memory keeps the cache in process, so make dev needs no
+	// Redis. Features built on Redis streams and Pub/Sub are then disabled.
+	var redisClient redis.UniversalClient
+	var storage cache.Backend
+	if cfg.RedisMode == config.RedisMemory {
+		if cfg.HydrationDispatch == config.DispatchStream {
+			log.Error("HYDRATION_DISPATCH=stream requires Redis")
+			os.Exit(1)
+		}
+		storage = cache.NewMemoryBackend()
+		log.Warn("using in-memory storage: hydration events, rate limiting, access patterns and invalidation streams are disabled")
+	} else {
+		redisClient, err = redisc.NewClient(cfg.RedisOptions())
+		if err != nil {
+			log.Error("redis connect failed", "error", err)
+			os.Exit(1)
+		}
+		log.Info("redis connected", "mode", cfg.RedisMode, "addrs", cfg.RedisOptions().Addrs)
+		storage = cache.NewRedisBackend(redisClient)
+	}
+
+	keys, err := cfg.KeyProvider()
+	if err != nil {
+		log.Error("encryption keys load failed", "error", err)
+		os.Exit(1)
+	}
+	// Optional in-process tier in front of Redis for resource reads.
+	store := cache.NewStore(storage,
+		cache.WithLocalCache(cfg.LocalCacheConfig()),
+		cache.WithEncryption(keys),
+		cache.WithCompression(cfg.CacheCompressionThreshold),
+		cache.WithLogger(log))
+	apps := registry.New(store, cfg.RegistryCacheTTL, cfg.DefaultAppConfig())
 
 	httpClient := services.NewHTTPClient()
 	backend := services.NewBackend(services.BackendConfig{
@@ -51,11 +87,60 @@ func main() {
 	}, httpClient)
 
 	backendTimeout := time.Duration(cfg.BackendTimeoutSecs) * time.Second
-	hyd := hydrator.New(store, backend, log, backendTimeout)
+	var publisher *events.Publisher
+	var hub *events.Hub
+	if redisClient != nil {
+		publisher = events.NewPublisher(redisClient)
+		hub = events.NewHub(redisClient, log)
+	}
+	hyd := hydrator.New(store, backend, log, backendTimeout,
+		hydrator.WithPublisher(publisher),
+		hydrator.WithSuppressWindow(cfg.HydrationSuppressWindow),
+		hydrator.WithJobStatusTTL(cfg.HydrationStatusTTL))
+
+	decoder, err := cfg.CookieDecoder(httpClient)
+	if err != nil {
+		log.Error("cookie keys load failed", "error", err)
+		os.Exit(1)
+	}
+
+	verifier, err := cfg.SessionVerifier(httpClient)
+	if err != nil {
+		log.Error("session auth config invalid", "error", err)
+		os.Exit(1)
+	}
+	if verifier == nil {
+		log.Warn("session auth disabled, reader routes are unauthenticated")
+	}
+
+	opts := []api.Option{
+		api.WithInvalidationToken(cfg.InvalidationToken),
+		api.WithEventHub(hub, cfg.SSEMaxWait),
+		api.WithSessionVerifier(verifier),
+	}
 
-	decoder := cookie.NewDecoder(cfg.CookieEncoding, cfg.CookieSecret)
+	// With HYDRATION_DISPATCH=stream, hydrations go through the job stream
+	// and an in-process worker, as they would with cmd/hydration-worker.
+	var pool *hydrator.Pool
+	var worker *jobs.Worker
+	if cfg.HydrationDispatch == config.DispatchStream {
+		opts = append(opts, api.WithJobQueue(jobs.NewQueue(redisClient, store, cfg.HydrationJobStreamMaxLen)))
+		hostname, _ := os.Hostname()
+		worker = jobs.NewWorker(redisClient, store, apps, hyd, log, hostname, cfg.JobWorkerConfig())
+	} else {
+		pool = hydrator.NewPool(hyd, cfg.HydrationWorkers, cfg.HydrationQueueSize)
+		opts = append(opts, api.WithHydrationPool(pool))
+	}
+	if cfg.RateLimitEnabled && redisClient != nil {
+		opts = append(opts, api.WithRateLimiter(ratelimit.New(redisClient), cfg.RateLimitTrustForwarded))
+	}
+	var tracker *accesspattern.Tracker
+	if cfg.AccessPatternsEnabled && redisClient != nil {
+		tracker = accesspattern.NewTracker(redisClient, store, apps, log, cfg.AccessPatternConfig())
+		opts = append(opts, api.WithAccessTracker(tracker))
+	}
 
-	srv := api.NewServer(store, hyd, decoder, appConfig, log)
+	srv := api.NewServer(store, hyd, decoder, apps, log, opts...)
 
 	httpServer := &http.Server{
 		Addr:         ":" + cfg.Port,
@@ -64,11 +149,40 @@ func main() {
 		WriteTimeout: cfg.WriteTimeout,
 	}
 
+	// Background workers stop when bgCtx is cancelled at shutdown.
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	if hub != nil {
+		go hub.Run(bgCtx)
+	}
+	go store.RunEviction(bgCtx)
+	if tracker != nil {
+		go tracker.Run(bgCtx)
+	}
+
+	workerDone := make(chan struct{})
+	if worker != nil {
+		go func() {
+			worker.Run(bgCtx)
+			close(workerDone)
+		}()
+	} else {
+		close(workerDone)
+	}
+
+	if cfg.InvalidationEnabled && redisClient != nil {
+		hostname, _ := os.Hostname()
+		consumer := invalidation.NewConsumer(redisClient, store, apps, log, hostname,
+			cfg.InvalidationApps, cfg.InvalidationDiscoveryInterval)
+		go consumer.Run(bgCtx)
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		log.Info("server starting", "port", cfg.Port, "mode", "combined", "app_id", appConfig.AppID)
+		log.Info("server starting", "port", cfg.Port, "mode", "combined", "app_id", apps.DefaultAppID())
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Error("server error", "error", err)
 			os.Exit(1)
@@ -77,6 +191,7 @@ func main() {
 
 	<-quit
 	log.Info("shutdown signal received")
+	stopBackground()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
@@ -84,5 +199,36 @@ func main() {
 	if err := httpServer.Shutdown(ctx); err != nil {
 		log.Error("server shutdown error", "error", err)
 	}
+	if tracker != nil {
+		if err := tracker.Flush(ctx); err != nil {
+			log.Error("access pattern flush failed", "error", err)
+		}
+	}
+
+	// No new hydrations are accepted once the listener is closed; let the
+	// queued and running ones finish. Jobs still on the stream are kept for
+	// the next start.
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.HydrationDrainTimeout)
+	defer cancelDrain()
+	if pool != nil {
+		queued, busy, _ := pool.Stats()
+		log.Info("draining hydrations", "queued", queued, "running", busy)
+		if err := pool.Drain(drainCtx); err != nil {
+			queued, busy, _ = pool.Stats()
+			log.Error("hydration drain incomplete", "queued", queued, "running", busy, "error", err)
+		}
+	}
+	select {
+	case <-workerDone:
+	case <-drainCtx.Done():
+		log.Error("hydration worker did not stop before the drain timeout")
+	}
+
+	// The drain may have used up ctx; flush spans with a fresh deadline.
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Error("tracing shutdown error", "error", err)
+	}
 	log.Info("server stopped")
 }
@@ -0,0 +1,273 @@
+// Package accesspattern learns which resources each contextKey actually
+// reads, so hydrations can skip the ones it never uses.
+//
+// Reader requests are counted in memory and flushed periodically into one
+// hash per contextKey:
+//
+//	hyd:access:{appID}:{contextKey}   _reads → reads, {resource} → reads including it
+//
+// Counts decay exponentially with a configurable half-life, so old habits
+// fade. Flushed contextKeys are added to hyd:access-dirty; a learning pass
+// pops them, computes each resource's share of reads and writes
+// {appID}:access_pattern:{contextKey}, which hydrator.ResolveResources reads.
+package accesspattern
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"github.com/yourorg/context-hydrator/internal/registry"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+const (
+	fieldReads     = "_reads"
+	fieldDecayedAt = "_decayed_at"
+
+	// maxPending bounds the contextKeys buffered between flushes; reads of
+	// further keys are dropped until the next flush.
+	maxPending = 100000
+	learnBatch = 500
+)
+
+// Config tunes a Tracker.
+type Config struct {
+	// FlushInterval is how often buffered reads are written to Redis.
+	FlushInterval time.Duration
+	// LearnInterval is how often access patterns are recomputed for the
+	// contextKeys read since the last pass.
+	LearnInterval time.Duration
+	// HalfLife is the time after which a read counts half as much.
+	HalfLife time.Duration
+	// TTL is how long counters and patterns of a contextKey that is no longer
+	// read are kept.
+	TTL time.Duration
+}
+
+type entry struct {
+	appID      string
+	contextKey string
+}
+
+// Tracker records reads and turns them into access patterns. Record is safe
+// for concurrent use and never touches Redis.
+type Tracker struct {
+	client redis.UniversalClient
+	store  *cache.Store
+	apps   *registry.Registry
+	log    *slog.Logger
+	cfg    Config
+	now    func() time.Time
+
+	mu      sync.Mutex
+	pending map[entry]map[string]float64
+}
+
+func NewTracker(client redis.UniversalClient, store *cache.Store, apps *registry.Registry, log *slog.Logger, cfg Config) *Tracker {
+	return &Tracker{
+		client:  client,
+		store:   store,
+		apps:    apps,
+		log:     log,
+		cfg:     cfg,
+		now:     time.Now,
+		pending: make(map[entry]map[string]float64),
+	}
+}
+
+// Record counts one read of resources for contextKey. Misses count too: a
+// resource left out of the pattern and then read is how it gets back in.
+func (t *Tracker) Record(appID, contextKey string, resources []services.ServiceName) {
+	if len(resources) == 0 {
+		return
+	}
+	e := entry{appID: appID, contextKey: contextKey}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts, ok := t.pending[e]
+	if !ok {
+		if len(t.pending) >= maxPending {
+			return
+		}
+		counts = make(map[string]float64, len(resources)+1)
+		t.pending[e] = counts
+	}
+	counts[fieldReads]++
+	for _, r := range resources {
+		counts[string(r)]++
+	}
+}
+
+// Run flushes and learns on their intervals until ctx is cancelled. Call
+// Flush after the HTTP server has stopped to keep the last reads.
+func (t *Tracker) Run(ctx context.Context) {
+	flush := time.NewTicker(t.cfg.FlushInterval)
+	defer flush.Stop()
+	learn := time.NewTicker(t.cfg.LearnInterval)
+	defer learn.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-flush.C:
+			if err := t.Flush(ctx); err != nil {
+				t.log.WarnContext(ctx, "access pattern flush failed", "error", err)
+			}
+		case <-learn.C:
+			if err := t.Learn(ctx); err != nil {
+				t.log.WarnContext(ctx, "access pattern learning failed", "error", err)
+			}
+		}
+	}
+}
+
+// Flush writes the buffered reads to Redis. Reads are dropped if the write
+// fails; losing a few only delays learning.
+func (t *Tracker) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[entry]map[string]float64)
+	t.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	_, err := t.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for e, counts := range pending {
+			key := cache.AccessStatsKey(e.appID, e.contextKey)
+			for field, n := range counts {
+				pipe.HIncrByFloat(ctx, key, field, n)
+			}
+			pipe.PExpire(ctx, key, t.cfg.TTL)
+			pipe.SAdd(ctx, redisc.KeyAccessDirty, e.appID+":"+e.contextKey)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("flush %d context keys: %w", len(pending), err)
+	}
+	return nil
+}
+
+// Learn recomputes the access pattern of every contextKey read since the
+// last pass. Instances share the dirty set, so each key is handled once.
+func (t *Tracker) Learn(ctx context.Context) error {
+	for {
+		members, err := t.client.SPopN(ctx, redisc.KeyAccessDirty, learnBatch).Result()
+		if err != nil {
+			return fmt.Errorf("pop dirty context keys: %w", err)
+		}
+		for _, m := range members {
+			// App IDs cannot contain ":", so the first one ends it.
+			appID, contextKey, ok := strings.Cut(m, ":")
+			if !ok {
+				continue
+			}
+			if err := t.learnOne(ctx, appID, contextKey); err != nil {
+				t.log.WarnContext(ctx, "access pattern update failed",
+					"app_id", appID, "context_key", contextKey, "error", err)
+			}
+		}
+		if len(members) < learnBatch {
+			return nil
+		}
+	}
+}
+
+func (t *Tracker) learnOne(ctx context.Context, appID, contextKey string) error {
+	appConfig, err := t.apps.AppConfig(ctx, appID)
+	if errors.Is(err, registry.ErrAppNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	counts, err := t.decayedCounts(ctx, cache.AccessStatsKey(appID, contextKey))
+	if err != nil {
+		return err
+	}
+	resources := Select(appConfig, counts)
+	if len(resources) == 0 {
+		return nil
+	}
+	names := make([]string, len(resources))
+	for i, r := range resources {
+		names[i] = string(r)
+	}
+	return t.store.SetAccessPattern(ctx, appID, contextKey, names, t.cfg.TTL)
+}
+
+// decayScript scales a contextKey's counters by 0.5^(elapsed/half-life)
+// since they were last decayed and returns them, atomically so concurrent
+// flushes are not lost.
+var decayScript = redis.NewScript(`
+local fields = redis.call("HGETALL", KEYS[1])
+if #fields == 0 then return {} end
+local now = tonumber(ARGV[1])
+local last = now
+for i = 1, #fields, 2 do
+	if fields[i] == ARGV[3] then last = tonumber(fields[i + 1]) end
+end
+local factor = 0.5 ^ ((now - last) / tonumber(ARGV[2]))
+local out = {}
+for i = 1, #fields, 2 do
+	if fields[i] ~= ARGV[3] then
+		local v = tonumber(fields[i + 1]) * factor
+		if factor < 1 then redis.call("HSET", KEYS[1], fields[i], tostring(v)) end
+		table.insert(out, fields[i])
+		table.insert(out, tostring(v))
+	end
+end
+redis.call("HSET", KEYS[1], ARGV[3], ARGV[1])
+return out
+`)
+
+func (t *Tracker) decayedCounts(ctx context.Context, key string) (map[string]float64, error) {
+	res, err := decayScript.Run(ctx, t.client, []string{key},
+		t.now().UnixMilli(), t.cfg.HalfLife.Milliseconds(), fieldDecayedAt).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("decay counters: %w", err)
+	}
+	counts := make(map[string]float64, len(res)/2)
+	for i := 0; i+1 < len(res); i += 2 {
+		v, err := strconv.ParseFloat(res[i+1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("counter %s: %w", res[i], err)
+		}
+		counts[res[i]] = v
+	}
+	return counts, nil
+}
+
+// Select returns the resources to hydrate given a contextKey's read counts:
+// those read in at least MinConfidence of its reads, plus AlwaysInclude. It
+// returns nil until there are MinReads reads, meaning "fetch everything".
+func Select(appConfig *services.AppConfig, counts map[string]float64) []services.ServiceName {
+	cfg := appConfig.AccessPatterns
+	reads := counts[fieldReads]
+	if reads <= 0 || reads < cfg.MinReads {
+		return nil
+	}
+
+	var selected []services.ServiceName
+	for _, name := range appConfig.ResourceNames() {
+		n := counts[string(name)]
+		if (n > 0 && n/reads >= cfg.MinConfidence) || slices.Contains(cfg.AlwaysInclude, name) {
+			selected = append(selected, name)
+		}
+	}
+	return selected
+}
@@ -0,0 +1,133 @@
+package accesspattern
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	"github.com/yourorg/context-hydrator/internal/registry"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+func newTestTracker(t *testing.T) (*Tracker, *cache.Store) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := cache.NewStore(cache.NewRedisBackend(client))
+	apps := registry.New(store, time.Minute, &services.AppConfig{
+		AppID: "app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			"profile":     {TTL: time.Hour},
+			"preferences": {TTL: time.Hour},
+			"billing":     {TTL: time.Hour},
+			"permissions": {TTL: time.Hour},
+		},
+		AccessPatterns: services.AccessPatternConfig{
+			MinReads:      10,
+			MinConfidence: 0.2,
+			AlwaysInclude: []services.ServiceName{"permissions"},
+		},
+	})
+	tr := NewTracker(client, store, apps, observability.NewLogger("error", "text"), Config{
+		FlushInterval: time.Second,
+		LearnInterval: time.Second,
+		HalfLife:      time.Hour,
+		TTL:           24 * time.Hour,
+	})
+	return tr, store
+}
+
+func TestTracker_LearnsPattern(t *testing.T) {
+	tr, store := newTestTracker(t)
+	ctx := context.Background()
+
+	// 10 reads: profile every time, preferences 3 times, billing once.
+	for i := range 10 {
+		read := []services.ServiceName{"profile"}
+		if i < 3 {
+			read = append(read, "preferences")
+		}
+		if i == 0 {
+			read = append(read, "billing")
+		}
+		tr.Record("app", "u1", read)
+	}
+	if err := tr.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Learn(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.GetAccessPattern(ctx, "app", "u1")
+	if err != nil {
+		t.Fatalf("pattern not written: %v", err)
+	}
+	want := []string{"permissions", "preferences", "profile"}
+	if !slices.Equal(got, want) {
+		t.Errorf("pattern: got %v, want %v", got, want)
+	}
+}
+
+func TestTracker_WaitsForMinReads(t *testing.T) {
+	tr, store := newTestTracker(t)
+	ctx := context.Background()
+	now := time.Now()
+	tr.now = func() time.Time { return now }
+
+	for range 9 {
+		tr.Record("app", "u1", []services.ServiceName{"profile"})
+	}
+	tr.Flush(ctx)
+	tr.Learn(ctx)
+
+	if _, err := store.GetAccessPattern(ctx, "app", "u1"); err != cache.ErrCacheMiss {
+		t.Fatalf("expected no pattern below min reads, got %v", err)
+	}
+
+	// Counters accumulate across flushes.
+	tr.Record("app", "u1", []services.ServiceName{"profile"})
+	tr.Flush(ctx)
+	tr.Learn(ctx)
+	if _, err := store.GetAccessPattern(ctx, "app", "u1"); err != nil {
+		t.Fatalf("expected pattern after 10 reads: %v", err)
+	}
+}
+
+func TestTracker_Decay(t *testing.T) {
+	tr, _ := newTestTracker(t)
+	ctx := context.Background()
+	now := time.Now()
+	tr.now = func() time.Time { return now }
+
+	for range 8 {
+		tr.Record("app", "u1", []services.ServiceName{"billing"})
+	}
+	tr.Flush(ctx)
+	key := cache.AccessStatsKey("app", "u1")
+	if _, err := tr.decayedCounts(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	counts, err := tr.decayedCounts(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := counts["billing"]; got < 1.99 || got > 2.01 {
+		t.Errorf("billing after two half-lives: got %v, want 2", got)
+	}
+
+	// New reads add to the decayed count.
+	tr.Record("app", "u1", []services.ServiceName{"billing"})
+	tr.Flush(ctx)
+	counts, _ = tr.decayedCounts(ctx, key)
+	if got := counts["billing"]; got < 2.99 || got > 3.01 {
+		t.Errorf("billing after another read: got %v, want 3", got)
+	}
+}
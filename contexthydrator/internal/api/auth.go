@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yourorg/context-hydrator/internal/auth"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+// sessionAuth requires a valid session token on reader routes and checks that
+// the session may read the requested {contextKey}. The verified session is
+// stored in the request context; its app_id claim selects the app.
+//
+// Without a verifier (local development) requests pass through unchecked.
+func (s *Server) sessionAuth(next http.Handler) http.Handler {
+	if s.verifier == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, ok := s.authenticate(w, r)
+		if !ok {
+			return
+		}
+		appConfig, ok := s.resolveAppConfig(w, r, s.requestAppID(r))
+		if !ok {
+			return
+		}
+		if !s.authorize(w, r, appConfig, chi.URLParam(r, "contextKey")) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate verifies the request's session token and returns the request
+// with the session in its context. It writes 401 for a missing or invalid
+// token, 403 when an X-App-ID header names an app other than the session's,
+// and 503 when the token cannot be checked, returning false in each case.
+// Without a verifier the request is returned unchanged.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if s.verifier == nil {
+		return r, true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	session, err := s.verifier.Verify(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			s.log.WarnContext(r.Context(), "session token rejected", "error", err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		} else {
+			s.log.ErrorContext(r.Context(), "session verification failed", "error", err)
+			http.Error(w, `{"error":"service unavailable"}`, http.StatusServiceUnavailable)
+		}
+		return nil, false
+	}
+	if id := r.Header.Get(appIDHeader); id != "" && id != session.AppID {
+		s.log.WarnContext(r.Context(), "session app mismatch",
+			"app_id", id, "session_app_id", session.AppID)
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		return nil, false
+	}
+	return r.WithContext(auth.WithSession(r.Context(), session)), true
+}
+
+// authorize checks that the authenticated session may read contextKey of
+// appConfig's app, writing 403 if not. Without a verifier every read is
+// allowed.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, appConfig *services.AppConfig, contextKey string) bool {
+	if s.verifier == nil || appConfig == nil {
+		return true
+	}
+	session, ok := auth.FromContext(r.Context())
+	if !ok || !session.Authorizes(appConfig.AppID, appConfig.ContextKeyClaims, contextKey) {
+		s.log.WarnContext(r.Context(), "session not authorized for context key",
+			"app_id", appConfig.AppID, "context_key", contextKey)
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		return false
+	}
+	return true
+}
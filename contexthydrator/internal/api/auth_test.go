@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/auth"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	"github.com/yourorg/context-hydrator/internal/registry"
+)
+
+func TestReaderHandler_SessionAuth(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	app := testAppConfig()
+	app.ContextKeyClaims = []string{"user_id", "profile_id"}
+
+	secret := []byte("session-secret")
+	verifier, err := auth.NewJWTVerifier(auth.JWTConfig{Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(store, nil, nil, registry.New(store, time.Minute, app), log, WithSessionVerifier(verifier))
+
+	store.Set(context.Background(), cache.ResourceCacheKey(app.AppID, "profile", "u1:pA"), json.RawMessage(`{}`), time.Hour)
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"app_id":  app.AppID,
+		"user_id": "u1",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString(secret)
+	noApp, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": "u1",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString(secret)
+
+	cases := []struct {
+		name   string
+		path   string
+		token  string
+		appID  string
+		status int
+	}{
+		{"no token", "/data/u1:pA/profile", "", "", http.StatusUnauthorized},
+		{"bad token", "/data/u1:pA/profile", "not-a-jwt", "", http.StatusUnauthorized},
+		{"token without app_id", "/data/u1:pA/profile", noApp, app.AppID, http.StatusUnauthorized},
+		{"other user", "/data/u2:pA/profile", token, "", http.StatusForbidden},
+		{"other user context", "/context/u2", token, "", http.StatusForbidden},
+		{"header names other app", "/data/u1:pA/profile", token, "other-app", http.StatusForbidden},
+		{"own context key", "/data/u1:pA/profile", token, "", http.StatusOK},
+		{"header names own app", "/data/u1:pA/profile", token, app.AppID, http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			if tc.appID != "" {
+				req.Header.Set(appIDHeader, tc.appID)
+			}
+			w := httptest.NewRecorder()
+			srv.ReaderHandler().ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Errorf("status: got %d, want %d (%s)", w.Code, tc.status, w.Body.String())
+			}
+		})
+	}
+}
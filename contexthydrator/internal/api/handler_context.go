@@ -5,15 +5,27 @@ import (
 	"errors"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/metrics"
 	"github.com/yourorg/context-hydrator/internal/services"
 )
 
 type resourceMeta struct {
-	Source string `json:"source"`          // "cache" | "unavailable"
+	Source string `json:"source"`          // "cache" | "cache-stale" | "origin" | "pending" | "unavailable"
 	Error  string `json:"error,omitempty"` // set when source == "unavailable"
+	// TTLMillis is how long the entry stays cached. Omitted for entries
+	// without an expiry and resources not served.
+	TTLMillis int64 `json:"ttl_ms,omitempty"`
+}
+
+// cachedMeta returns the meta of a resource served with remaining TTL ttl.
+func cachedMeta(source string, ttl time.Duration) resourceMeta {
+	return resourceMeta{Source: source, TTLMillis: max(ttl.Milliseconds(), 0)}
 }
 
 type contextResponse struct {
@@ -26,7 +38,10 @@ type contextResponse struct {
 //
 // For each requested resource:
 //  1. Try Redis cache → source: "cache"
-//  2. On miss or error: include in meta with source: "unavailable"
+//     (or "cache-stale" when near expiry; re-hydrated in the background)
+//  2. On miss, if the resource has read-through enabled: fetch it from the
+//     backend and cache it → source: "origin"
+//  3. Otherwise, or on error: include in meta with source: "unavailable"
 //
 // Always returns 200 with whatever data is available.
 // Callers should inspect meta.source to know the freshness of each field.
@@ -35,45 +50,142 @@ func (s *Server) handleContext() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		contextKey := chi.URLParam(r, "contextKey")
 
-		requested := parseResourcesParam(r)
-		if len(requested) == 0 {
-			http.Error(w, `{"error":"no valid resources requested","allowed":["profile","preferences","permissions","resources"]}`,
-				http.StatusBadRequest)
+		appConfig, ok := s.resolveAppConfig(w, r, s.requestAppID(r))
+		if !ok {
 			return
 		}
 
-		resp := contextResponse{
-			ContextKey: contextKey,
-			Data:       make(map[string]json.RawMessage, len(requested)),
-			Meta:       make(map[string]resourceMeta, len(requested)),
+		requested := parseResourcesParam(r, appConfig)
+		if len(requested) == 0 {
+			writeResourceError(w, "no valid resources requested", appConfig)
+			return
 		}
+		s.recordAccess(appConfig, contextKey, requested...)
+
+		resp := s.readContext(r, appConfig, contextKey, requested)
 
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// cacheMissError is the meta error of resources not in the cache.
+const cacheMissError = "cache miss — trigger POST /hydrate"
+
+// readContext reads the requested resources of contextKey from the cache in
+// one round trip, reporting each entry's remaining TTL. Entries near expiry
+// are served and revalidated in the background. Misses of resources that
+// enable read-through are fetched concurrently from the backend.
+func (s *Server) readContext(r *http.Request, appConfig *services.AppConfig, contextKey string, requested []services.ServiceName) contextResponse {
+	resp := contextResponse{
+		ContextKey: contextKey,
+		Data:       make(map[string]json.RawMessage, len(requested)),
+		Meta:       make(map[string]resourceMeta, len(requested)),
+	}
+
+	names := make([]string, len(requested))
+	for i, svc := range requested {
+		names[i] = string(svc)
+	}
+	reads, err := s.store.GetResources(r.Context(), appConfig.AppID, contextKey, names)
+	if err != nil {
+		s.log.WarnContext(r.Context(), "cache read error",
+			"context_key", contextKey, "resources", names, "error", err)
 		for _, svc := range requested {
-			key, _ := cache.KeyForResource(s.appID(), contextKey, string(svc))
-			data, err := s.store.Get(r.Context(), key)
-			if err == nil {
-				resp.Data[string(svc)] = data
-				resp.Meta[string(svc)] = resourceMeta{Source: "cache"}
-				continue
+			resp.Meta[string(svc)] = resourceMeta{Source: "unavailable", Error: "cache error"}
+		}
+		return resp
+	}
+
+	var misses []services.ServiceName
+	for i, svc := range requested {
+		data, ttl, err := reads[i].Data, reads[i].TTL, reads[i].Err
+		if err == nil {
+			metrics.CacheHits.WithLabelValues(appConfig.AppID, string(svc)).Inc()
+			resp.Data[string(svc)] = data
+			if s.revalidateIfStale(r.Context(), appConfig, contextKey, svc, ttl) {
+				resp.Meta[string(svc)] = cachedMeta("cache-stale", ttl)
+			} else {
+				resp.Meta[string(svc)] = cachedMeta("cache", ttl)
 			}
-			if errors.Is(err, cache.ErrCacheMiss) {
-				resp.Meta[string(svc)] = resourceMeta{Source: "unavailable", Error: "cache miss — trigger POST /hydrate"}
-				continue
+			continue
+		}
+		if errors.Is(err, cache.ErrCacheMiss) {
+			metrics.CacheMisses.WithLabelValues(appConfig.AppID, string(svc)).Inc()
+			resp.Meta[string(svc)] = resourceMeta{Source: "unavailable", Error: cacheMissError}
+			if s.readThroughEnabled(appConfig, svc) {
+				misses = append(misses, svc)
 			}
-			s.log.WarnContext(r.Context(), "cache read error",
-				"context_key", contextKey, "resource", svc, "error", err)
-			resp.Meta[string(svc)] = resourceMeta{Source: "unavailable", Error: "cache error"}
+			continue
 		}
+		s.log.WarnContext(r.Context(), "cache read error",
+			"context_key", contextKey, "resource", svc, "error", err)
+		resp.Meta[string(svc)] = resourceMeta{Source: "unavailable", Error: "cache error"}
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(resp)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, svc := range misses {
+		wg.Go(func() {
+			data, err := s.readThrough(r, appConfig, contextKey, svc)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				resp.Data[string(svc)] = data
+				resp.Meta[string(svc)] = cachedMeta("origin", appConfig.Resources[svc].TTL)
+			case !errors.Is(err, hydrator.ErrNoClaims):
+				resp.Meta[string(svc)] = resourceMeta{Source: "unavailable", Error: "upstream unavailable"}
+			}
+		})
+	}
+	wg.Wait()
+	return resp
+}
+
+// cachedContext reads the requested resources of contextKey from the cache
+// as readContext does, but for a caller other than the reader: it records no
+// cache hit or miss metrics, serves near-expiry entries as "cache" without
+// revalidating them and never reads through to the backend.
+func (s *Server) cachedContext(r *http.Request, appConfig *services.AppConfig, contextKey string, requested []services.ServiceName) contextResponse {
+	resp := contextResponse{
+		ContextKey: contextKey,
+		Data:       make(map[string]json.RawMessage, len(requested)),
+		Meta:       make(map[string]resourceMeta, len(requested)),
+	}
+
+	names := make([]string, len(requested))
+	for i, svc := range requested {
+		names[i] = string(svc)
+	}
+	reads, err := s.store.GetResources(r.Context(), appConfig.AppID, contextKey, names)
+	for i, svc := range requested {
+		switch {
+		case err == nil && reads[i].Err == nil:
+			resp.Data[string(svc)] = reads[i].Data
+			resp.Meta[string(svc)] = cachedMeta("cache", reads[i].TTL)
+		case err == nil && errors.Is(reads[i].Err, cache.ErrCacheMiss):
+			resp.Meta[string(svc)] = resourceMeta{Source: "unavailable", Error: cacheMissError}
+		default:
+			resp.Meta[string(svc)] = resourceMeta{Source: "unavailable", Error: "cache error"}
+		}
+	}
+	if err != nil {
+		s.log.WarnContext(r.Context(), "cache read error",
+			"context_key", contextKey, "resources", names, "error", err)
 	}
+	return resp
 }
 
 // parseResourcesParam reads ?resources=profile,preferences or ?resources=profile&resources=permissions.
-// Defaults to all four resources when the param is absent.
-func parseResourcesParam(r *http.Request) []services.ServiceName {
+// Names not configured for the app are dropped. Defaults to all of the app's
+// resources when the param is absent.
+func parseResourcesParam(r *http.Request, appConfig *services.AppConfig) []services.ServiceName {
+	if appConfig == nil {
+		return nil
+	}
+
 	raw := r.URL.Query()["resources"]
 
 	var tokens []string
@@ -86,20 +198,16 @@ func parseResourcesParam(r *http.Request) []services.ServiceName {
 	}
 
 	if len(tokens) == 0 {
-		return services.AllServices
+		return appConfig.ResourceNames()
 	}
 
 	seen := make(map[services.ServiceName]bool)
 	var result []services.ServiceName
 	for _, t := range tokens {
 		svc := services.ServiceName(t)
-		switch svc {
-		case services.ServiceProfile, services.ServicePreferences,
-			services.ServicePermissions, services.ServiceResources:
-			if !seen[svc] {
-				seen[svc] = true
-				result = append(result, svc)
-			}
+		if appConfig.HasResource(t) && !seen[svc] {
+			seen[svc] = true
+			result = append(result, svc)
 		}
 	}
 	return result
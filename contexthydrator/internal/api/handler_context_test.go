@@ -1,25 +1,67 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/auth"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
 	"github.com/yourorg/context-hydrator/internal/observability"
+	"github.com/yourorg/context-hydrator/internal/registry"
 	"github.com/yourorg/context-hydrator/internal/services"
 )
 
+// testAppConfig returns an app with the four standard resources plus a
+// custom "limits" resource.
+func testAppConfig() *services.AppConfig {
+	return &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile:     {URLTemplate: "http://svc/users/{user_id}/profile", TTL: time.Hour},
+			services.ServicePreferences: {URLTemplate: "http://svc/users/{user_id}/preferences", TTL: time.Hour},
+			services.ServicePermissions: {URLTemplate: "http://svc/users/{user_id}/permissions", TTL: time.Hour},
+			services.ServiceResources:   {URLTemplate: "http://svc/users/{user_id}/resources", TTL: time.Hour},
+			"limits":                    {URLTemplate: "http://svc/accounts/{user_id}/limits", TTL: time.Minute},
+		},
+	}
+}
+
 func TestParseResourcesParam_Default(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/context/u1", nil)
-	got := parseResourcesParam(req)
-	if len(got) != len(services.AllServices) {
-		t.Errorf("expected %d resources, got %d", len(services.AllServices), len(got))
+	app := testAppConfig()
+	got := parseResourcesParam(req, app)
+	if len(got) != len(app.Resources) {
+		t.Errorf("expected %d resources, got %d", len(app.Resources), len(got))
+	}
+}
+
+func TestParseResourcesParam_CustomResource(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/context/u1?resources=limits,accounts", nil)
+	got := parseResourcesParam(req, testAppConfig())
+	if len(got) != 1 || got[0] != "limits" {
+		t.Errorf("expected [limits], got %v", got)
+	}
+}
+
+func TestParseResourcesParam_NoAppConfig(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/context/u1?resources=profile", nil)
+	if got := parseResourcesParam(req, nil); len(got) != 0 {
+		t.Errorf("expected 0, got %d", len(got))
 	}
 }
 
 func TestParseResourcesParam_CommaSeparated(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/context/u1?resources=profile,preferences", nil)
-	got := parseResourcesParam(req)
+	got := parseResourcesParam(req, testAppConfig())
 	if len(got) != 2 {
 		t.Fatalf("expected 2, got %d", len(got))
 	}
@@ -30,7 +72,7 @@ func TestParseResourcesParam_CommaSeparated(t *testing.T) {
 
 func TestParseResourcesParam_MultiKey(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/context/u1?resources=profile&resources=permissions", nil)
-	got := parseResourcesParam(req)
+	got := parseResourcesParam(req, testAppConfig())
 	if len(got) != 2 {
 		t.Fatalf("expected 2, got %d: %v", len(got), got)
 	}
@@ -38,7 +80,7 @@ func TestParseResourcesParam_MultiKey(t *testing.T) {
 
 func TestParseResourcesParam_Deduplicated(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/context/u1?resources=profile,profile,profile", nil)
-	got := parseResourcesParam(req)
+	got := parseResourcesParam(req, testAppConfig())
 	if len(got) != 1 {
 		t.Errorf("expected 1 after dedup, got %d", len(got))
 	}
@@ -46,7 +88,7 @@ func TestParseResourcesParam_Deduplicated(t *testing.T) {
 
 func TestParseResourcesParam_AllUnknown(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/context/u1?resources=bogus,invalid", nil)
-	got := parseResourcesParam(req)
+	got := parseResourcesParam(req, testAppConfig())
 	if len(got) != 0 {
 		t.Errorf("expected 0, got %d", len(got))
 	}
@@ -64,3 +106,237 @@ func TestHandleContext_NoValidResources(t *testing.T) {
 		t.Errorf("status: got %d, want %d", w.Code, http.StatusBadRequest)
 	}
 }
+
+func TestHandleContext_StaleWhileRevalidate(t *testing.T) {
+	var fetches atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write([]byte(`{"fresh":true}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour},
+		},
+		RevalidateFraction: 0.2,
+	}
+	backend := services.NewBackend(services.BackendConfig{}, upstream.Client())
+	srv := NewServer(store, hydrator.New(store, backend, log, time.Second), nil,
+		registry.New(store, time.Minute, app), log)
+
+	ctx := context.Background()
+	key := cache.ResourceCacheKey("test-app", "profile", "u1")
+	store.Set(ctx, key, json.RawMessage(`{"fresh":false}`), 5*time.Minute) // below 20% of 1h
+	store.StoreClaims(ctx, "test-app", "u1", map[string]string{"user_id": "u1"}, time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/context/u1?resources=profile", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	var resp contextResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if got := resp.Meta["profile"].Source; got != "cache-stale" {
+		t.Fatalf("source: got %q, want %q", got, "cache-stale")
+	}
+	if got := resp.Meta["profile"].TTLMillis; got != (5 * time.Minute).Milliseconds() {
+		t.Errorf("ttl_ms: got %d, want the remaining TTL", got)
+	}
+	if string(resp.Data["profile"]) != `{"fresh":false}` {
+		t.Errorf("expected the stale value to be served, got %s", resp.Data["profile"])
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for mr.TTL(key) <= 5*time.Minute && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if mr.TTL(key) != time.Hour {
+		t.Fatalf("entry was not re-hydrated: ttl %s", mr.TTL(key))
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("upstream fetches: got %d, want 1", n)
+	}
+}
+
+func TestHandleContext_StaleWithoutClaims(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: "http://svc/users/{user_id}/profile", TTL: time.Hour},
+		},
+		RevalidateFraction: 0.2,
+	}
+	srv := NewServer(store, hydrator.New(store, services.NewBackend(services.BackendConfig{}, nil), log, time.Second), nil,
+		registry.New(store, time.Minute, app), log)
+	store.Set(context.Background(), cache.ResourceCacheKey("test-app", "profile", "u1"), json.RawMessage(`{}`), 5*time.Minute)
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/context/u1?resources=profile", nil))
+
+	var resp contextResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if got := resp.Meta["profile"].Source; got != "cache" {
+		t.Fatalf("source without recorded claims: got %q, want %q", got, "cache")
+	}
+}
+
+func TestHandleContext_ReadThrough(t *testing.T) {
+	var fetches atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile:     {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour, ReadThrough: true},
+			services.ServicePreferences: {URLTemplate: upstream.URL + "/users/{user_id}/preferences", TTL: time.Hour},
+		},
+	}
+	backend := services.NewBackend(services.BackendConfig{}, upstream.Client())
+	verifier, token := testSessionVerifier(t, "test-app", "u1")
+	srv := NewServer(store, hydrator.New(store, backend, log, time.Second), nil,
+		registry.New(store, time.Minute, app), log, WithSessionVerifier(verifier))
+	store.StoreClaims(context.Background(), "test-app", "u1", map[string]string{"user_id": "u1"}, time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/context/u1", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	var resp contextResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if got := resp.Meta["profile"].Source; got != "origin" {
+		t.Fatalf("profile source: got %q, want origin", got)
+	}
+	if got := resp.Meta["profile"].TTLMillis; got != time.Hour.Milliseconds() {
+		t.Errorf("profile ttl_ms: got %d, want the resource TTL", got)
+	}
+	if string(resp.Data["profile"]) != `{"path":"/users/u1/profile"}` {
+		t.Errorf("profile: got %s", resp.Data["profile"])
+	}
+	if got := resp.Meta["preferences"]; got.Source != "unavailable" || got.Error != cacheMissError {
+		t.Errorf("preferences without read-through: got %+v", got)
+	}
+	if mr.TTL(cache.ResourceCacheKey("test-app", "profile", "u1")) != time.Hour {
+		t.Error("read-through result was not cached with the resource TTL")
+	}
+
+	// The next read is a cache hit.
+	req = httptest.NewRequest(http.MethodGet, "/data/u1/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if w.Header().Get("X-Cache") != "HIT" || fetches.Load() != 1 {
+		t.Errorf("second read: X-Cache %q, %d fetches", w.Header().Get("X-Cache"), fetches.Load())
+	}
+}
+
+func TestHandleData_ReadThroughRequiresSessionAuth(t *testing.T) {
+	var fetches atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour, ReadThrough: true},
+		},
+	}
+	srv := NewServer(store, hydrator.New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()), log, time.Second), nil,
+		registry.New(store, time.Minute, app), log)
+	store.StoreClaims(context.Background(), "test-app", "u1", map[string]string{"user_id": "u1"}, time.Hour)
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/data/u1/profile", nil))
+	if w.Code != http.StatusNotFound || fetches.Load() != 0 {
+		t.Errorf("without session auth: status %d, %d fetches; want 404 and none", w.Code, fetches.Load())
+	}
+}
+
+func TestHandleData_ReadThroughSessionClaims(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour, ReadThrough: true},
+		},
+	}
+	verifier, token := testSessionVerifier(t, "test-app", "u1")
+	srv := NewServer(store, hydrator.New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()), log, time.Second), nil,
+		registry.New(store, time.Minute, app), log, WithSessionVerifier(verifier))
+
+	// Never hydrated: the authorized session's claims fill the template.
+	req := httptest.NewRequest(http.MethodGet, "/data/u1/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != `{"path":"/users/u1/profile"}` {
+		t.Errorf("got %d %s", w.Code, w.Body.String())
+	}
+}
+
+// testSessionVerifier returns an HS256 session verifier and a token it
+// accepts for userID of appID.
+func testSessionVerifier(t *testing.T, appID, userID string) (auth.Verifier, string) {
+	t.Helper()
+	secret := []byte("session-secret")
+	verifier, err := auth.NewJWTVerifier(auth.JWTConfig{Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"app_id":  appID,
+		"user_id": userID,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return verifier, token
+}
+
+func TestHandleData_ReadThroughNoClaims(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: "http://svc/users/{user_id}/profile", TTL: time.Hour, ReadThrough: true},
+		},
+	}
+	srv := NewServer(store, hydrator.New(store, services.NewBackend(services.BackendConfig{}, nil), log, time.Second), nil,
+		registry.New(store, time.Minute, app), log)
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/data/u1/profile", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status: got %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -3,37 +3,82 @@ package api
 import (
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/metrics"
+	"github.com/yourorg/context-hydrator/internal/services"
 )
 
 // handleData serves GET /data/{contextKey}/{resource}.
 //
 // Returns the cached resource for the given context key.
 // Returns 404 if the resource has not been hydrated yet — the caller
-// should trigger POST /hydrate and retry.
+// should trigger POST /hydrate and retry. Entries close to expiry are served
+// with X-Cache: STALE while they are re-hydrated in the background. Resources
+// with read-through enabled are fetched from the backend on a miss instead,
+// cached and served with X-Cache: ORIGIN. Entries stored compressed are sent
+// as is with Content-Encoding: gzip to clients that accept it.
 func (s *Server) handleData() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		contextKey := chi.URLParam(r, "contextKey")
 		resource := chi.URLParam(r, "resource")
 
-		cacheKey, ok := cache.KeyForResource(s.appID(), contextKey, resource)
+		appConfig, ok := s.resolveAppConfig(w, r, s.requestAppID(r))
 		if !ok {
-			http.Error(w, `{"error":"unknown resource","allowed":["profile","preferences","permissions","resources"]}`,
-				http.StatusBadRequest)
 			return
 		}
 
-		data, err := s.store.Get(r.Context(), cacheKey)
+		if _, ok := cache.KeyForResource(appConfig, contextKey, resource); !ok {
+			writeResourceError(w, "unknown resource", appConfig)
+			return
+		}
+		s.recordAccess(appConfig, contextKey, services.ServiceName(resource))
+
+		var data []byte
+		var gzipped bool
+		var ttl time.Duration
+		var err error
+		if acceptsGzip(r) {
+			data, gzipped, ttl, err = s.store.GetResourceGzip(r.Context(), appConfig.AppID, resource, contextKey)
+		} else {
+			data, ttl, err = s.store.GetResource(r.Context(), appConfig.AppID, resource, contextKey)
+		}
 		if err == nil {
+			metrics.CacheHits.WithLabelValues(appConfig.AppID, resource).Inc()
 			w.Header().Set("Content-Type", "application/json")
-			w.Header().Set("X-Cache", "HIT")
+			w.Header().Set("Vary", "Accept-Encoding")
+			if gzipped {
+				w.Header().Set("Content-Encoding", "gzip")
+			}
+			if s.revalidateIfStale(r.Context(), appConfig, contextKey, services.ServiceName(resource), ttl) {
+				w.Header().Set("X-Cache", "STALE")
+			} else {
+				w.Header().Set("X-Cache", "HIT")
+			}
 			w.Write(data)
 			return
 		}
 
 		if errors.Is(err, cache.ErrCacheMiss) {
+			metrics.CacheMisses.WithLabelValues(appConfig.AppID, resource).Inc()
+			if s.readThroughEnabled(appConfig, services.ServiceName(resource)) {
+				data, err := s.readThrough(r, appConfig, contextKey, services.ServiceName(resource))
+				if err == nil {
+					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set("X-Cache", "ORIGIN")
+					w.Write(data)
+					return
+				}
+				if !errors.Is(err, hydrator.ErrNoClaims) {
+					http.Error(w, `{"error":"upstream unavailable"}`, http.StatusBadGateway)
+					return
+				}
+			}
 			http.Error(w, `{"error":"not found","hint":"trigger POST /hydrate first"}`, http.StatusNotFound)
 			return
 		}
@@ -43,3 +88,22 @@ func (s *Server) handleData() http.HandlerFunc {
 		http.Error(w, `{"error":"service unavailable"}`, http.StatusServiceUnavailable)
 	}
 }
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for part := range strings.SplitSeq(header, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+				continue
+			}
+			q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+			if !ok {
+				return true
+			}
+			v, err := strconv.ParseFloat(q, 64)
+			return err == nil && v > 0
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	"github.com/yourorg/context-hydrator/internal/registry"
+)
+
+func TestHandleData_Gzip(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})), cache.WithCompression(64))
+	log := observability.NewLogger("error", "text")
+	srv := NewServer(store, nil, nil, registry.New(store, time.Minute, testAppConfig()), log)
+
+	large := `[` + strings.Repeat(`{"project":"alpha"},`, 50) + `{}]`
+	store.SetResource(context.Background(), "test-app", "resources", "u1", json.RawMessage(large), time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/data/u1/resources", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("got %d with Content-Encoding %q, want gzip", w.Code, w.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != large {
+		t.Errorf("body: got %.40s", body)
+	}
+
+	for _, accept := range []string{"", "gzip;q=0", "identity"} {
+		req := httptest.NewRequest(http.MethodGet, "/data/u1/resources", nil)
+		if accept != "" {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		if w.Header().Get("Content-Encoding") != "" || w.Body.String() != large {
+			t.Errorf("Accept-Encoding %q: got Content-Encoding %q", accept, w.Header().Get("Content-Encoding"))
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/events"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+// Resource statuses reported by the events stream, in addition to the
+// hydrator's "ok" and "failed".
+const (
+	eventStatusCached      = "cached"      // already in the cache when the stream opened
+	eventStatusPending     = "pending"     // not written before the max wait elapsed
+	eventStatusUnavailable = "unavailable" // not part of the hydration run that completed
+)
+
+type resourceEvent struct {
+	Resource string `json:"resource"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+type completeEvent struct {
+	ContextKey string            `json:"context_key"`
+	Resources  map[string]string `json:"resources"`
+	TimedOut   bool              `json:"timed_out"`
+}
+
+// handleContextEvents serves GET /context/{contextKey}/events?resources=profile,...
+//
+// Server-Sent Events stream for clients waiting on a hydration:
+//  1. One "resource" event per requested resource already cached (status "cached")
+//  2. One "resource" event per resource as the hydrator writes it (status "ok" | "failed"),
+//     received over Redis Pub/Sub from whichever instance runs the hydration
+//  3. A final "complete" event once every resource is reported, the hydration run
+//     ends, or the max wait elapses — whichever comes first
+//
+// After "complete", read the data with GET /context/{contextKey}.
+func (s *Server) handleContextEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		contextKey := chi.URLParam(r, "contextKey")
+
+		appConfig, ok := s.resolveAppConfig(w, r, s.requestAppID(r))
+		if !ok {
+			return
+		}
+
+		requested := parseResourcesParam(r, appConfig)
+		if len(requested) == 0 {
+			writeResourceError(w, "no valid resources requested", appConfig)
+			return
+		}
+
+		if s.hub == nil {
+			http.Error(w, `{"error":"events unavailable"}`, http.StatusServiceUnavailable)
+			return
+		}
+
+		// Subscribe before reading the cache so a resource written in between
+		// is reported by one or the other.
+		updates, unsubscribe, err := s.hub.Subscribe(r.Context(), appConfig.AppID, contextKey)
+		if err != nil {
+			s.log.ErrorContext(r.Context(), "progress subscribe failed",
+				"context_key", contextKey, "error", err)
+			http.Error(w, `{"error":"service unavailable"}`, http.StatusServiceUnavailable)
+			return
+		}
+		defer unsubscribe()
+
+		// The stream outlives the server's WriteTimeout.
+		rc := http.NewResponseController(w)
+		rc.SetWriteDeadline(time.Now().Add(s.eventsMaxWait + 5*time.Second))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		statuses := make(map[string]string, len(requested))
+		pending := make(map[string]bool, len(requested))
+		for _, svc := range requested {
+			pending[string(svc)] = true
+		}
+
+		report := func(ev resourceEvent) {
+			if !pending[ev.Resource] {
+				return
+			}
+			delete(pending, ev.Resource)
+			statuses[ev.Resource] = ev.Status
+			writeSSE(w, "resource", ev)
+		}
+
+		s.reportCached(r, appConfig, contextKey, pending, report)
+		rc.Flush()
+
+		timer := time.NewTimer(s.eventsMaxWait)
+		defer timer.Stop()
+
+		timedOut := false
+	wait:
+		for len(pending) > 0 {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-timer.C:
+				// A write may have landed without its message reaching us.
+				s.reportCached(r, appConfig, contextKey, pending, report)
+				timedOut = len(pending) > 0
+				break wait
+			case p := <-updates:
+				switch p.Type {
+				case events.ProgressResource:
+					report(resourceEvent{Resource: p.Resource, Status: p.Status, Error: p.Error})
+				case events.ProgressComplete:
+					break wait
+				}
+				rc.Flush()
+			}
+		}
+
+		for name := range pending {
+			if timedOut {
+				statuses[name] = eventStatusPending
+			} else {
+				statuses[name] = eventStatusUnavailable
+			}
+		}
+		writeSSE(w, "complete", completeEvent{ContextKey: contextKey, Resources: statuses, TimedOut: timedOut})
+		rc.Flush()
+	}
+}
+
+// reportCached reports every pending resource that is already cached.
+func (s *Server) reportCached(r *http.Request, appConfig *services.AppConfig, contextKey string, pending map[string]bool, report func(resourceEvent)) {
+	for name := range pending {
+		key, _ := cache.KeyForResource(appConfig, contextKey, name)
+		_, err := s.store.Get(r.Context(), key)
+		if err == nil {
+			report(resourceEvent{Resource: name, Status: eventStatusCached})
+			continue
+		}
+		if !errors.Is(err, cache.ErrCacheMiss) {
+			s.log.WarnContext(r.Context(), "cache read error",
+				"context_key", contextKey, "resource", name, "error", err)
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, event string, data any) {
+	b, _ := json.Marshal(data)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+}
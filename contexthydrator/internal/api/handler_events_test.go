@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/events"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	"github.com/yourorg/context-hydrator/internal/registry"
+)
+
+func TestHandleContextEvents_StreamsProgress(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := cache.NewStore(cache.NewRedisBackend(client))
+	log := observability.NewLogger("error", "text")
+	app := testAppConfig()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hub := events.NewHub(client, log)
+	go hub.Run(ctx)
+
+	srv := NewServer(store, nil, nil, registry.New(store, time.Minute, app), log,
+		WithEventHub(hub, 2*time.Second))
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	store.Set(ctx, cache.ResourceCacheKey(app.AppID, "profile", "u1"), json.RawMessage(`{}`), time.Hour)
+
+	resp, err := http.Get(ts.URL + "/context/u1/events?resources=profile,limits,permissions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content-type: got %q", ct)
+	}
+
+	channel := events.ProgressChannelKey(app.AppID, "u1")
+	for deadline := time.Now().Add(2 * time.Second); mr.PubSubNumSub(channel)[channel] == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("reader did not subscribe to the progress channel")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	pub := events.NewPublisher(client)
+	pub.PublishProgress(ctx, app.AppID, "u1", events.Progress{Type: events.ProgressResource, Resource: "limits", Status: events.StatusOK})
+	pub.PublishProgress(ctx, app.AppID, "u1", events.Progress{Type: events.ProgressComplete})
+
+	got := readSSE(t, resp)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 events, got %d: %v", len(got), got)
+	}
+	if got[0].event != "resource" || !strings.Contains(got[0].data, `"status":"cached"`) {
+		t.Errorf("first event: %+v", got[0])
+	}
+	if got[1].event != "resource" || !strings.Contains(got[1].data, `"resource":"limits"`) {
+		t.Errorf("second event: %+v", got[1])
+	}
+
+	var complete completeEvent
+	if err := json.Unmarshal([]byte(got[2].data), &complete); err != nil {
+		t.Fatalf("decode complete: %v", err)
+	}
+	want := map[string]string{"profile": "cached", "limits": "ok", "permissions": "unavailable"}
+	for name, status := range want {
+		if complete.Resources[name] != status {
+			t.Errorf("%s: got %q, want %q", name, complete.Resources[name], status)
+		}
+	}
+	if complete.TimedOut {
+		t.Error("expected timed_out=false")
+	}
+}
+
+func TestHandleContextEvents_TimesOut(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := cache.NewStore(cache.NewRedisBackend(client))
+	log := observability.NewLogger("error", "text")
+	app := testAppConfig()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hub := events.NewHub(client, log)
+	go hub.Run(ctx)
+
+	srv := NewServer(store, nil, nil, registry.New(store, time.Minute, app), log,
+		WithEventHub(hub, 50*time.Millisecond))
+
+	req := httptest.NewRequest(http.MethodGet, "/context/u1/events?resources=profile", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if !strings.Contains(w.Body.String(), `"resources":{"profile":"pending"},"timed_out":true`) {
+		t.Errorf("unexpected stream: %s", w.Body.String())
+	}
+}
+
+func TestHandleContextEvents_NoHub(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	srv := NewServer(store, nil, nil, registry.New(store, time.Minute, testAppConfig()), log)
+
+	req := httptest.NewRequest(http.MethodGet, "/context/u1/events", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status: got %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+type sseEvent struct {
+	event, data string
+}
+
+func readSSE(t *testing.T, resp *http.Response) []sseEvent {
+	t.Helper()
+	var out []sseEvent
+	var cur sseEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			cur.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			cur.data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			out = append(out, cur)
+			cur = sseEvent{}
+		}
+	}
+	return out
+}
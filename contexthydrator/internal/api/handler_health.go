@@ -15,9 +15,7 @@ func (s *Server) handleHealth() http.HandlerFunc {
 			httpStatus = http.StatusServiceUnavailable
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(httpStatus)
-		json.NewEncoder(w).Encode(map[string]any{
+		body := map[string]any{
 			"status": func() string {
 				if httpStatus == http.StatusOK {
 					return "ok"
@@ -27,6 +25,14 @@ func (s *Server) handleHealth() http.HandlerFunc {
 			"checks": map[string]string{
 				"redis": redisStatus,
 			},
-		})
+		}
+		if s.pool != nil {
+			queued, busy, workers := s.pool.Stats()
+			body["hydration_pool"] = map[string]int{"queued": queued, "busy": busy, "workers": workers}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(httpStatus)
+		json.NewEncoder(w).Encode(body)
 	}
 }
@@ -2,45 +2,109 @@ package api
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/services"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// hydrationCookie is the cookie set by the SDK (Path=/hydrate). Browsers
+// send it with POST /hydrate, so the body may omit the cookie field.
+const hydrationCookie = "hyd"
+
+// hydrationRetryAfter is the Retry-After, in seconds, sent when a hydration
+// cannot be queued.
+const hydrationRetryAfter = 1
+
+// Bounds of POST /hydrate?wait=true: the timeout used when none is given,
+// the largest one accepted, and how often the job status is checked.
+const (
+	defaultHydrateWait = time.Second
+	maxHydrateWait     = 10 * time.Second
+	hydrateWaitPoll    = 10 * time.Millisecond
 )
 
 type hydrateRequest struct {
 	Cookie string `json:"cookie"`
 }
 
+// handleHydrate serves POST /hydrate. By default it starts the hydration and
+// returns 202 with a job ID at once. With ?wait=true&timeout=800ms it waits
+// up to the timeout and returns the hydrated context in the shape of
+// GET /context; resources still being fetched have meta.source "pending"
+// and finish in the background. Since that returns data, a waited hydration
+// requires a session that may read the contextKey, as on the reader. The
+// context is read from the cache only: no read-through, no stale
+// revalidation and no reader metrics.
 func (s *Server) handleHydrate() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		wait, timeout, err := parseWaitParams(r)
+		if err != nil {
+			http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+			return
+		}
+		if wait {
+			var ok bool
+			if r, ok = s.authenticate(w, r); !ok {
+				return
+			}
+		}
+
 		var req hydrateRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
 			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
 			return
 		}
+		if req.Cookie == "" {
+			if c, err := r.Cookie(hydrationCookie); err == nil {
+				req.Cookie = c.Value
+			}
+		}
 		if req.Cookie == "" {
 			http.Error(w, `{"error":"cookie field is required"}`, http.StatusBadRequest)
 			return
 		}
 
-		claims, err := s.decoder.Decode(req.Cookie)
+		// The wait starts with the request, so mapping lookups and queueing
+		// count against the caller's timeout.
+		deadline := time.Now().Add(timeout)
+
+		claims, err := s.decoder.Decode(r.Context(), req.Cookie)
 		if err != nil {
 			s.log.WarnContext(r.Context(), "cookie decode failed", "error", err)
 			http.Error(w, `{"error":"invalid cookie"}`, http.StatusBadRequest)
 			return
 		}
 
+		// JWT cookies name their app; base64json cookies fall back to the
+		// X-App-ID header or the default app.
+		appID := claims.AppID
+		if appID == "" {
+			appID = s.requestAppID(r)
+		}
+		appConfig, ok := s.resolveAppConfig(w, r, appID)
+		if !ok {
+			return
+		}
+		if !s.allowHydrate(w, r, appConfig, claims) {
+			return
+		}
+
 		var contextKey string
 		var rawClaims map[string]string
 
 		if claims.HydrationToken != "" {
 			// JWT mode: resolve hyd_token → {contextKey, claims} from Redis mapping.
 			// The mapping is stored at login time by the issuing application.
-			appID := claims.AppID
-			if appID == "" {
-				appID = s.appID()
-			}
 			mapping, err := s.store.ResolveMapping(r.Context(), appID, claims.HydrationToken)
 			if err != nil {
 				if err == cache.ErrCacheMiss {
@@ -60,21 +124,171 @@ func (s *Server) handleHydrate() http.HandlerFunc {
 			rawClaims = map[string]string{"user_id": claims.UserID}
 		}
 
-		if s.appConfig == nil {
-			// No app config — accept the request but skip hydration (test mode).
+		if wait && !s.authorize(w, r, appConfig, contextKey) {
+			return
+		}
+
+		if appConfig == nil {
+			// No app registry — accept the request but skip hydration (test mode).
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusAccepted)
 			json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
 			return
 		}
 
+		// The job ID lets the caller follow the hydration on GET /hydrate/{jobID}.
+		// Its status is written before the hand-off so a worker never finds
+		// it missing.
+		jobID := rand.Text()
+		if err := s.hydrator.Accept(r.Context(), appConfig.AppID, jobID); err != nil {
+			s.log.WarnContext(r.Context(), "job status write failed",
+				"app_id", appConfig.AppID, "job_id", jobID, "error", err)
+		}
+
 		// Fire-and-forget: background context so HTTP cancellation does not
-		// kill the hydration goroutine.
-		bgCtx := context.Background()
-		go s.hydrator.RunHydration(bgCtx, s.appConfig, contextKey, rawClaims)
+		// kill the hydration goroutine. It carries the request's span context
+		// only so the hydration trace can link back to this request.
+		bgCtx := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(r.Context()))
+		bgCtx = hydrator.ContextWithJobID(bgCtx, jobID)
+		switch {
+		case s.jobs != nil:
+			if err := s.jobs.Enqueue(hydrator.ContextWithJobID(r.Context(), jobID), appConfig.AppID, contextKey, rawClaims); err != nil {
+				s.log.ErrorContext(r.Context(), "hydration enqueue failed",
+					"app_id", appConfig.AppID, "context_key", contextKey, "error", err)
+				s.abandonJob(r, jobID)
+				w.Header().Set("Retry-After", strconv.Itoa(hydrationRetryAfter))
+				http.Error(w, `{"error":"service unavailable"}`, http.StatusServiceUnavailable)
+				return
+			}
+		case s.pool != nil:
+			if err := s.pool.Submit(bgCtx, appConfig, contextKey, rawClaims); err != nil {
+				s.log.WarnContext(r.Context(), "hydration rejected",
+					"app_id", appConfig.AppID, "context_key", contextKey, "error", err)
+				s.abandonJob(r, jobID)
+				w.Header().Set("Retry-After", strconv.Itoa(hydrationRetryAfter))
+				http.Error(w, `{"error":"hydration queue full"}`, http.StatusServiceUnavailable)
+				return
+			}
+		default:
+			go s.hydrator.RunHydration(bgCtx, appConfig, contextKey, rawClaims)
+		}
 
 		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/hydrate/"+jobID)
+		if wait {
+			st := s.waitForJob(r, jobID, deadline)
+			resources := parseResourcesParam(r, appConfig)
+			resp := s.cachedContext(r, appConfig, contextKey, resources)
+			markPending(resp, st, resources)
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
 		w.WriteHeader(http.StatusAccepted)
-		json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+		json.NewEncoder(w).Encode(map[string]string{"status": "accepted", "job_id": jobID})
+	}
+}
+
+// parseWaitParams reads ?wait=true&timeout=800ms. The timeout defaults to
+// defaultHydrateWait and may not exceed maxHydrateWait.
+func parseWaitParams(r *http.Request) (bool, time.Duration, error) {
+	q := r.URL.Query()
+	if q.Get("wait") == "" {
+		return false, 0, nil
+	}
+	wait, err := strconv.ParseBool(q.Get("wait"))
+	if err != nil {
+		return false, 0, errors.New("invalid wait")
+	}
+	if !wait {
+		return false, 0, nil
+	}
+	timeout := defaultHydrateWait
+	if raw := q.Get("timeout"); raw != "" {
+		timeout, err = time.ParseDuration(raw)
+		if err != nil || timeout <= 0 {
+			return false, 0, errors.New("invalid timeout")
+		}
+		timeout = min(timeout, maxHydrateWait)
+	}
+	return true, timeout, nil
+}
+
+// waitForJob polls the status of jobID until the hydration ends, the
+// deadline passes or the client goes away, and returns the last status seen
+// (nil if none could be read).
+func (s *Server) waitForJob(r *http.Request, jobID string, deadline time.Time) *hydrator.JobStatus {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	ticker := time.NewTicker(hydrateWaitPoll)
+	defer ticker.Stop()
+
+	var last *hydrator.JobStatus
+	for {
+		if st, err := s.hydrator.JobStatus(r.Context(), jobID); err == nil {
+			last = st
+			if st.Terminal() {
+				return last
+			}
+		}
+		select {
+		case <-r.Context().Done():
+			return last
+		case <-timer.C:
+			return last
+		case <-ticker.C:
+		}
+	}
+}
+
+// markPending sets the meta of resources missing from the cache after a
+// waited hydration: "pending" while the job may still fetch them,
+// "unavailable" with the upstream error once it has given up on them.
+func markPending(resp contextResponse, st *hydrator.JobStatus, resources []services.ServiceName) {
+	for _, svc := range resources {
+		if resp.Meta[string(svc)].Error != cacheMissError {
+			continue
+		}
+		if st != nil {
+			if rs, ok := st.Resources[string(svc)]; ok && rs.Status == "failed" {
+				resp.Meta[string(svc)] = resourceMeta{Source: "unavailable", Error: rs.Error}
+				continue
+			}
+			if st.State == hydrator.JobComplete || st.State == hydrator.JobFailed {
+				resp.Meta[string(svc)] = resourceMeta{Source: "unavailable", Error: "not hydrated"}
+				continue
+			}
+		}
+		resp.Meta[string(svc)] = resourceMeta{Source: "pending"}
+	}
+}
+
+// abandonJob removes the status of a job that was never handed off.
+func (s *Server) abandonJob(r *http.Request, jobID string) {
+	if err := s.store.Delete(r.Context(), cache.JobStatusKey(jobID)); err != nil {
+		s.log.WarnContext(r.Context(), "job status delete failed", "job_id", jobID, "error", err)
+	}
+}
+
+// handleHydrateStatus serves GET /hydrate/{jobID}: the state of an accepted
+// hydration and, once fetched, each resource's outcome and latency. Statuses
+// expire shortly after the hydration ends; unknown or expired IDs are 404.
+func (s *Server) handleHydrateStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobID := chi.URLParam(r, "jobID")
+
+		st, err := s.hydrator.JobStatus(r.Context(), jobID)
+		if errors.Is(err, cache.ErrCacheMiss) {
+			http.Error(w, `{"error":"job not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.log.ErrorContext(r.Context(), "job status read failed", "job_id", jobID, "error", err)
+			http.Error(w, `{"error":"service unavailable"}`, http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(st)
 	}
 }
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/cookie"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/jobs"
+	"github.com/yourorg/context-hydrator/internal/metrics"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"github.com/yourorg/context-hydrator/internal/registry"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+func TestHandleHydrate_PoolUnavailable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: "http://svc/users/{user_id}/profile", TTL: time.Hour},
+		},
+	}
+	hyd := hydrator.New(store, nil, log, time.Second)
+	pool := hydrator.NewPool(hyd, 1, 1)
+	pool.Drain(t.Context()) // a draining pool rejects like a full one
+
+	srv := NewServer(store, hyd, cookie.NewDecoder("base64json", ""), registry.New(store, time.Minute, app), log,
+		WithHydrationPool(pool))
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(`{"user_id":"u123"}`))
+	req := httptest.NewRequest(http.MethodPost, "/hydrate", bytes.NewBufferString(`{"cookie":"`+encoded+`"}`))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status: got %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Retry-After: got %q, want 1", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestHandleHydrate_JobQueue(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := cache.NewStore(cache.NewRedisBackend(client))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: "http://svc/users/{user_id}/profile", TTL: time.Hour},
+		},
+	}
+	hyd := hydrator.New(store, nil, log, time.Second)
+	srv := NewServer(store, hyd, cookie.NewDecoder("base64json", ""), registry.New(store, time.Minute, app), log,
+		WithJobQueue(jobs.NewQueue(client, store, 1000)))
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(`{"user_id":"u123"}`))
+	req := httptest.NewRequest(http.MethodPost, "/hydrate", bytes.NewBufferString(`{"cookie":"`+encoded+`"}`))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status: got %d, want %d", w.Code, http.StatusAccepted)
+	}
+	entries, err := client.XRange(t.Context(), redisc.KeyHydrationJobs, "-", "+").Result()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("queued jobs: got %d (%v), want 1", len(entries), err)
+	}
+	var job jobs.Job
+	if err := json.Unmarshal([]byte(entries[0].Values["job"].(string)), &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.AppID != "test-app" || job.ContextKey != "u123" || job.SealedClaims == nil {
+		t.Errorf("unexpected job: %+v", job)
+	}
+
+	var resp map[string]string
+	json.NewDecoder(w.Body).Decode(&resp)
+	if job.ID == "" || job.ID != resp["job_id"] {
+		t.Errorf("job ID: queued %q, returned %q", job.ID, resp["job_id"])
+	}
+}
+
+func TestHandleHydrate_JobStatus(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users/u123/preferences" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"name":"Ada"}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile:     {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour},
+			services.ServicePreferences: {URLTemplate: upstream.URL + "/users/{user_id}/preferences", TTL: time.Hour},
+		},
+	}
+	hyd := hydrator.New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()), log, time.Second)
+	srv := NewServer(store, hyd, cookie.NewDecoder("base64json", ""), registry.New(store, time.Minute, app), log)
+	h := srv.HydrationHandler()
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(`{"user_id":"u123"}`))
+	req := httptest.NewRequest(http.MethodPost, "/hydrate", bytes.NewBufferString(`{"cookie":"`+encoded+`"}`))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status: got %d, want %d", w.Code, http.StatusAccepted)
+	}
+	var accepted map[string]string
+	json.NewDecoder(w.Body).Decode(&accepted)
+	jobID := accepted["job_id"]
+	if jobID == "" || w.Header().Get("Location") != "/hydrate/"+jobID {
+		t.Fatalf("job_id %q, Location %q", jobID, w.Header().Get("Location"))
+	}
+
+	var st hydrator.JobStatus
+	deadline := time.Now().Add(2 * time.Second)
+	for !st.Terminal() {
+		if time.Now().After(deadline) {
+			t.Fatalf("job not complete: %+v", st)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hydrate/"+jobID, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status lookup: got %d", w.Code)
+		}
+		st = hydrator.JobStatus{}
+		json.NewDecoder(w.Body).Decode(&st)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if st.State != hydrator.JobComplete || st.StartedAt == nil || st.CompletedAt == nil {
+		t.Errorf("unexpected status: %+v", st)
+	}
+	if got := st.Resources["profile"]; got.Status != "ok" {
+		t.Errorf("profile: got %+v", got)
+	}
+	if got := st.Resources["preferences"]; got.Status != "failed" || got.Error == "" {
+		t.Errorf("preferences: got %+v", got)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hydrate/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown job: got %d, want 404", w.Code)
+	}
+}
+
+func TestHandleHydrate_Wait(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/u123/preferences":
+			<-release
+		case "/users/u123/permissions":
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"name":"Ada"}`))
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile:     {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour},
+			services.ServicePreferences: {URLTemplate: upstream.URL + "/users/{user_id}/preferences", TTL: time.Hour},
+			services.ServicePermissions: {URLTemplate: upstream.URL + "/users/{user_id}/permissions", TTL: time.Hour},
+		},
+	}
+	hyd := hydrator.New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()), log, 5*time.Second)
+	srv := NewServer(store, hyd, cookie.NewDecoder("base64json", ""), registry.New(store, time.Minute, app), log)
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(`{"user_id":"u123"}`))
+	req := httptest.NewRequest(http.MethodPost, "/hydrate?wait=true&timeout=300ms", bytes.NewBufferString(`{"cookie":"`+encoded+`"}`))
+	w := httptest.NewRecorder()
+	srv.HydrationHandler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want %d", w.Code, http.StatusOK)
+	}
+	if w.Header().Get("Location") == "" {
+		t.Error("missing Location header")
+	}
+	var resp contextResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ContextKey != "u123" || string(resp.Data["profile"]) != `{"name":"Ada"}` {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if got := resp.Meta["profile"].Source; got != "cache" {
+		t.Errorf("profile source: got %q, want cache", got)
+	}
+	if got := resp.Meta["preferences"].Source; got != "pending" {
+		t.Errorf("preferences source: got %q, want pending", got)
+	}
+	if got := resp.Meta["permissions"]; got.Source != "unavailable" || got.Error == "" {
+		t.Errorf("permissions meta: got %+v, want unavailable with error", got)
+	}
+}
+
+func TestHandleHydrate_WaitRequiresSession(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":"Ada"}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "wait-auth-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour},
+		},
+	}
+	verifier, token := testSessionVerifier(t, "wait-auth-app", "u123")
+	_, otherToken := testSessionVerifier(t, "wait-auth-app", "u999")
+	hyd := hydrator.New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()), log, time.Second)
+	srv := NewServer(store, hyd, cookie.NewDecoder("base64json", ""), registry.New(store, time.Minute, app), log,
+		WithSessionVerifier(verifier))
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(`{"user_id":"u123"}`))
+	cases := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"other user", otherToken, http.StatusForbidden},
+		{"own context key", token, http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/hydrate?wait=true", bytes.NewBufferString(`{"cookie":"`+encoded+`"}`))
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+			srv.HydrationHandler().ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Fatalf("status: got %d, want %d (%s)", w.Code, tc.status, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var resp contextResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if string(resp.Data["profile"]) != `{"name":"Ada"}` {
+				t.Errorf("unexpected response: %+v", resp)
+			}
+		})
+	}
+
+	// The waited read is not a reader cache hit.
+	if got := testutil.ToFloat64(metrics.CacheHits.WithLabelValues("wait-auth-app", "profile")); got != 0 {
+		t.Errorf("cache hits: got %v, want 0", got)
+	}
+}
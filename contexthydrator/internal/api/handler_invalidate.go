@@ -0,0 +1,62 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yourorg/context-hydrator/internal/cache"
+)
+
+// handleInvalidate serves DELETE /data/{contextKey}/{resource}.
+//
+// For backend services that cannot publish to the invalidation stream.
+// Requires Authorization: Bearer <INVALIDATION_TOKEN>. Deletes the cached
+// resource so the next read misses; returns 204 whether or not it was cached.
+func (s *Server) handleInvalidate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validServiceToken(r, s.invalidationToken) {
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+
+		contextKey := chi.URLParam(r, "contextKey")
+		resource := chi.URLParam(r, "resource")
+
+		appID := s.requestAppID(r)
+		appConfig, ok := s.resolveAppConfig(w, r, appID)
+		if !ok {
+			return
+		}
+
+		if _, ok := cache.KeyForResource(appConfig, contextKey, resource); !ok {
+			writeResourceError(w, "unknown resource", appConfig)
+			return
+		}
+
+		if err := s.store.DeleteResource(r.Context(), appConfig.AppID, resource, contextKey); err != nil {
+			s.log.ErrorContext(r.Context(), "cache delete failed",
+				"context_key", contextKey, "resource", resource, "error", err)
+			http.Error(w, `{"error":"service unavailable"}`, http.StatusServiceUnavailable)
+			return
+		}
+
+		s.log.InfoContext(r.Context(), "cache invalidated",
+			"app_id", appID, "context_key", contextKey, "resource", resource)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// validServiceToken reports whether r carries want as its bearer token. An
+// empty want rejects every request.
+func validServiceToken(r *http.Request, want string) bool {
+	if want == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yourorg/context-hydrator/internal/registry"
+)
+
+// registryAuth requires the registry service token on registry writes.
+func (s *Server) registryAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validServiceToken(r, s.registryToken) {
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleRegisterApp serves POST /platform/apps/register.
+//
+// Stores a new app registration. Returns 201 with the stored registration,
+// 409 if the app ID is already taken.
+func (s *Server) handleRegisterApp() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reg, ok := s.decodeRegistration(w, r, "")
+		if !ok {
+			return
+		}
+
+		err := s.apps.Register(r.Context(), reg)
+		if errors.Is(err, registry.ErrAppExists) {
+			http.Error(w, `{"error":"app already registered"}`, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			s.log.ErrorContext(r.Context(), "app registration failed", "app_id", reg.AppID, "error", err)
+			http.Error(w, `{"error":"service unavailable"}`, http.StatusServiceUnavailable)
+			return
+		}
+
+		s.log.InfoContext(r.Context(), "app registered", "app_id", reg.AppID)
+		writeRegistration(w, http.StatusCreated, reg)
+	}
+}
+
+// handleGetApp serves GET /platform/apps/{appID}.
+func (s *Server) handleGetApp() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reg, err := s.apps.Get(r.Context(), chi.URLParam(r, "appID"))
+		if errors.Is(err, registry.ErrAppNotFound) {
+			http.Error(w, `{"error":"app not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.log.ErrorContext(r.Context(), "app lookup failed", "error", err)
+			http.Error(w, `{"error":"service unavailable"}`, http.StatusServiceUnavailable)
+			return
+		}
+		writeRegistration(w, http.StatusOK, reg)
+	}
+}
+
+// handleUpdateApp serves PUT /platform/apps/{appID}.
+//
+// Replaces the whole registration. The body's app_id must be empty or match the path.
+func (s *Server) handleUpdateApp() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appID := chi.URLParam(r, "appID")
+		reg, ok := s.decodeRegistration(w, r, appID)
+		if !ok {
+			return
+		}
+
+		err := s.apps.Update(r.Context(), reg)
+		if errors.Is(err, registry.ErrAppNotFound) {
+			http.Error(w, `{"error":"app not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.log.ErrorContext(r.Context(), "app update failed", "app_id", appID, "error", err)
+			http.Error(w, `{"error":"service unavailable"}`, http.StatusServiceUnavailable)
+			return
+		}
+
+		s.log.InfoContext(r.Context(), "app updated", "app_id", appID)
+		writeRegistration(w, http.StatusOK, reg)
+	}
+}
+
+// handleDeleteApp serves DELETE /platform/apps/{appID}.
+//
+// Cached data for the app is left to expire with its TTLs.
+func (s *Server) handleDeleteApp() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appID := chi.URLParam(r, "appID")
+		err := s.apps.Delete(r.Context(), appID)
+		if errors.Is(err, registry.ErrAppNotFound) {
+			http.Error(w, `{"error":"app not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.log.ErrorContext(r.Context(), "app delete failed", "app_id", appID, "error", err)
+			http.Error(w, `{"error":"service unavailable"}`, http.StatusServiceUnavailable)
+			return
+		}
+
+		s.log.InfoContext(r.Context(), "app deleted", "app_id", appID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// decodeRegistration reads and validates a registration body, writing a 400 on
+// failure. When pathAppID is set, the body's app_id defaults to it and must match.
+func (s *Server) decodeRegistration(w http.ResponseWriter, r *http.Request, pathAppID string) (*registry.AppRegistration, bool) {
+	var reg registry.AppRegistration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	if pathAppID != "" {
+		if reg.AppID == "" {
+			reg.AppID = pathAppID
+		}
+		if reg.AppID != pathAppID {
+			http.Error(w, `{"error":"app_id does not match path"}`, http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	if err := reg.Validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return &reg, true
+}
+
+func writeRegistration(w http.ResponseWriter, status int, reg *registry.AppRegistration) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(reg)
+}
+
+// writeJSONError writes {"error": msg}, escaping msg for messages that carry
+// caller-supplied values.
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -67,6 +67,22 @@ func TestHandleHydrate_InvalidBody(t *testing.T) {
 	}
 }
 
+func TestHandleHydrate_InvalidWaitTimeout(t *testing.T) {
+	log := observability.NewLogger("info", "text")
+	decoder := cookie.NewDecoder("base64json", "")
+	hyd := hydrator.New(nil, nil, log, 0)
+	srv := NewServer(nil, hyd, decoder, nil, log)
+
+	req := httptest.NewRequest(http.MethodPost, "/hydrate?wait=true&timeout=soon", bytes.NewBufferString(`{}`))
+	w := httptest.NewRecorder()
+
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status: got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
 func TestHandleHydrate_MissingCookie(t *testing.T) {
 	log := observability.NewLogger("info", "text")
 	decoder := cookie.NewDecoder("base64json", "")
@@ -133,3 +149,96 @@ func TestHandleData_UnknownResource(t *testing.T) {
 func TestHandleData_CacheMiss(t *testing.T) {
 	t.Skip("requires Redis store — run with make test-integration")
 }
+
+func TestHandleRegisterApp_InvalidRegistration(t *testing.T) {
+	log := observability.NewLogger("info", "text")
+	srv := NewServer(nil, nil, nil, nil, log, WithRegistryToken("registry-token"))
+
+	body := bytes.NewBufferString(`{"app_id":"bad:id","resources":{}}`)
+	req := httptest.NewRequest(http.MethodPost, "/platform/apps/register", body)
+	req.Header.Set("Authorization", "Bearer registry-token")
+	w := httptest.NewRecorder()
+
+	srv.RegistryHandler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status: got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleUpdateApp_MismatchedAppID(t *testing.T) {
+	log := observability.NewLogger("info", "text")
+	srv := NewServer(nil, nil, nil, nil, log, WithRegistryToken("registry-token"))
+
+	body := bytes.NewBufferString(`{"app_id":"other-app"}`)
+	req := httptest.NewRequest(http.MethodPut, "/platform/apps/payments-app", body)
+	req.Header.Set("Authorization", "Bearer registry-token")
+	w := httptest.NewRecorder()
+
+	srv.RegistryHandler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status: got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRegistryWrites_Unauthorized(t *testing.T) {
+	log := observability.NewLogger("info", "text")
+	srv := NewServer(nil, nil, nil, nil, log, WithRegistryToken("registry-token"))
+
+	for _, auth := range []string{"", "Bearer wrong", "registry-token"} {
+		for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+			path := "/platform/apps/payments-app"
+			if method == http.MethodPost {
+				path = "/platform/apps/register"
+			}
+			req := httptest.NewRequest(method, path, bytes.NewBufferString(`{"app_id":"payments-app"}`))
+			if auth != "" {
+				req.Header.Set("Authorization", auth)
+			}
+			w := httptest.NewRecorder()
+
+			srv.RegistryHandler().ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("%s auth %q: got %d, want %d", method, auth, w.Code, http.StatusUnauthorized)
+			}
+		}
+	}
+}
+
+func TestRegistryRoutes_NotOnReader(t *testing.T) {
+	log := observability.NewLogger("info", "text")
+	srv := NewServer(nil, nil, nil, nil, log, WithRegistryToken("registry-token"))
+
+	for _, h := range []http.Handler{srv.ReaderHandler(), srv.Handler()} {
+		req := httptest.NewRequest(http.MethodPost, "/platform/apps/register", bytes.NewBufferString(`{}`))
+		req.Header.Set("Authorization", "Bearer registry-token")
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound && w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("got %d, want the route to be absent", w.Code)
+		}
+	}
+}
+
+func TestHandleInvalidate_Unauthorized(t *testing.T) {
+	log := observability.NewLogger("info", "text")
+	srv := NewServer(nil, nil, nil, nil, log, WithInvalidationToken("svc-token"))
+
+	for _, auth := range []string{"", "Bearer wrong", "svc-token"} {
+		req := httptest.NewRequest(http.MethodDelete, "/data/u123/profile", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+
+		srv.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("auth %q: got %d, want %d", auth, w.Code, http.StatusUnauthorized)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/cookie"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/metrics"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	"github.com/yourorg/context-hydrator/internal/registry"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+func TestMetrics_HydrationAndReads(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/preferences") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"name":"Ada"}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "metrics-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile:     {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour},
+			services.ServicePreferences: {URLTemplate: upstream.URL + "/users/{user_id}/preferences", TTL: time.Hour},
+		},
+	}
+	hyd := hydrator.New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()), log, time.Second)
+	srv := NewServer(store, hyd, cookie.NewDecoder("base64json", ""), registry.New(store, time.Minute, app), log)
+
+	hyd.RunHydration(context.Background(), app, "u1", map[string]string{"user_id": "u1"})
+
+	if got := testutil.ToFloat64(metrics.HydrationErrors.WithLabelValues("metrics-app", "preferences", metrics.StageFetch)); got != 1 {
+		t.Errorf("fetch errors: got %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.HydrationsInFlight.WithLabelValues("metrics-app")); got != 0 {
+		t.Errorf("in flight after run: got %v, want 0", got)
+	}
+
+	h := srv.Handler()
+	for _, path := range []string{"/data/u1/profile", "/data/u1/preferences", "/context/u1"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(appIDHeader, "metrics-app")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if got := testutil.ToFloat64(metrics.CacheHits.WithLabelValues("metrics-app", "profile")); got != 2 {
+		t.Errorf("profile hits: got %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.CacheMisses.WithLabelValues("metrics-app", "preferences")); got != 2 {
+		t.Errorf("preferences misses: got %v, want 2", got)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	for _, want := range []string{
+		`hydration_latency_ms_count{app_id="metrics-app",resource="profile"} 1`,
+		`upstream_request_duration_ms_count{app_id="metrics-app",resource="preferences",status_code="503"} 1`,
+		`cache_miss_total{app_id="metrics-app",resource="preferences"} 2`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("/metrics missing %q", want)
+		}
+	}
+}
+
+func TestHydrationHandler_NoMetrics(t *testing.T) {
+	srv := NewServer(nil, nil, nil, nil, observability.NewLogger("error", "text"))
+
+	w := httptest.NewRecorder()
+	srv.HydrationHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("internet-facing /metrics: got %d, want 404", w.Code)
+	}
+	w = httptest.NewRecorder()
+	srv.InternalHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("internal /metrics: got %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yourorg/context-hydrator/internal/cookie"
+	"github.com/yourorg/context-hydrator/internal/ratelimit"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+// allowHydrate applies the app's /hydrate rate limits and writes 429 with
+// Retry-After when one is exceeded. Requests are let through if Redis is
+// unavailable: the limiter protects the upstreams, not the hydrator itself.
+func (s *Server) allowHydrate(w http.ResponseWriter, r *http.Request, appConfig *services.AppConfig, claims *cookie.Claims) bool {
+	if s.limiter == nil || appConfig == nil {
+		return true
+	}
+
+	// JWT cookies are limited by hyd_token, base64json cookies by user_id.
+	token := claims.HydrationToken
+	if token == "" {
+		token = claims.UserID
+	}
+
+	decision, err := s.limiter.Allow(r.Context(), appConfig.AppID, appConfig.RateLimit,
+		ratelimit.HashToken(token), s.clientIP(r))
+	if err != nil {
+		s.log.WarnContext(r.Context(), "rate limit check failed, allowing request",
+			"app_id", appConfig.AppID, "error", err)
+		return true
+	}
+	if decision.Allowed {
+		return true
+	}
+
+	s.log.WarnContext(r.Context(), "hydrate rate limited",
+		"app_id", appConfig.AppID, "scope", decision.Scope)
+	retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	http.Error(w, `{"error":"rate limit exceeded"}`, http.StatusTooManyRequests)
+	return false
+}
+
+// clientIP returns the source IP of r. Behind a trusted gateway it is the
+// last X-Forwarded-For entry — the address the gateway itself saw — since
+// earlier entries are supplied by the client.
+func (s *Server) clientIP(r *http.Request) string {
+	if s.trustForwardedFor {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			parts := strings.Split(xff, ",")
+			if ip := strings.TrimSpace(parts[len(parts)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/cookie"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	"github.com/yourorg/context-hydrator/internal/ratelimit"
+	"github.com/yourorg/context-hydrator/internal/registry"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+func TestHandleHydrate_RateLimited(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := cache.NewStore(cache.NewRedisBackend(client))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour},
+		},
+		RateLimit: services.RateLimitConfig{PerToken: 1},
+	}
+	hyd := hydrator.New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()), log, time.Second)
+	srv := NewServer(store, hyd, cookie.NewDecoder("base64json", ""), registry.New(store, time.Minute, app), log,
+		WithRateLimiter(ratelimit.New(client), false))
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(`{"user_id":"u123"}`))
+	hydrate := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/hydrate", bytes.NewBufferString(`{"cookie":"`+encoded+`"}`))
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	if w := hydrate(); w.Code != http.StatusAccepted {
+		t.Fatalf("first request: got %d, want %d", w.Code, http.StatusAccepted)
+	}
+	w := hydrate()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: got %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/hydrate", nil)
+	req.RemoteAddr = "10.0.0.9:5555"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 203.0.113.7")
+
+	if got := (&Server{}).clientIP(req); got != "10.0.0.9" {
+		t.Errorf("untrusted: got %q, want %q", got, "10.0.0.9")
+	}
+	if got := (&Server{trustForwardedFor: true}).clientIP(req); got != "203.0.113.7" {
+		t.Errorf("trusted: got %q, want %q", got, "203.0.113.7")
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/yourorg/context-hydrator/internal/auth"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+// readThroughEnabled reports whether a cache miss of resource is fetched from
+// the backend instead of being returned as a miss. Read-through needs session
+// authentication: without a verifier any caller could trigger upstream
+// fetches for any contextKey.
+func (s *Server) readThroughEnabled(appConfig *services.AppConfig, resource services.ServiceName) bool {
+	return s.hydrator != nil && s.verifier != nil && appConfig.Resources[resource].ReadThrough
+}
+
+// readThrough fetches a missed resource through the hydrator. The verified
+// session's claims resolve the URL template when the contextKey has never
+// been hydrated, provided the session is authorized for contextKey. Failures
+// are logged; ErrNoClaims is left to the caller to report as a plain miss.
+func (s *Server) readThrough(r *http.Request, appConfig *services.AppConfig, contextKey string, resource services.ServiceName) (json.RawMessage, error) {
+	var sessionClaims map[string]string
+	if session, ok := auth.FromContext(r.Context()); ok && session.Authorizes(appConfig.AppID, appConfig.ContextKeyClaims, contextKey) {
+		sessionClaims = session.Claims
+	}
+	data, err := s.hydrator.ReadThrough(r.Context(), appConfig, contextKey, resource, sessionClaims)
+	if err != nil && !errors.Is(err, hydrator.ErrNoClaims) {
+		s.log.WarnContext(r.Context(), "read-through failed",
+			"app_id", appConfig.AppID, "context_key", contextKey, "resource", resource, "error", err)
+	}
+	return data, err
+}
@@ -1,58 +1,214 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/yourorg/context-hydrator/internal/accesspattern"
+	"github.com/yourorg/context-hydrator/internal/auth"
 	"github.com/yourorg/context-hydrator/internal/cache"
 	"github.com/yourorg/context-hydrator/internal/cookie"
+	"github.com/yourorg/context-hydrator/internal/events"
 	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/jobs"
+	"github.com/yourorg/context-hydrator/internal/metrics"
+	"github.com/yourorg/context-hydrator/internal/ratelimit"
+	"github.com/yourorg/context-hydrator/internal/registry"
 	"github.com/yourorg/context-hydrator/internal/services"
 )
 
+// appIDHeader names the app a reader or hydrate request is made for.
+const appIDHeader = "X-App-ID"
+
 type Server struct {
-	store     *cache.Store
-	hydrator  *hydrator.Hydrator
-	decoder   *cookie.Decoder
-	appConfig *services.AppConfig
-	log       *slog.Logger
+	store    *cache.Store
+	hydrator *hydrator.Hydrator
+	decoder  *cookie.Decoder
+	apps     *registry.Registry
+	log      *slog.Logger
+
+	invalidationToken string
+	registryToken     string
+
+	hub           *events.Hub
+	eventsMaxWait time.Duration
+
+	limiter           *ratelimit.Limiter
+	trustForwardedFor bool
+
+	verifier auth.Verifier
+
+	pool *hydrator.Pool
+	jobs *jobs.Queue
+
+	access *accesspattern.Tracker
+}
+
+// Option configures optional Server features.
+type Option func(*Server)
+
+// WithAccessTracker records which resources each contextKey reads on
+// /data and /context, so hydrations can be narrowed to them.
+func WithAccessTracker(t *accesspattern.Tracker) Option {
+	return func(s *Server) { s.access = t }
+}
+
+// WithJobQueue queues hydrations on the durable job stream for
+// cmd/hydration-worker instead of running them in this process.
+func WithJobQueue(q *jobs.Queue) Option {
+	return func(s *Server) { s.jobs = q }
+}
+
+// WithHydrationPool runs hydrations on the pool's bounded workers. POST /hydrate
+// returns 503 with Retry-After when the pool's queue is full. Without it each
+// hydration runs on its own goroutine.
+func WithHydrationPool(p *hydrator.Pool) Option {
+	return func(s *Server) { s.pool = p }
+}
+
+// WithInvalidationToken enables DELETE /data/{contextKey}/{resource} for
+// callers presenting this bearer token. Without it every call is rejected.
+func WithInvalidationToken(token string) Option {
+	return func(s *Server) { s.invalidationToken = token }
+}
+
+// WithRegistryToken enables the registry's POST, PUT and DELETE routes for
+// callers presenting this bearer token. Without it every write is rejected.
+func WithRegistryToken(token string) Option {
+	return func(s *Server) { s.registryToken = token }
+}
+
+// WithEventHub enables GET /context/{contextKey}/events. Each stream is held
+// open for at most maxWait before the final "complete" event is sent.
+func WithEventHub(hub *events.Hub, maxWait time.Duration) Option {
+	return func(s *Server) {
+		s.hub = hub
+		s.eventsMaxWait = maxWait
+	}
+}
+
+// WithRateLimiter enforces each app's per-token, per-IP and per-app limits on
+// POST /hydrate. trustForwardedFor takes the source IP from X-Forwarded-For;
+// enable it only behind a gateway that sets the header.
+func WithRateLimiter(l *ratelimit.Limiter, trustForwardedFor bool) Option {
+	return func(s *Server) {
+		s.limiter = l
+		s.trustForwardedFor = trustForwardedFor
+	}
+}
+
+// WithSessionVerifier requires a session token on the reader routes
+// (/data and /context) and on POST /hydrate?wait=true, and checks it
+// authorizes the requested context key.
+func WithSessionVerifier(v auth.Verifier) Option {
+	return func(s *Server) { s.verifier = v }
 }
 
 func NewServer(
 	store *cache.Store,
 	hyd *hydrator.Hydrator,
 	decoder *cookie.Decoder,
-	appConfig *services.AppConfig,
+	apps *registry.Registry,
 	log *slog.Logger,
+	opts ...Option,
 ) *Server {
-	return &Server{
-		store:     store,
-		hydrator:  hyd,
-		decoder:   decoder,
-		appConfig: appConfig,
-		log:       log,
+	s := &Server{
+		store:    store,
+		hydrator: hyd,
+		decoder:  decoder,
+		apps:     apps,
+		log:      log,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
-// appID returns the configured app ID, falling back to "default" for tests.
+// appID returns the default app ID, falling back to "default" for tests.
 func (s *Server) appID() string {
-	if s.appConfig == nil {
+	if s.apps == nil {
 		return "default"
 	}
-	return s.appConfig.AppID
+	return s.apps.DefaultAppID()
+}
+
+// requestAppID returns the app of the request's session, else the app named
+// by the X-App-ID header, else the default app. A session's app cannot be
+// overridden by the header: authenticate rejects a header naming another app.
+func (s *Server) requestAppID(r *http.Request) string {
+	if session, ok := auth.FromContext(r.Context()); ok {
+		return session.AppID
+	}
+	if id := r.Header.Get(appIDHeader); id != "" {
+		return id
+	}
+	return s.appID()
+}
+
+// appConfig resolves the runtime config for appID from the registry.
+// Returns nil, nil when no registry is configured (test mode).
+func (s *Server) appConfig(ctx context.Context, appID string) (*services.AppConfig, error) {
+	if s.apps == nil {
+		return nil, nil
+	}
+	return s.apps.AppConfig(ctx, appID)
+}
+
+// resolveAppConfig is appConfig for handlers: it writes 400 for an unknown app
+// and 503 for a registry failure, returning false in both cases.
+func (s *Server) resolveAppConfig(w http.ResponseWriter, r *http.Request, appID string) (*services.AppConfig, bool) {
+	appConfig, err := s.appConfig(r.Context(), appID)
+	if err == nil {
+		return appConfig, true
+	}
+	if errors.Is(err, registry.ErrAppNotFound) {
+		s.log.WarnContext(r.Context(), "unknown app", "app_id", appID)
+		http.Error(w, `{"error":"unknown app"}`, http.StatusBadRequest)
+	} else {
+		s.log.ErrorContext(r.Context(), "app config lookup failed", "app_id", appID, "error", err)
+		http.Error(w, `{"error":"service unavailable"}`, http.StatusServiceUnavailable)
+	}
+	return nil, false
+}
+
+// writeResourceError writes a 400 listing the app's valid resource names.
+func writeResourceError(w http.ResponseWriter, msg string, appConfig *services.AppConfig) {
+	allowed := []services.ServiceName{}
+	if appConfig != nil {
+		allowed = appConfig.ResourceNames()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]any{"error": msg, "allowed": allowed})
+}
+
+// recordAccess counts a read of resources towards contextKey's access pattern.
+func (s *Server) recordAccess(appConfig *services.AppConfig, contextKey string, resources ...services.ServiceName) {
+	if s.access == nil || appConfig == nil {
+		return
+	}
+	s.access.Record(appConfig.AppID, contextKey, resources)
 }
 
 // HydrationHandler returns routes for the hydration service (unauthenticated, pre-auth).
-// Exposed to the internet — POST /hydrate only.
+// Exposed to the internet — POST /hydrate and its job status only. Metrics
+// are served separately by InternalHandler.
 func (s *Server) HydrationHandler() http.Handler {
 	r := chi.NewRouter()
 	r.Use(requestIDMiddleware)
+	r.Use(publicTracingMiddleware)
 	r.Use(loggingMiddleware(s.log))
 	r.Use(chimiddleware.Recoverer)
 
 	r.Post("/hydrate", s.handleHydrate())
+	r.Get("/hydrate/{jobID}", s.handleHydrateStatus())
 	r.Get("/health", s.handleHealth())
 	r.Head("/health", s.handleHealth())
 
@@ -61,37 +217,90 @@ func (s *Server) HydrationHandler() http.Handler {
 
 // ReaderHandler returns routes for the context reader service (authenticated, post-auth).
 // Internal — requires session token. Reads from Redis only; returns 404 on cache miss.
+// DELETE /data is the one write: service-token invalidation for backends.
 func (s *Server) ReaderHandler() http.Handler {
 	r := chi.NewRouter()
 	r.Use(requestIDMiddleware)
+	r.Use(tracingMiddleware)
 	r.Use(loggingMiddleware(s.log))
 	r.Use(chimiddleware.Recoverer)
 
+	r.Group(s.readerRoutes)
+	r.Delete("/data/{contextKey}/{resource}", s.handleInvalidate())
+	r.Get("/health", s.handleHealth())
+	r.Head("/health", s.handleHealth())
+	r.Handle("/metrics", metrics.Handler())
+
+	return r
+}
+
+// readerRoutes registers the session-authenticated read routes.
+func (s *Server) readerRoutes(r chi.Router) {
+	r.Use(s.sessionAuth)
 	r.Get("/data/{contextKey}/{resource}", s.handleData())
 	r.Head("/data/{contextKey}/{resource}", s.handleData())
 	r.Get("/context/{contextKey}", s.handleContext())
 	r.Head("/context/{contextKey}", s.handleContext())
+	r.Get("/context/{contextKey}/events", s.handleContextEvents())
+}
+
+// RegistryHandler returns routes for the app registry service.
+// Internal — used by app teams to onboard and manage their hydration config.
+// Registrations point the hydrator at upstream URLs, so writes require the
+// registry service token.
+func (s *Server) RegistryHandler() http.Handler {
+	r := chi.NewRouter()
+	r.Use(requestIDMiddleware)
+	r.Use(tracingMiddleware)
+	r.Use(loggingMiddleware(s.log))
+	r.Use(chimiddleware.Recoverer)
+
+	r.Get("/platform/apps/{appID}", s.handleGetApp())
+	r.Group(func(r chi.Router) {
+		r.Use(s.registryAuth)
+		r.Post("/platform/apps/register", s.handleRegisterApp())
+		r.Put("/platform/apps/{appID}", s.handleUpdateApp())
+		r.Delete("/platform/apps/{appID}", s.handleDeleteApp())
+	})
 	r.Get("/health", s.handleHealth())
 	r.Head("/health", s.handleHealth())
+	r.Handle("/metrics", metrics.Handler())
+
+	return r
+}
+
+// InternalHandler returns health and metrics only, for probes and scraping:
+// the routes of cmd/hydration-worker, and of the internal listener that
+// cmd/hydration-server runs beside its internet-facing one.
+func (s *Server) InternalHandler() http.Handler {
+	r := chi.NewRouter()
+	r.Use(requestIDMiddleware)
+	r.Use(chimiddleware.Recoverer)
+
+	r.Get("/health", s.handleHealth())
+	r.Head("/health", s.handleHealth())
+	r.Handle("/metrics", metrics.Handler())
 
 	return r
 }
 
 // Handler returns all routes on a single mux — used by cmd/server for local development
-// and combined testing. Both hydration and reader routes are served on the same port.
+// and combined testing. Hydration and reader routes are served on the same port; the
+// registry is only served by RegistryHandler.
 func (s *Server) Handler() http.Handler {
 	r := chi.NewRouter()
 	r.Use(requestIDMiddleware)
+	r.Use(tracingMiddleware)
 	r.Use(loggingMiddleware(s.log))
 	r.Use(chimiddleware.Recoverer)
 
 	r.Post("/hydrate", s.handleHydrate())
-	r.Get("/data/{contextKey}/{resource}", s.handleData())
-	r.Head("/data/{contextKey}/{resource}", s.handleData())
-	r.Get("/context/{contextKey}", s.handleContext())
-	r.Head("/context/{contextKey}", s.handleContext())
+	r.Get("/hydrate/{jobID}", s.handleHydrateStatus())
+	r.Group(s.readerRoutes)
+	r.Delete("/data/{contextKey}/{resource}", s.handleInvalidate())
 	r.Get("/health", s.handleHealth())
 	r.Head("/health", s.handleHealth())
+	r.Handle("/metrics", metrics.Handler())
 
 	return r
 }
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+// revalidateIfStale implements stale-while-revalidate for reads: when a cached
+// entry's remaining ttl is below the app's RevalidateFraction of the resource
+// TTL, it starts a deduplicated background re-hydration of that resource.
+//
+// Returns true if a refresh is in flight, i.e. the caller is serving a stale entry.
+func (s *Server) revalidateIfStale(ctx context.Context, appConfig *services.AppConfig, contextKey string, resource services.ServiceName, ttl time.Duration) bool {
+	if s.hydrator == nil || appConfig.RevalidateFraction <= 0 || ttl < 0 {
+		return false
+	}
+	threshold := time.Duration(float64(appConfig.Resources[resource].TTL) * appConfig.RevalidateFraction)
+	if ttl >= threshold {
+		return false
+	}
+	return s.hydrator.Revalidate(ctx, appConfig, contextKey, resource)
+}
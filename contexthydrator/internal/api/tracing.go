@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/yourorg/context-hydrator/internal/api")
+
+// tracingMiddleware starts a server span per request, continuing the caller's
+// trace when a traceparent header is present. The span is renamed to the
+// matched route pattern once routing completes. It is for internal routers,
+// whose callers are trusted to choose the trace and its sampling.
+func tracingMiddleware(next http.Handler) http.Handler {
+	return traceRequests(next, true)
+}
+
+// publicTracingMiddleware is tracingMiddleware for internet-facing routers:
+// every request starts a new root span, sampled by this service's own
+// sampler, and a caller's traceparent is only recorded as a link. Clients
+// can neither force sampling nor place spans in internal traces.
+func publicTracingMiddleware(next http.Handler) http.Handler {
+	return traceRequests(next, false)
+}
+
+func traceRequests(next http.Handler, continueParent bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		opts := []trace.SpanStartOption{
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		}
+		ctx := remote
+		if !continueParent {
+			ctx = r.Context()
+			opts = append(opts, trace.WithNewRoot())
+			if sc := trace.SpanContextFromContext(remote); sc.IsValid() {
+				opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+			}
+		}
+		ctx, span := tracer.Start(ctx, r.Method, opts...)
+		defer span.End()
+
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				span.SetName(r.Method + " " + pattern)
+				span.SetAttributes(semconv.HTTPRoute(pattern))
+			}
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rw.status))
+		if rw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rw.status))
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/cookie"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	"github.com/yourorg/context-hydrator/internal/registry"
+	"github.com/yourorg/context-hydrator/internal/services"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	spanRecorder     *tracetest.SpanRecorder
+	spanRecorderOnce sync.Once
+)
+
+// recordSpans installs one global tracer provider for the package's tests
+// and returns its recorder. Tracers bind to the first global provider, so it
+// cannot be replaced per test.
+func recordSpans() *tracetest.SpanRecorder {
+	spanRecorderOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return spanRecorder
+}
+
+func TestTracing_HydrateLinksBackgroundTrace(t *testing.T) {
+	recorder := recordSpans()
+	start := time.Now()
+
+	traceparents := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("traceparent")
+		w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour},
+		},
+	}
+	hyd := hydrator.New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()), log, time.Second)
+	srv := NewServer(store, hyd, cookie.NewDecoder("base64json", ""), registry.New(store, time.Minute, app), log)
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(`{"user_id":"u123"}`))
+	req := httptest.NewRequest(http.MethodPost, "/hydrate", bytes.NewBufferString(`{"cookie":"`+encoded+`"}`))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status: got %d, want %d", w.Code, http.StatusAccepted)
+	}
+
+	var traceparent string
+	select {
+	case traceparent = <-traceparents:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream was not called")
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	deadline := time.Now().Add(2 * time.Second)
+	for spans["hydration"] == nil && time.Now().Before(deadline) {
+		for _, s := range recorder.Ended() {
+			if !s.StartTime().Before(start) {
+				spans[s.Name()] = s
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, name := range []string{"POST /hydrate", "hydration", "ResolveResources", "upstream.fetch", "cache.write"} {
+		if spans[name] == nil {
+			t.Fatalf("missing span %q", name)
+		}
+	}
+
+	request, hydration := spans["POST /hydrate"], spans["hydration"]
+	if got := request.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("request span did not continue the incoming trace: %s", got)
+	}
+	if hydration.SpanContext().TraceID() == request.SpanContext().TraceID() {
+		t.Error("hydration should start its own trace")
+	}
+	if links := hydration.Links(); len(links) != 1 || links[0].SpanContext.SpanID() != request.SpanContext().SpanID() {
+		t.Errorf("hydration links: got %+v, want the request span", links)
+	}
+	fetch := spans["upstream.fetch"]
+	if fetch.Parent().TraceID() != hydration.SpanContext().TraceID() {
+		t.Error("upstream.fetch is not part of the hydration trace")
+	}
+	want := "00-" + fetch.SpanContext().TraceID().String() + "-" + fetch.SpanContext().SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("upstream traceparent: got %q, want %q", traceparent, want)
+	}
+}
+
+func TestTracing_HydrationHandlerStartsNewTrace(t *testing.T) {
+	recorder := recordSpans()
+	srv := NewServer(nil, nil, nil, nil, observability.NewLogger("error", "text"))
+
+	// An unsampled client trace: continuing it would drop the span.
+	req := httptest.NewRequest(http.MethodPost, "/hydrate", nil)
+	req.Header.Set("traceparent", "00-5bf92f3577b34da6a3ce929d0e0e4736-10f067aa0ba902b7-00")
+	srv.HydrationHandler().ServeHTTP(httptest.NewRecorder(), req)
+
+	var span sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		if links := s.Links(); len(links) == 1 && links[0].SpanContext.SpanID().String() == "10f067aa0ba902b7" {
+			span = s
+		}
+	}
+	if span == nil {
+		t.Fatal("no sampled request span linked to the client's span")
+	}
+	if span.Parent().IsValid() || span.SpanContext().TraceID().String() == "5bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Error("request span continued the client's trace")
+	}
+}
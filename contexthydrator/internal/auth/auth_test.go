@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourorg/context-hydrator/internal/jwks"
+)
+
+var secret = []byte("session-secret")
+
+func signHS256(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":     "auth.example",
+		"aud":     "context-reader",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+		"app_id":  "payments-app",
+		"user_id": "u1",
+	}
+}
+
+func TestJWTVerifier_HS256(t *testing.T) {
+	v, err := NewJWTVerifier(JWTConfig{Secret: secret, Issuer: "auth.example", Audience: "context-reader"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := v.Verify(context.Background(), signHS256(t, validClaims()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if session.AppID != "payments-app" || session.Claims["user_id"] != "u1" {
+		t.Errorf("unexpected session: %+v", session)
+	}
+
+	cases := map[string]func(jwt.MapClaims){
+		"wrong issuer":   func(c jwt.MapClaims) { c["iss"] = "evil.example" },
+		"wrong audience": func(c jwt.MapClaims) { c["aud"] = "other" },
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"no expiry":      func(c jwt.MapClaims) { delete(c, "exp") },
+		"no app_id":      func(c jwt.MapClaims) { delete(c, "app_id") },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			claims := validClaims()
+			mutate(claims)
+			if _, err := v.Verify(context.Background(), signHS256(t, claims)); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("expected ErrInvalidToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestJWTVerifier_RS256FromJWKS(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	os.WriteFile(path, []byte(`{"keys":[{"kty":"RSA","kid":"k1","n":"`+
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes())+`","e":"`+
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())+`"}]}`), 0o600)
+
+	v, err := NewJWTVerifier(JWTConfig{JWKS: jwks.NewSource(path, time.Minute, nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims())
+	tok.Header["kid"] = "k1"
+	signed, _ := tok.SignedString(key)
+	if _, err := v.Verify(context.Background(), signed); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// HS256 is not accepted when only a JWKS is configured.
+	if _, err := v.Verify(context.Background(), signHS256(t, validClaims())); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for HS256, got %v", err)
+	}
+}
+
+func TestJWTVerifier_Introspection(t *testing.T) {
+	active := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "reader" || pass != "pw" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if active {
+			w.Write([]byte(`{"active":true,"user_id":"u1"}`))
+		} else {
+			w.Write([]byte(`{"active":false}`))
+		}
+	}))
+	defer srv.Close()
+
+	v, _ := NewJWTVerifier(JWTConfig{
+		Secret:       secret,
+		Introspector: &HTTPIntrospector{URL: srv.URL, ClientID: "reader", ClientSecret: "pw", Client: srv.Client()},
+	})
+	token := signHS256(t, validClaims())
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	active = false // revoked
+	if _, err := v.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for a revoked token, got %v", err)
+	}
+}
+
+func TestSession_Authorizes(t *testing.T) {
+	claimNames := []string{"user_id", "profile_id"}
+	user := &Session{AppID: "app", Claims: map[string]string{"user_id": "u1"}}
+	profile := &Session{AppID: "app", Claims: map[string]string{"user_id": "u1", "profile_id": "pA"}}
+
+	cases := []struct {
+		name       string
+		session    *Session
+		appID      string
+		contextKey string
+		want       bool
+	}{
+		{"own user key", user, "app", "u1", true},
+		{"own profile key", user, "app", "u1:pB", true},
+		{"other user", user, "app", "u2", false},
+		{"user id prefix of other user", user, "app", "u10", false},
+		{"other app", user, "other-app", "u1", false},
+		{"profile-scoped session", profile, "app", "u1:pA", true},
+		{"other profile", profile, "app", "u1:pB", false},
+		{"profile session, bare user key", profile, "app", "u1", false},
+		{"no identity claims", &Session{AppID: "app", Claims: map[string]string{}}, "app", "u1", false},
+		{"no app", &Session{Claims: map[string]string{"user_id": "u1"}}, "app", "u1", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.session.Authorizes(tc.appID, claimNames, tc.contextKey); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJWTVerifier_UnavailableIsTransient(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims())
+	tok.Header["kid"] = "k1"
+	signed, _ := tok.SignedString(key)
+
+	v, _ := NewJWTVerifier(JWTConfig{JWKS: jwks.NewSource(down.URL, time.Minute, down.Client())})
+	if _, err := v.Verify(context.Background(), signed); err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Errorf("JWKS unavailable: expected a transient error, got %v", err)
+	}
+
+	v, _ = NewJWTVerifier(JWTConfig{
+		Secret:       secret,
+		Introspector: &HTTPIntrospector{URL: down.URL, Client: down.Client()},
+	})
+	if _, err := v.Verify(context.Background(), signHS256(t, validClaims())); err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Errorf("introspection status 500: expected a transient error, got %v", err)
+	}
+
+	down.Close()
+	if _, err := v.Verify(context.Background(), signHS256(t, validClaims())); err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Errorf("introspection unreachable: expected a transient error, got %v", err)
+	}
+}
+
+func TestJWTVerifier_NumericClaims(t *testing.T) {
+	v, _ := NewJWTVerifier(JWTConfig{Secret: secret})
+	claims := validClaims()
+	claims["user_id"] = 12345678
+	claims["profile_id"] = 2.5
+
+	session, err := v.Verify(context.Background(), signHS256(t, claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Claims["user_id"] != "12345678" || session.Claims["profile_id"] != "2.5" {
+		t.Errorf("claims: got %v", session.Claims)
+	}
+	if !session.Authorizes("payments-app", nil, "12345678") {
+		t.Error("session with a numeric user_id does not authorize its context key")
+	}
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Introspector asks the token issuer whether a token is active and returns
+// its claims. It returns ErrInvalidToken for inactive tokens; transport
+// failures and unexpected responses are returned as plain (transient) errors.
+type Introspector interface {
+	Introspect(ctx context.Context, token string) (map[string]any, error)
+}
+
+// HTTPIntrospector is an OAuth 2.0 token introspection client (RFC 7662).
+type HTTPIntrospector struct {
+	URL          string
+	ClientID     string
+	ClientSecret string
+	Client       *http.Client
+}
+
+func (h *HTTPIntrospector) Introspect(ctx context.Context, token string) (map[string]any, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("introspect: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if h.ClientID != "" {
+		req.SetBasicAuth(h.ClientID, h.ClientSecret)
+	}
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("introspect: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspect: status %d", resp.StatusCode)
+	}
+
+	var claims map[string]any
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("introspect: decode: %w", err)
+	}
+	if active, _ := claims["active"].(bool); !active {
+		return nil, fmt.Errorf("%w: token is not active", ErrInvalidToken)
+	}
+	delete(claims, "active")
+	return claims, nil
+}
+
+// IntrospectionVerifier verifies opaque session tokens with an Introspector.
+type IntrospectionVerifier struct {
+	Introspector Introspector
+}
+
+func (v *IntrospectionVerifier) Verify(ctx context.Context, token string) (*Session, error) {
+	claims, err := v.Introspector.Introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return sessionFromClaims(claims)
+}
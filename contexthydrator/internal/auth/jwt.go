@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourorg/context-hydrator/internal/jwks"
+)
+
+// JWTConfig configures JWTVerifier. At least one of Secret and JWKS is required.
+type JWTConfig struct {
+	// Secret verifies HS256 tokens.
+	Secret []byte
+	// JWKS supplies RS256 verification keys, selected by the token's kid.
+	JWKS *jwks.Source
+	// Issuer and Audience are required to match when set.
+	Issuer   string
+	Audience string
+	// Introspector, if set, is consulted after local verification, e.g. to
+	// reject revoked tokens. Claims it returns override the token's.
+	Introspector Introspector
+}
+
+// JWTVerifier verifies session JWTs signed with HS256 or RS256.
+type JWTVerifier struct {
+	cfg    JWTConfig
+	parser *jwt.Parser
+}
+
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	var methods []string
+	if len(cfg.Secret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.JWKS != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("jwt verifier: a secret or JWKS is required")
+	}
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	return &JWTVerifier{cfg: cfg, parser: jwt.NewParser(opts...)}, nil
+}
+
+// Verify returns ErrInvalidToken for tokens that fail verification. A JWKS
+// that cannot be loaded, or an introspection endpoint that cannot be reached,
+// is reported as a plain (transient) error.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*Session, error) {
+	claims := jwt.MapClaims{}
+	var keyErr error
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		switch t.Method.Alg() {
+		case jwt.SigningMethodHS256.Alg():
+			return v.cfg.Secret, nil
+		case jwt.SigningMethodRS256.Alg():
+			kid, _ := t.Header["kid"].(string)
+			key, err := v.cfg.JWKS.Key(ctx, kid)
+			if err != nil {
+				if !errors.Is(err, jwks.ErrKeyNotFound) {
+					keyErr = err
+				}
+				return nil, err
+			}
+			if _, ok := key.(*rsa.PublicKey); !ok {
+				return nil, fmt.Errorf("key %q is not an RSA key", kid)
+			}
+			return key, nil
+		}
+		return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+	})
+	if keyErr != nil {
+		return nil, fmt.Errorf("jwt verifier: %w", keyErr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if v.cfg.Introspector != nil {
+		introspected, err := v.cfg.Introspector.Introspect(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range introspected {
+			claims[name] = value
+		}
+	}
+	return sessionFromClaims(claims)
+}
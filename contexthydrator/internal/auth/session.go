@@ -0,0 +1,102 @@
+// Package auth verifies the session tokens presented to the context reader
+// and decides which context keys a session may read.
+//
+// Verifiers are pluggable: JWTVerifier checks HS256/RS256 tokens locally
+// (optionally confirming them with an introspection endpoint), and
+// IntrospectionVerifier delegates opaque tokens to the issuer entirely.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, expired,
+// revoked or fail signature, issuer or audience checks. Other verifier errors
+// are treated as transient.
+var ErrInvalidToken = errors.New("invalid session token")
+
+// DefaultContextKeyClaims is used for apps that do not declare how their
+// context keys are composed.
+var DefaultContextKeyClaims = []string{"user_id"}
+
+// Verifier authenticates a session token.
+type Verifier interface {
+	Verify(ctx context.Context, token string) (*Session, error)
+}
+
+// Session is the verified identity behind a session token.
+type Session struct {
+	// AppID is the token's app_id claim. Tokens without one are rejected, so
+	// a session is never valid for every app.
+	AppID string
+	// Claims holds the token's string and numeric claims.
+	Claims map[string]string
+}
+
+// Authorizes reports whether the session may read contextKey of appID. The
+// session's app must be appID.
+//
+// The values of claimNames present in the session, joined with ":" in order,
+// must equal contextKey or be a ":"-delimited prefix of it. With
+// claimNames ["user_id", "profile_id"], a session for user u1 may read "u1"
+// and "u1:pA" — or only "u1:pA" if it also carries profile_id pA.
+func (s *Session) Authorizes(appID string, claimNames []string, contextKey string) bool {
+	if s.AppID != appID {
+		return false
+	}
+	if len(claimNames) == 0 {
+		claimNames = DefaultContextKeyClaims
+	}
+
+	var parts []string
+	for _, name := range claimNames {
+		v := s.Claims[name]
+		if v == "" {
+			break
+		}
+		parts = append(parts, v)
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	prefix := strings.Join(parts, ":")
+	return contextKey == prefix || strings.HasPrefix(contextKey, prefix+":")
+}
+
+// sessionFromClaims builds a Session from decoded token claims, keeping
+// string and numeric values. Numbers are written out in full (1234567, not
+// 1.234567e+06) so numeric IDs compare equal to context keys. Claims without
+// an app_id are rejected with ErrInvalidToken.
+func sessionFromClaims(claims map[string]any) (*Session, error) {
+	s := &Session{Claims: make(map[string]string, len(claims))}
+	for name, v := range claims {
+		switch v := v.(type) {
+		case string:
+			s.Claims[name] = v
+		case float64:
+			s.Claims[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	s.AppID = s.Claims["app_id"]
+	if s.AppID == "" {
+		return nil, fmt.Errorf("%w: no app_id claim", ErrInvalidToken)
+	}
+	return s, nil
+}
+
+type sessionKey struct{}
+
+// WithSession returns a copy of ctx carrying s.
+func WithSession(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+// FromContext returns the session stored by WithSession, if any.
+func FromContext(ctx context.Context) (*Session, bool) {
+	s, ok := ctx.Value(sessionKey{}).(*Session)
+	return s, ok
+}
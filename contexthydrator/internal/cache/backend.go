@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Backend is the key-value storage behind a Store. Missing keys are reported
+// as ErrCacheMiss, and a zero TTL means no expiry.
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	// GetWithTTL also returns the key's remaining TTL, negative for keys
+	// without an expiry.
+	GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error)
+	// GetManyWithTTL is GetWithTTL for several keys at once, with results in
+	// the order of keys. Missing keys have a nil value.
+	GetManyWithTTL(ctx context.Context, keys []string) ([][]byte, []time.Duration, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetNX sets key only if it does not exist, and reports whether it did.
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Del removes key. Deleting a missing key is not an error.
+	Del(ctx context.Context, key string) error
+	// DelIfEqual removes key if it holds value.
+	DelIfEqual(ctx context.Context, key string, value []byte) error
+	// ExpireIfEqual resets key's TTL if it holds value.
+	ExpireIfEqual(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// SAdd and SRem add and remove member of the set at key; SMembers lists
+	// it, empty if key does not exist.
+	SAdd(ctx context.Context, key, member string) error
+	SRem(ctx context.Context, key, member string) error
+	SMembers(ctx context.Context, key string) ([]string, error)
+
+	Publish(ctx context.Context, channel, message string) error
+	// Subscribe passes messages published on channel to handle until ctx is
+	// cancelled or the subscription fails. subscribed is called each time
+	// the subscription is (re)established; messages published while it was
+	// down are lost.
+	Subscribe(ctx context.Context, channel string, subscribed func(), handle func(message string)) error
+
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/yourorg/context-hydrator/internal/keyring"
+)
+
+// Cached resources are stored as written by the upstream (plain JSON) or
+// behind a format marker byte. JSON never starts with a marker byte, so
+// entries written before a format was enabled stay readable. Compression is
+// applied first: an encrypted entry's plaintext may itself be compressed.
+const (
+	// markerEncrypted is followed by the 4-byte big-endian key version, the
+	// GCM nonce and the AES-GCM ciphertext. The cache key is the additional
+	// data, so an entry cannot be replayed under another key.
+	markerEncrypted byte = 0x01
+	// markerGzip is followed by a gzip stream of the JSON, which can be served
+	// as is with Content-Encoding: gzip.
+	markerGzip byte = 0x02
+)
+
+// ErrUndecryptable is returned for encrypted entries whose key is unavailable.
+var ErrUndecryptable = errors.New("cache: entry cannot be decrypted")
+
+// encode prepares a resource of appID for storage under key: compressed if
+// it reaches the store's compression threshold, then encrypted with the app's
+// current key if the store has a key provider and the app has a key.
+func (s *Store) encode(ctx context.Context, appID, key string, data json.RawMessage) ([]byte, error) {
+	payload := s.compress(data)
+	if s.keys == nil {
+		return payload, nil
+	}
+	k, ok, err := s.keys.Current(ctx, appID)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key: %w", err)
+	}
+	if !ok {
+		return payload, nil
+	}
+	aead, err := newAEAD(k)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 5+aead.NonceSize(), 5+aead.NonceSize()+len(payload)+aead.Overhead())
+	out[0] = markerEncrypted
+	binary.BigEndian.PutUint32(out[1:5], k.Version)
+	nonce := out[5:]
+	rand.Read(nonce)
+	return aead.Seal(out, nonce, payload, []byte(key)), nil
+}
+
+// compress gzips data behind markerGzip if it reaches the threshold and
+// compression makes it smaller.
+func (s *Store) compress(data json.RawMessage) []byte {
+	if s.compressAbove <= 0 || len(data) < s.compressAbove {
+		return data
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(markerGzip)
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	if buf.Len() >= len(data) {
+		return data
+	}
+	return buf.Bytes()
+}
+
+// decrypt reverses the encryption step of encode, returning the payload:
+// JSON or a compressed entry. Entries written under an older key version are
+// decrypted with that version.
+func (s *Store) decrypt(ctx context.Context, appID, key string, raw []byte) ([]byte, error) {
+	if len(raw) == 0 || raw[0] != markerEncrypted {
+		return raw, nil
+	}
+	if s.keys == nil {
+		return nil, fmt.Errorf("%w: no key provider", ErrUndecryptable)
+	}
+	if len(raw) < 5 {
+		return nil, fmt.Errorf("%w: truncated header", ErrUndecryptable)
+	}
+	k, err := s.keys.Version(ctx, appID, binary.BigEndian.Uint32(raw[1:5]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUndecryptable, err)
+	}
+	aead, err := newAEAD(k)
+	if err != nil {
+		return nil, err
+	}
+	body := raw[5:]
+	if len(body) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: truncated nonce", ErrUndecryptable)
+	}
+	plain, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUndecryptable, err)
+	}
+	return plain, nil
+}
+
+// decode reverses encode, returning the JSON stored under key.
+func (s *Store) decode(ctx context.Context, appID, key string, raw []byte) (json.RawMessage, error) {
+	payload, err := s.decrypt(ctx, appID, key, raw)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(payload)
+}
+
+// decompress returns the JSON of a payload returned by decrypt.
+func decompress(payload []byte) (json.RawMessage, error) {
+	gz, ok := gzipBody(payload)
+	if !ok {
+		return payload, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, fmt.Errorf("cache: decompress: %w", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("cache: decompress: %w", err)
+	}
+	return data, nil
+}
+
+// gzipBody returns the gzip stream of a compressed payload.
+func gzipBody(payload []byte) ([]byte, bool) {
+	if len(payload) == 0 || payload[0] != markerGzip {
+		return nil, false
+	}
+	return payload[1:], true
+}
+
+func newAEAD(k keyring.Key) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.Material)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key version %d: %w", k.Version, err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/keyring"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+// staticKeys is a keyring.Provider with fixed keys for one app.
+type staticKeys struct {
+	appID   string
+	current uint32
+	keys    map[uint32]keyring.Key
+}
+
+func (p *staticKeys) Current(_ context.Context, appID string) (keyring.Key, bool, error) {
+	if appID != p.appID {
+		return keyring.Key{}, false, nil
+	}
+	return p.keys[p.current], true, nil
+}
+
+func (p *staticKeys) Version(_ context.Context, appID string, version uint32) (keyring.Key, error) {
+	k, ok := p.keys[version]
+	if appID != p.appID || !ok {
+		return keyring.Key{}, keyring.ErrKeyNotFound
+	}
+	return k, nil
+}
+
+func TestStore_Encryption(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	keys := &staticKeys{appID: "app", current: 1, keys: map[uint32]keyring.Key{
+		1: {Version: 1, Material: bytes.Repeat([]byte{1}, keyring.KeySize)},
+	}}
+	store := NewStore(NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})), WithEncryption(keys))
+	profile := json.RawMessage(`{"email":"ada@example.com"}`)
+
+	if err := store.SetResource(ctx, "app", "profile", "u1", profile, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := mr.Get(ResourceCacheKey("app", "profile", "u1"))
+	if bytes.Contains([]byte(raw), []byte("ada@example.com")) || raw[0] != markerEncrypted {
+		t.Fatalf("entry stored in plaintext: %q", raw)
+	}
+	if data, _, err := store.GetResource(ctx, "app", "profile", "u1"); err != nil || !bytes.Equal(data, profile) {
+		t.Fatalf("read: %s, %v", data, err)
+	}
+
+	// Rotation: entries under version 1 still read; new writes use version 2.
+	keys.keys[2] = keyring.Key{Version: 2, Material: bytes.Repeat([]byte{2}, keyring.KeySize)}
+	keys.current = 2
+	if data, _, err := store.GetResource(ctx, "app", "profile", "u1"); err != nil || !bytes.Equal(data, profile) {
+		t.Fatalf("read after rotation: %s, %v", data, err)
+	}
+	store.SetResource(ctx, "app", "profile", "u1", profile, time.Hour)
+	raw, _ = mr.Get(ResourceCacheKey("app", "profile", "u1"))
+	if raw[4] != 2 {
+		t.Errorf("re-written entry: key version byte %d, want 2", raw[4])
+	}
+
+	// The cache key is authenticated: an entry copied to another key fails.
+	mr.Set(ResourceCacheKey("app", "profile", "u2"), raw)
+	if _, _, err := store.GetResource(ctx, "app", "profile", "u2"); !errors.Is(err, ErrUndecryptable) {
+		t.Errorf("copied entry: got %v, want ErrUndecryptable", err)
+	}
+
+	// Apps without a key, and entries written before encryption, are plaintext.
+	store.SetResource(ctx, "other", "profile", "u1", profile, time.Hour)
+	if raw, _ := mr.Get(ResourceCacheKey("other", "profile", "u1")); raw != string(profile) {
+		t.Errorf("app without key: stored %q", raw)
+	}
+	mr.Set(ResourceCacheKey("app", "profile", "u3"), string(profile))
+	if data, _, err := store.GetResource(ctx, "app", "profile", "u3"); err != nil || !bytes.Equal(data, profile) {
+		t.Errorf("plaintext entry: %s, %v", data, err)
+	}
+
+	// Recorded hydration claims are encrypted too; plaintext ones still read.
+	claims := map[string]string{"user_id": "u1", "email": "ada@example.com"}
+	if err := store.StoreClaims(ctx, "app", "u1", claims, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := mr.Get(ClaimsKey("app", "u1")); strings.Contains(raw, "ada@example.com") {
+		t.Errorf("claims stored in plaintext: %q", raw)
+	}
+	if got, err := store.GetClaims(ctx, "app", "u1"); err != nil || got["email"] != "ada@example.com" {
+		t.Errorf("claims: %v, %v", got, err)
+	}
+	mr.Set(ClaimsKey("app", "u2"), `{"user_id":"u2"}`)
+	if got, err := store.GetClaims(ctx, "app", "u2"); err != nil || got["user_id"] != "u2" {
+		t.Errorf("plaintext claims: %v, %v", got, err)
+	}
+
+	// So are token mappings.
+	mapping := &services.HydrationMapping{ContextKey: "u1", Claims: claims}
+	if err := store.StoreMapping(ctx, "app", "tok1", mapping); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := mr.Get(MappingKey("app", "tok1")); strings.Contains(raw, "ada@example.com") {
+		t.Errorf("mapping stored in plaintext: %q", raw)
+	}
+	if got, err := store.ResolveMapping(ctx, "app", "tok1"); err != nil || got.Claims["email"] != "ada@example.com" {
+		t.Errorf("mapping: %+v, %v", got, err)
+	}
+	mr.Set(MappingKey("app", "tok2"), `{"context_key":"u2","claims":{"user_id":"u2"}}`)
+	if got, err := store.ResolveMapping(ctx, "app", "tok2"); err != nil || got.ContextKey != "u2" {
+		t.Errorf("plaintext mapping: %+v, %v", got, err)
+	}
+}
+
+func TestStore_Compression(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	keys := &staticKeys{appID: "app", current: 1, keys: map[uint32]keyring.Key{
+		1: {Version: 1, Material: bytes.Repeat([]byte{1}, keyring.KeySize)},
+	}}
+	store := NewStore(NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})),
+		WithCompression(64), WithEncryption(keys))
+	large := json.RawMessage(`[` + strings.Repeat(`{"project":"alpha"},`, 50) + `{}]`)
+	small := json.RawMessage(`{"id":1}`)
+
+	for _, appID := range []string{"app", "other"} {
+		store.SetResource(ctx, appID, "resources", "u1", large, time.Hour)
+		store.SetResource(ctx, appID, "profile", "u1", small, time.Hour)
+
+		if data, _, err := store.GetResource(ctx, appID, "resources", "u1"); err != nil || !bytes.Equal(data, large) {
+			t.Fatalf("%s: large read: %.40s, %v", appID, data, err)
+		}
+		gz, gzipped, _, err := store.GetResourceGzip(ctx, appID, "resources", "u1")
+		if err != nil || !gzipped {
+			t.Fatalf("%s: large entry not served compressed: %v", appID, err)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(gz))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := io.ReadAll(zr); !bytes.Equal(data, large) {
+			t.Errorf("%s: gzip stream: %.40s", appID, data)
+		}
+
+		data, gzipped, _, err := store.GetResourceGzip(ctx, appID, "profile", "u1")
+		if err != nil || gzipped || !bytes.Equal(data, small) {
+			t.Errorf("%s: small entry below threshold: %s gzipped=%v %v", appID, data, gzipped, err)
+		}
+	}
+	if raw, _ := mr.Get(ResourceCacheKey("other", "resources", "u1")); raw[0] != markerGzip || len(raw) >= len(large) {
+		t.Errorf("large entry stored uncompressed (%d bytes)", len(raw))
+	}
+}
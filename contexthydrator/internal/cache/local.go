@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"container/list"
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LocalConfig bounds the in-process cache tier. Entries live for at most TTL,
+// and never past their Redis expiry.
+type LocalConfig struct {
+	MaxEntries int
+	MaxBytes   int64
+	TTL        time.Duration
+}
+
+// generationBuckets is the number of eviction counters keys are hashed onto.
+const generationBuckets = 256
+
+// localCache is a size- and count-bounded LRU of cached resources. Entries
+// hold the payload as read from Redis, decrypted but still compressed.
+//
+// An entry read from Redis is only added if no eviction hit its generation
+// bucket while the read was in flight, so a read racing a rewrite never
+// caches the old value.
+type localCache struct {
+	cfg  LocalConfig
+	seed maphash.Seed
+	gens [generationBuckets]atomic.Uint64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is most recently used
+	bytes   int64
+}
+
+type localEntry struct {
+	key         string
+	data        []byte
+	expires     time.Time // local expiry, capped at redisExpiry
+	redisExpiry time.Time // zero when the Redis key has no expiry
+}
+
+func (e *localEntry) size() int64 { return int64(len(e.key) + len(e.data)) }
+
+func newLocalCache(cfg LocalConfig) *localCache {
+	return &localCache{
+		cfg:     cfg,
+		seed:    maphash.MakeSeed(),
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// generation returns the eviction counter of key's bucket, to be passed to add.
+func (c *localCache) generation(key string) uint64 {
+	if c == nil {
+		return 0
+	}
+	return c.bucket(key).Load()
+}
+
+func (c *localCache) bucket(key string) *atomic.Uint64 {
+	return &c.gens[maphash.String(c.seed, key)%generationBuckets]
+}
+
+// get returns a live entry and its remaining Redis TTL (negative without
+// an expiry).
+func (c *localCache) get(key string) ([]byte, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, 0, false
+	}
+	e := el.Value.(*localEntry)
+	now := time.Now()
+	if !now.Before(e.expires) {
+		c.remove(el)
+		return nil, 0, false
+	}
+	c.lru.MoveToFront(el)
+	if e.redisExpiry.IsZero() {
+		return e.data, -1, true
+	}
+	return e.data, e.redisExpiry.Sub(now), true
+}
+
+// add caches a value read from Redis with the given remaining TTL, unless key
+// was evicted since gen was taken.
+func (c *localCache) add(key string, data []byte, redisTTL time.Duration, gen uint64) {
+	if c == nil || int64(len(key)+len(data)) > c.cfg.MaxBytes {
+		return
+	}
+	now := time.Now()
+	e := &localEntry{key: key, data: data, expires: now.Add(c.cfg.TTL)}
+	if redisTTL >= 0 {
+		e.redisExpiry = now.Add(redisTTL)
+		if e.redisExpiry.Before(e.expires) {
+			e.expires = e.redisExpiry
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.bucket(key).Load() != gen {
+		return
+	}
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	c.bytes += e.size()
+	for len(c.entries) > c.cfg.MaxEntries || c.bytes > c.cfg.MaxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// evict drops key and invalidates reads of it still in flight.
+func (c *localCache) evict(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bucket(key).Add(1)
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// purge drops every entry, after evictions may have been missed.
+func (c *localCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.gens {
+		c.gens[i].Add(1)
+	}
+	clear(c.entries)
+	c.lru.Init()
+	c.bytes = 0
+}
+
+func (c *localCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*localEntry)
+	delete(c.entries, e.key)
+	c.bytes -= e.size()
+}
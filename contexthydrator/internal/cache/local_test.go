@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+)
+
+func TestLocalCache_Bounds(t *testing.T) {
+	c := newLocalCache(LocalConfig{MaxEntries: 2, MaxBytes: 20, TTL: time.Minute})
+
+	c.add("a", json.RawMessage(`1`), time.Hour, c.generation("a"))
+	c.add("b", json.RawMessage(`2`), time.Hour, c.generation("b"))
+	c.get("a") // a is now more recent than b
+	c.add("c", json.RawMessage(`3`), time.Hour, c.generation("c"))
+
+	if _, _, ok := c.get("b"); ok {
+		t.Error("least recently used entry was not evicted by count")
+	}
+	if _, _, ok := c.get("a"); !ok {
+		t.Error("recently used entry was evicted")
+	}
+
+	c.add("d", json.RawMessage(`"0123456789abcdefghijklmn"`), time.Hour, c.generation("d"))
+	if _, _, ok := c.get("d"); ok {
+		t.Error("entry larger than MaxBytes was cached")
+	}
+	c.add("e", json.RawMessage(`"0123456789abcdef"`), time.Hour, c.generation("e"))
+	if c.bytes > 20 || len(c.entries) != 1 {
+		t.Errorf("byte bound: %d bytes in %d entries", c.bytes, len(c.entries))
+	}
+}
+
+func TestLocalCache_TTLCappedAtRedis(t *testing.T) {
+	c := newLocalCache(LocalConfig{MaxEntries: 10, MaxBytes: 1 << 10, TTL: time.Hour})
+
+	c.add("k", json.RawMessage(`1`), 20*time.Millisecond, c.generation("k"))
+	if _, ttl, ok := c.get("k"); !ok || ttl > 20*time.Millisecond {
+		t.Fatalf("got ok=%v ttl=%s, want the Redis TTL", ok, ttl)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, _, ok := c.get("k"); ok {
+		t.Error("entry outlived its Redis TTL")
+	}
+}
+
+func TestLocalCache_EvictedDuringRead(t *testing.T) {
+	c := newLocalCache(LocalConfig{MaxEntries: 10, MaxBytes: 1 << 10, TTL: time.Hour})
+
+	gen := c.generation("k")
+	c.evict("k") // rewritten while the Redis read was in flight
+	c.add("k", json.RawMessage(`"old"`), time.Hour, gen)
+	if _, _, ok := c.get("k"); ok {
+		t.Error("value read before an eviction was cached")
+	}
+}
+
+func TestStore_LocalEvictionAcrossInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := LocalConfig{MaxEntries: 10, MaxBytes: 1 << 10, TTL: time.Hour}
+	reader := NewStore(NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})), WithLocalCache(cfg))
+	writer := NewStore(NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reader.RunEviction(ctx)
+
+	for mr.PubSubNumSub(redisc.KeyCacheEvict)[redisc.KeyCacheEvict] == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	writer.SetResource(ctx, "app", "profile", "u1", json.RawMessage(`"v1"`), time.Hour)
+	if data, _, err := reader.GetResource(ctx, "app", "profile", "u1"); err != nil || string(data) != `"v1"` {
+		t.Fatalf("first read: %s, %v", data, err)
+	}
+	mr.Set(ResourceCacheKey("app", "profile", "u1"), `"unseen"`) // bypasses the store: the local copy stays
+	if data, _, _ := reader.GetResource(ctx, "app", "profile", "u1"); string(data) != `"v1"` {
+		t.Fatalf("expected a local hit, got %s", data)
+	}
+
+	writer.SetResource(ctx, "app", "profile", "u1", json.RawMessage(`"v2"`), time.Hour)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		data, _, _ := reader.GetResource(ctx, "app", "profile", "u1")
+		if string(data) == `"v2"` {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("local copy not evicted, still %s", data)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	writer.DeleteResource(ctx, "app", "profile", "u1")
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		if _, _, err := reader.GetResource(ctx, "app", "profile", "u1"); err == ErrCacheMiss {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("deleted entry still served locally")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStore_GetResources(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	cfg := LocalConfig{MaxEntries: 10, MaxBytes: 1 << 10, TTL: time.Hour}
+	store := NewStore(NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})), WithLocalCache(cfg))
+
+	store.SetResource(ctx, "app", "profile", "u1", json.RawMessage(`"p"`), time.Hour)
+	store.SetResource(ctx, "app", "limits", "u1", json.RawMessage(`"l"`), time.Minute)
+	mr.Set(ResourceCacheKey("app", "permissions", "u1"), "\x01\x00") // truncated encrypted entry
+	store.GetResource(ctx, "app", "profile", "u1")                   // profile is now in the local tier
+
+	reads, err := store.GetResources(ctx, "app", "u1", []string{"limits", "preferences", "profile", "permissions"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reads[0].Data) != `"l"` || reads[0].TTL != time.Minute || reads[0].Err != nil {
+		t.Errorf("limits: %+v", reads[0])
+	}
+	if reads[1].Err != ErrCacheMiss {
+		t.Errorf("preferences: got %v, want ErrCacheMiss", reads[1].Err)
+	}
+	if string(reads[2].Data) != `"p"` || reads[2].TTL <= 0 {
+		t.Errorf("profile from the local tier: %+v", reads[2])
+	}
+	if !errors.Is(reads[3].Err, ErrUndecryptable) {
+		t.Errorf("permissions: got %v, want ErrUndecryptable", reads[3].Err)
+	}
+
+	mr.Close()
+	if _, err := store.GetResources(ctx, "app", "u1", []string{"preferences"}); err == nil {
+		t.Error("expected an error with Redis down")
+	}
+}
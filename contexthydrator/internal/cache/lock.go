@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// AcquireLock takes the hydration lock for contextKey with SET NX. It returns
+// false if another hydration holds it or finished within its suppression window.
+func (s *Store) AcquireLock(ctx context.Context, appID, contextKey, token string, ttl time.Duration) (bool, error) {
+	return s.backend.SetNX(ctx, LockKey(appID, contextKey), []byte(token), ttl)
+}
+
+// ReleaseLock deletes the lock if token still holds it.
+func (s *Store) ReleaseLock(ctx context.Context, appID, contextKey, token string) error {
+	return s.backend.DelIfEqual(ctx, LockKey(appID, contextKey), []byte(token))
+}
+
+// HoldLock resets the lock's expiry to ttl if token still holds it, keeping
+// further hydrations of contextKey suppressed until it lapses.
+func (s *Store) HoldLock(ctx context.Context, appID, contextKey, token string, ttl time.Duration) error {
+	return s.backend.ExpireIfEqual(ctx, LockKey(appID, contextKey), []byte(token), ttl)
+}
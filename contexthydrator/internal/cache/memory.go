@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// memorySweepEvery is the number of writes between sweeps of expired entries.
+const memorySweepEvery = 1024
+
+// MemoryBackend keeps entries in process memory, for unit tests and local
+// development without Redis. Expired entries are dropped when read and swept
+// periodically on writes. Pub/Sub messages reach subscribers of the same
+// backend only. Safe for concurrent use.
+type MemoryBackend struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	writes  int
+	sets    map[string]map[string]struct{}
+	subs    map[string]map[*memorySub]struct{}
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time // zero without an expiry
+}
+
+type memorySub struct {
+	handle func(string)
+}
+
+func (e memoryEntry) live(now time.Time) bool {
+	return e.expires.IsZero() || now.Before(e.expires)
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		entries: make(map[string]memoryEntry),
+		sets:    make(map[string]map[string]struct{}),
+		subs:    make(map[string]map[*memorySub]struct{}),
+	}
+}
+
+// lookup returns the live entry for key, dropping it if expired. Callers hold mu.
+func (b *MemoryBackend) lookup(key string, now time.Time) (memoryEntry, bool) {
+	e, ok := b.entries[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if !e.live(now) {
+		delete(b.entries, key)
+		return memoryEntry{}, false
+	}
+	return e, true
+}
+
+// store writes an entry. Callers hold mu.
+func (b *MemoryBackend) store(key string, value []byte, ttl time.Duration, now time.Time) {
+	e := memoryEntry{value: bytes.Clone(value)}
+	if ttl > 0 {
+		e.expires = now.Add(ttl)
+	}
+	b.entries[key] = e
+
+	b.writes++
+	if b.writes%memorySweepEvery == 0 {
+		for k, e := range b.entries {
+			if !e.live(now) {
+				delete(b.entries, k)
+			}
+		}
+	}
+}
+
+func (b *MemoryBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	v, _, err := b.GetWithTTL(ctx, key)
+	return v, err
+}
+
+func (b *MemoryBackend) GetWithTTL(_ context.Context, key string) ([]byte, time.Duration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	e, ok := b.lookup(key, now)
+	if !ok {
+		return nil, 0, ErrCacheMiss
+	}
+	if e.expires.IsZero() {
+		return bytes.Clone(e.value), -1, nil
+	}
+	return bytes.Clone(e.value), e.expires.Sub(now), nil
+}
+
+func (b *MemoryBackend) GetManyWithTTL(ctx context.Context, keys []string) ([][]byte, []time.Duration, error) {
+	values := make([][]byte, len(keys))
+	ttls := make([]time.Duration, len(keys))
+	for i, key := range keys {
+		v, ttl, err := b.GetWithTTL(ctx, key)
+		if err == nil {
+			values[i], ttls[i] = v, ttl
+		}
+	}
+	return values, ttls, nil
+}
+
+func (b *MemoryBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.store(key, value, ttl, time.Now())
+	return nil
+}
+
+func (b *MemoryBackend) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if _, ok := b.lookup(key, now); ok {
+		return false, nil
+	}
+	b.store(key, value, ttl, now)
+	return true, nil
+}
+
+func (b *MemoryBackend) Del(_ context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.entries, key)
+	delete(b.sets, key)
+	return nil
+}
+
+func (b *MemoryBackend) DelIfEqual(_ context.Context, key string, value []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if e, ok := b.lookup(key, time.Now()); ok && bytes.Equal(e.value, value) {
+		delete(b.entries, key)
+	}
+	return nil
+}
+
+func (b *MemoryBackend) ExpireIfEqual(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if e, ok := b.lookup(key, now); ok && bytes.Equal(e.value, value) {
+		e.expires = now.Add(ttl)
+		b.entries[key] = e
+	}
+	return nil
+}
+
+func (b *MemoryBackend) SAdd(_ context.Context, key, member string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sets[key] == nil {
+		b.sets[key] = make(map[string]struct{})
+	}
+	b.sets[key][member] = struct{}{}
+	return nil
+}
+
+func (b *MemoryBackend) SRem(_ context.Context, key, member string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sets[key], member)
+	return nil
+}
+
+func (b *MemoryBackend) SMembers(_ context.Context, key string) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	members := make([]string, 0, len(b.sets[key]))
+	for m := range b.sets[key] {
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+// Publish delivers message to the channel's subscribers before returning.
+func (b *MemoryBackend) Publish(_ context.Context, channel, message string) error {
+	b.mu.Lock()
+	subs := make([]*memorySub, 0, len(b.subs[channel]))
+	for sub := range b.subs[channel] {
+		subs = append(subs, sub)
+	}
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.handle(message)
+	}
+	return nil
+}
+
+// Subscribe blocks until ctx is cancelled; an in-memory subscription never fails.
+func (b *MemoryBackend) Subscribe(ctx context.Context, channel string, subscribed func(), handle func(string)) error {
+	sub := &memorySub{handle: handle}
+	b.mu.Lock()
+	if b.subs[channel] == nil {
+		b.subs[channel] = make(map[*memorySub]struct{})
+	}
+	b.subs[channel][sub] = struct{}{}
+	b.mu.Unlock()
+	subscribed()
+
+	<-ctx.Done()
+
+	b.mu.Lock()
+	delete(b.subs[channel], sub)
+	b.mu.Unlock()
+	return ctx.Err()
+}
+
+func (b *MemoryBackend) Ping(context.Context) error {
+	return nil
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+)
+
+func TestMemoryBackend_TTL(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend()
+
+	b.Set(ctx, "short", []byte("1"), 20*time.Millisecond)
+	b.Set(ctx, "forever", []byte("2"), 0)
+	if _, ttl, err := b.GetWithTTL(ctx, "short"); err != nil || ttl <= 0 || ttl > 20*time.Millisecond {
+		t.Fatalf("short: ttl %s, %v", ttl, err)
+	}
+	if _, ttl, _ := b.GetWithTTL(ctx, "forever"); ttl >= 0 {
+		t.Errorf("key without expiry: got ttl %s, want negative", ttl)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := b.Get(ctx, "short"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("expired key: got %v, want ErrCacheMiss", err)
+	}
+	if ok, _ := b.SetNX(ctx, "short", []byte("3"), time.Minute); !ok {
+		t.Error("SetNX failed on an expired key")
+	}
+	if ok, _ := b.SetNX(ctx, "short", []byte("4"), time.Minute); ok {
+		t.Error("SetNX overwrote a live key")
+	}
+}
+
+func TestStore_MemoryBackend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := NewMemoryBackend()
+	cfg := LocalConfig{MaxEntries: 10, MaxBytes: 1 << 10, TTL: time.Hour}
+	reader := NewStore(b, WithLocalCache(cfg))
+	writer := NewStore(b)
+	go reader.RunEviction(ctx)
+	for {
+		b.mu.Lock()
+		n := len(b.subs[redisc.KeyCacheEvict])
+		b.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	writer.SetResource(ctx, "app", "profile", "u1", json.RawMessage(`"v1"`), time.Hour)
+	if data, ttl, err := reader.GetResource(ctx, "app", "profile", "u1"); err != nil || string(data) != `"v1"` || ttl <= 0 {
+		t.Fatalf("read: %s %s %v", data, ttl, err)
+	}
+	// Publish is synchronous in memory: the local copy is gone on return.
+	writer.SetResource(ctx, "app", "profile", "u1", json.RawMessage(`"v2"`), time.Hour)
+	if data, _, _ := reader.GetResource(ctx, "app", "profile", "u1"); string(data) != `"v2"` {
+		t.Errorf("after rewrite: got %s, want \"v2\"", data)
+	}
+
+	if ok, _ := writer.AcquireLock(ctx, "app", "u1", "a", time.Minute); !ok {
+		t.Fatal("lock not acquired")
+	}
+	writer.ReleaseLock(ctx, "app", "u1", "b") // not the holder
+	if ok, _ := writer.AcquireLock(ctx, "app", "u1", "b", time.Minute); ok {
+		t.Error("lock released by a non-holder")
+	}
+	writer.ReleaseLock(ctx, "app", "u1", "a")
+	if ok, _ := writer.AcquireLock(ctx, "app", "u1", "b", time.Minute); !ok {
+		t.Error("lock not released by its holder")
+	}
+}
+
+// unpublishable is a backend whose Pub/Sub is down.
+type unpublishable struct{ *MemoryBackend }
+
+func (unpublishable) Publish(context.Context, string, string) error {
+	return errors.New("publish: connection refused")
+}
+
+func TestStore_PublishFailure(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(unpublishable{NewMemoryBackend()}, WithLogger(slog.New(slog.DiscardHandler)))
+
+	if err := store.SetResource(ctx, "app", "profile", "u1", json.RawMessage(`"v1"`), time.Hour); err != nil {
+		t.Fatalf("SetResource: got %v, want nil once the write succeeded", err)
+	}
+	if data, _, err := store.GetResource(ctx, "app", "profile", "u1"); err != nil || string(data) != `"v1"` {
+		t.Errorf("read: %s, %v", data, err)
+	}
+
+	if err := store.DeleteResource(ctx, "app", "profile", "u1"); err != nil {
+		t.Fatalf("DeleteResource: got %v, want nil once the delete succeeded", err)
+	}
+	if _, _, err := store.GetResource(ctx, "app", "profile", "u1"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("after delete: got %v, want ErrCacheMiss", err)
+	}
+}
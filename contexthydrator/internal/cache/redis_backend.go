@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Lock owner checks: only the instance holding a value may delete or extend it.
+var (
+	delIfEqualScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	expireIfEqualScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// RedisBackend stores entries in Redis through a redis.UniversalClient: a
+// single node, a Sentinel-managed primary or a Cluster. On a Cluster, keys
+// are stored with a hash tag so one contextKey's resources share a slot;
+// elsewhere they are stored as given.
+type RedisBackend struct {
+	client  redis.UniversalClient
+	cluster bool
+}
+
+func NewRedisBackend(client redis.UniversalClient) *RedisBackend {
+	_, cluster := client.(*redis.ClusterClient)
+	return &RedisBackend{client: client, cluster: cluster}
+}
+
+// key returns the Redis key an entry is stored under. On a Cluster the part
+// after the second ':' becomes a hash tag: appID:resource:{contextKey} for
+// resource keys. Keys with fewer segments are stored as given.
+func (b *RedisBackend) key(key string) string {
+	if !b.cluster {
+		return key
+	}
+	return clusterKey(key)
+}
+
+func clusterKey(key string) string {
+	i := strings.IndexByte(key, ':')
+	if i < 0 {
+		return key
+	}
+	j := strings.IndexByte(key[i+1:], ':')
+	if j < 0 {
+		return key
+	}
+	j += i + 1
+	return key[:j+1] + "{" + key[j+1:] + "}"
+}
+
+func (b *RedisBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	v, err := b.client.Get(ctx, b.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis get: %w", err)
+	}
+	return v, nil
+}
+
+// GetWithTTL reads the value and its TTL in one round trip.
+func (b *RedisBackend) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	var get *redis.StringCmd
+	var pttl *redis.DurationCmd
+	_, err := b.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, b.key(key))
+		pttl = pipe.PTTL(ctx, b.key(key))
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		return nil, 0, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("redis get: %w", err)
+	}
+	return []byte(get.Val()), pttl.Val(), nil
+}
+
+// GetManyWithTTL reads the values with MGET and their TTLs in the same
+// round trip. On a Cluster the keys must share a hash tag, as the resources
+// of one contextKey do.
+func (b *RedisBackend) GetManyWithTTL(ctx context.Context, keys []string) ([][]byte, []time.Duration, error) {
+	stored := make([]string, len(keys))
+	for i, key := range keys {
+		stored[i] = b.key(key)
+	}
+	var mget *redis.SliceCmd
+	pttls := make([]*redis.DurationCmd, len(keys))
+	_, err := b.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		mget = pipe.MGet(ctx, stored...)
+		for i, key := range stored {
+			pttls[i] = pipe.PTTL(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("redis mget: %w", err)
+	}
+	values := make([][]byte, len(keys))
+	ttls := make([]time.Duration, len(keys))
+	for i, v := range mget.Val() {
+		if s, ok := v.(string); ok {
+			values[i] = []byte(s)
+			ttls[i] = pttls[i].Val()
+		}
+	}
+	return values, ttls, nil
+}
+
+func (b *RedisBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return b.client.Set(ctx, b.key(key), value, ttl).Err()
+}
+
+func (b *RedisBackend) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return b.client.SetNX(ctx, b.key(key), value, ttl).Result()
+}
+
+func (b *RedisBackend) Del(ctx context.Context, key string) error {
+	return b.client.Del(ctx, b.key(key)).Err()
+}
+
+func (b *RedisBackend) DelIfEqual(ctx context.Context, key string, value []byte) error {
+	return delIfEqualScript.Run(ctx, b.client, []string{b.key(key)}, value).Err()
+}
+
+func (b *RedisBackend) ExpireIfEqual(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return expireIfEqualScript.Run(ctx, b.client, []string{b.key(key)}, value, ttl.Milliseconds()).Err()
+}
+
+func (b *RedisBackend) SAdd(ctx context.Context, key, member string) error {
+	return b.client.SAdd(ctx, b.key(key), member).Err()
+}
+
+func (b *RedisBackend) SRem(ctx context.Context, key, member string) error {
+	return b.client.SRem(ctx, b.key(key), member).Err()
+}
+
+func (b *RedisBackend) SMembers(ctx context.Context, key string) ([]string, error) {
+	return b.client.SMembers(ctx, b.key(key)).Result()
+}
+
+func (b *RedisBackend) Publish(ctx context.Context, channel, message string) error {
+	return b.client.Publish(ctx, channel, message).Err()
+}
+
+func (b *RedisBackend) Subscribe(ctx context.Context, channel string, subscribed func(), handle func(string)) error {
+	pubsub := b.client.Subscribe(ctx, channel)
+	defer pubsub.Close()
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			return err
+		}
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			subscribed()
+		case *redis.Message:
+			handle(m.Payload)
+		}
+	}
+}
+
+func (b *RedisBackend) Ping(ctx context.Context) error {
+	return b.client.Ping(ctx).Err()
+}
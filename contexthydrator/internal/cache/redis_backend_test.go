@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestClusterKey(t *testing.T) {
+	cases := map[string]string{
+		ResourceCacheKey("payments-app", "profile", "u1:acc-99"): "payments-app:profile:{u1:acc-99}",
+		ClaimsKey("payments-app", "u1"):                          "hyd:claims:{payments-app:u1}",
+		JobStatusKey("job-1"):                                    "hyd:jobstatus:{job-1}",
+		"hyd:jobs":                                               "hyd:jobs",
+	}
+	for key, want := range cases {
+		if got := clusterKey(key); got != want {
+			t.Errorf("clusterKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestRedisBackend_KeysUntaggedOutsideCluster(t *testing.T) {
+	b := NewRedisBackend(redis.NewClient(&redis.Options{Addr: "localhost:0"}))
+	if key := ResourceCacheKey("app", "profile", "u1"); b.key(key) != key {
+		t.Errorf("key %q stored as %q", key, b.key(key))
+	}
+	if !NewRedisBackend(redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{"localhost:0"}})).cluster {
+		t.Error("cluster client not detected")
+	}
+}
@@ -5,61 +5,320 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
-	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/keyring"
+	"github.com/yourorg/context-hydrator/internal/metrics"
 	redisc "github.com/yourorg/context-hydrator/internal/redis"
 	"github.com/yourorg/context-hydrator/internal/services"
 )
 
 var ErrCacheMiss = errors.New("cache miss")
 
+// Store reads and writes cached resources, hydration token mappings and
+// related state through a Backend, adding the local tier, compression and
+// encryption where configured.
 type Store struct {
-	client *redis.Client
+	backend Backend
+	local   *localCache
+	keys    keyring.Provider
+	// compressAbove is the size from which resources are gzipped; 0 disables.
+	compressAbove int
+	log           *slog.Logger
 }
 
-func NewStore(client *redis.Client) *Store {
-	return &Store{client: client}
+// Option configures optional Store features.
+type Option func(*Store)
+
+// WithLocalCache serves cached resources (GetResource) from an in-process LRU
+// tier in front of Redis. Run RunEviction so entries rewritten or deleted by
+// other instances are dropped. A zero MaxEntries disables the tier.
+func WithLocalCache(cfg LocalConfig) Option {
+	return func(s *Store) {
+		if cfg.MaxEntries > 0 && cfg.MaxBytes > 0 && cfg.TTL > 0 {
+			s.local = newLocalCache(cfg)
+		}
+	}
+}
+
+// WithEncryption encrypts the cached resources of apps that have a key in p
+// with AES-GCM. Entries record their key version, so keys can rotate without
+// a flush: entries under an older version are still decrypted with it and
+// re-encrypted with the current key when next written. A nil p disables
+// encryption.
+func WithEncryption(p keyring.Provider) Option {
+	return func(s *Store) { s.keys = p }
+}
+
+// WithCompression gzips cached resources of at least threshold bytes. Entries
+// are marked, so uncompressed entries stay readable and GetResourceGzip can
+// hand compressed ones to clients as is. A threshold of 0 disables it.
+func WithCompression(threshold int) Option {
+	return func(s *Store) { s.compressAbove = threshold }
+}
+
+// WithLogger sets the logger for failures the Store recovers from, such as
+// an eviction that could not be broadcast. It defaults to slog.Default().
+func WithLogger(log *slog.Logger) Option {
+	return func(s *Store) { s.log = log }
+}
+
+func NewStore(backend Backend, opts ...Option) *Store {
+	s := &Store{backend: backend, log: slog.Default()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Store) Set(ctx context.Context, key string, data json.RawMessage, ttl time.Duration) error {
-	return s.client.Set(ctx, key, []byte(data), ttl).Err()
+	return s.backend.Set(ctx, key, data, ttl)
+}
+
+// SetNX sets key only if it does not exist, and reports whether it did.
+func (s *Store) SetNX(ctx context.Context, key string, data json.RawMessage, ttl time.Duration) (bool, error) {
+	return s.backend.SetNX(ctx, key, data, ttl)
 }
 
 func (s *Store) Get(ctx context.Context, key string) (json.RawMessage, error) {
-	b, err := s.client.Get(ctx, key).Bytes()
-	if errors.Is(err, redis.Nil) {
-		return nil, ErrCacheMiss
+	return s.backend.Get(ctx, key)
+}
+
+// SAdd adds member to the set at key.
+func (s *Store) SAdd(ctx context.Context, key, member string) error {
+	return s.backend.SAdd(ctx, key, member)
+}
+
+// SRem removes member from the set at key.
+func (s *Store) SRem(ctx context.Context, key, member string) error {
+	return s.backend.SRem(ctx, key, member)
+}
+
+// SMembers lists the set at key.
+func (s *Store) SMembers(ctx context.Context, key string) ([]string, error) {
+	return s.backend.SMembers(ctx, key)
+}
+
+// GetResource returns a cached resource and its remaining TTL, from the local
+// tier if enabled or else from Redis in one round trip. The TTL is negative
+// for keys without an expiry.
+func (s *Store) GetResource(ctx context.Context, appID, resource, contextKey string) (json.RawMessage, time.Duration, error) {
+	payload, ttl, err := s.getPayload(ctx, appID, ResourceCacheKey(appID, resource, contextKey))
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := decompress(payload)
+	if err != nil {
+		return nil, 0, err
 	}
+	return data, ttl, nil
+}
+
+// GetResourceGzip is GetResource for clients that accept gzip: entries stored
+// compressed are returned as their gzip stream without being decompressed,
+// with gzipped set. Other entries are returned as JSON.
+func (s *Store) GetResourceGzip(ctx context.Context, appID, resource, contextKey string) (data []byte, gzipped bool, ttl time.Duration, err error) {
+	payload, ttl, err := s.getPayload(ctx, appID, ResourceCacheKey(appID, resource, contextKey))
 	if err != nil {
-		return nil, fmt.Errorf("redis get: %w", err)
+		return nil, false, 0, err
+	}
+	if gz, ok := gzipBody(payload); ok {
+		return gz, true, ttl, nil
+	}
+	return payload, false, ttl, nil
+}
+
+// ResourceRead is one result of GetResources.
+type ResourceRead struct {
+	Data json.RawMessage
+	// TTL is the entry's remaining TTL, negative for keys without an expiry.
+	TTL time.Duration
+	// Err is ErrCacheMiss for missing entries, or why an entry could not be
+	// decoded.
+	Err error
+}
+
+// GetResources reads several resources of contextKey: from the local tier if
+// enabled, and the rest from the backend in one round trip. Results are in
+// the order of resources. The error is set only if the backend read failed.
+func (s *Store) GetResources(ctx context.Context, appID, contextKey string, resources []string) ([]ResourceRead, error) {
+	reads := make([]ResourceRead, len(resources))
+	payloads := make([][]byte, len(resources))
+
+	var remote []int
+	for i, resource := range resources {
+		key := ResourceCacheKey(appID, resource, contextKey)
+		if s.local != nil {
+			if data, ttl, ok := s.local.get(key); ok {
+				metrics.CacheTierReads.WithLabelValues(metrics.TierLocal, "hit").Inc()
+				payloads[i], reads[i].TTL = data, ttl
+				continue
+			}
+			metrics.CacheTierReads.WithLabelValues(metrics.TierLocal, "miss").Inc()
+		}
+		remote = append(remote, i)
+	}
+
+	if len(remote) > 0 {
+		keys := make([]string, len(remote))
+		gens := make([]uint64, len(remote))
+		for j, i := range remote {
+			keys[j] = ResourceCacheKey(appID, resources[i], contextKey)
+			gens[j] = s.local.generation(keys[j])
+		}
+		values, ttls, err := s.backend.GetManyWithTTL(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range remote {
+			if values[j] == nil {
+				metrics.CacheTierReads.WithLabelValues(metrics.TierRedis, "miss").Inc()
+				reads[i].Err = ErrCacheMiss
+				continue
+			}
+			metrics.CacheTierReads.WithLabelValues(metrics.TierRedis, "hit").Inc()
+			payload, err := s.decrypt(ctx, appID, keys[j], values[j])
+			if err != nil {
+				reads[i].Err = err
+				continue
+			}
+			s.local.add(keys[j], payload, ttls[j], gens[j])
+			payloads[i], reads[i].TTL = payload, ttls[j]
+		}
+	}
+
+	for i := range reads {
+		if reads[i].Err == nil {
+			reads[i].Data, reads[i].Err = decompress(payloads[i])
+		}
+	}
+	return reads, nil
+}
+
+// getPayload reads a resource entry, decrypted but not decompressed.
+func (s *Store) getPayload(ctx context.Context, appID, key string) ([]byte, time.Duration, error) {
+	if s.local != nil {
+		if data, ttl, ok := s.local.get(key); ok {
+			metrics.CacheTierReads.WithLabelValues(metrics.TierLocal, "hit").Inc()
+			return data, ttl, nil
+		}
+		metrics.CacheTierReads.WithLabelValues(metrics.TierLocal, "miss").Inc()
+	}
+	gen := s.local.generation(key)
+
+	raw, ttl, err := s.backend.GetWithTTL(ctx, key)
+	if errors.Is(err, ErrCacheMiss) {
+		metrics.CacheTierReads.WithLabelValues(metrics.TierRedis, "miss").Inc()
+		return nil, 0, err
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	metrics.CacheTierReads.WithLabelValues(metrics.TierRedis, "hit").Inc()
+	payload, err := s.decrypt(ctx, appID, key, raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	s.local.add(key, payload, ttl, gen)
+	return payload, ttl, nil
+}
+
+// SetResource writes a cached resource and tells every instance to drop its
+// local copy. Once the write succeeds, a failed broadcast is only logged:
+// other instances' local copies then expire by their TTL.
+func (s *Store) SetResource(ctx context.Context, appID, resource, contextKey string, data json.RawMessage, ttl time.Duration) error {
+	key := ResourceCacheKey(appID, resource, contextKey)
+	value, err := s.encode(ctx, appID, key, data)
+	if err != nil {
+		return err
+	}
+	s.local.evict(key)
+	if err := s.backend.Set(ctx, key, value, ttl); err != nil {
+		return err
 	}
-	return json.RawMessage(b), nil
+	if err := s.backend.Publish(ctx, redisc.KeyCacheEvict, key); err != nil {
+		s.log.WarnContext(ctx, "cache eviction broadcast failed", "key", key, "error", err)
+	}
+	return nil
+}
+
+// DeleteResource removes a cached resource on every instance. Deleting a
+// missing key is not an error. As in SetResource, a failed broadcast after
+// the delete is only logged.
+func (s *Store) DeleteResource(ctx context.Context, appID, resource, contextKey string) error {
+	key := ResourceCacheKey(appID, resource, contextKey)
+	s.local.evict(key)
+	if err := s.backend.Del(ctx, key); err != nil {
+		return err
+	}
+	if err := s.backend.Publish(ctx, redisc.KeyCacheEvict, key); err != nil {
+		s.log.WarnContext(ctx, "cache eviction broadcast failed", "key", key, "error", err)
+	}
+	return nil
+}
+
+// Delete removes a key. Deleting a missing key is not an error.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	return s.backend.Del(ctx, key)
 }
 
+// RunEviction drops local entries rewritten or deleted by any instance, until
+// ctx is cancelled. Without a local tier it returns at once. After a
+// reconnect the whole tier is dropped, since evictions may have been missed.
+func (s *Store) RunEviction(ctx context.Context) {
+	if s.local == nil {
+		return
+	}
+	for {
+		s.backend.Subscribe(ctx, redisc.KeyCacheEvict, s.local.purge, s.local.evict)
+		if ctx.Err() != nil {
+			return
+		}
+		// The backend is unreachable: serve nothing that might be stale, and
+		// retry the subscription shortly.
+		s.local.purge()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(evictionRetry):
+		}
+	}
+}
+
+// evictionRetry is the pause before resubscribing after a Pub/Sub error.
+const evictionRetry = time.Second
+
 func (s *Store) Ping(ctx context.Context) error {
-	return s.client.Ping(ctx).Err()
+	return s.backend.Ping(ctx)
 }
 
 // StoreMapping persists the hyd_token → {contextKey, claims} mapping in Redis.
-// Called at login time by the issuing application (via SDK).
+// Called at login time by the issuing application (via SDK). It is encoded
+// like a resource, so encryption at rest covers it.
 func (s *Store) StoreMapping(ctx context.Context, appID, hydToken string, mapping *services.HydrationMapping) error {
 	b, err := json.Marshal(mapping)
 	if err != nil {
 		return fmt.Errorf("marshal mapping: %w", err)
 	}
 	key := MappingKey(appID, hydToken)
-	return s.client.Set(ctx, key, b, redisc.TTLMapping).Err()
+	value, err := s.encode(ctx, appID, key, b)
+	if err != nil {
+		return err
+	}
+	return s.backend.Set(ctx, key, value, redisc.TTLMapping)
 }
 
 // ResolveMapping retrieves the mapping for a given hyd_token.
 func (s *Store) ResolveMapping(ctx context.Context, appID, hydToken string) (*services.HydrationMapping, error) {
-	b, err := s.client.Get(ctx, MappingKey(appID, hydToken)).Bytes()
-	if errors.Is(err, redis.Nil) {
-		return nil, ErrCacheMiss
+	key := MappingKey(appID, hydToken)
+	raw, err := s.backend.Get(ctx, key)
+	if err != nil {
+		return nil, err
 	}
+	b, err := s.decode(ctx, appID, key, raw)
 	if err != nil {
-		return nil, fmt.Errorf("redis get mapping: %w", err)
+		return nil, err
 	}
 	var m services.HydrationMapping
 	if err := json.Unmarshal(b, &m); err != nil {
@@ -68,6 +327,54 @@ func (s *Store) ResolveMapping(ctx context.Context, appID, hydToken string) (*se
 	return &m, nil
 }
 
+// StoreClaims records the claims a contextKey was last hydrated with, so the
+// reader can re-hydrate entries in the background without the hydration token.
+// They are encoded like resources, so encryption at rest covers them.
+func (s *Store) StoreClaims(ctx context.Context, appID, contextKey string, claims map[string]string, ttl time.Duration) error {
+	key := ClaimsKey(appID, contextKey)
+	value, err := s.SealClaims(ctx, appID, key, claims)
+	if err != nil {
+		return err
+	}
+	return s.backend.Set(ctx, key, value, ttl)
+}
+
+// GetClaims returns the claims recorded by StoreClaims.
+func (s *Store) GetClaims(ctx context.Context, appID, contextKey string) (map[string]string, error) {
+	key := ClaimsKey(appID, contextKey)
+	raw, err := s.backend.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.OpenClaims(ctx, appID, key, raw)
+}
+
+// SealClaims encodes claims of appID like a cached resource, so encryption at
+// rest covers them wherever they are kept, such as in a queued job. ref is the
+// Redis key or other name they are stored under; it is authenticated, so
+// OpenClaims rejects sealed claims moved to another ref.
+func (s *Store) SealClaims(ctx context.Context, appID, ref string, claims map[string]string) ([]byte, error) {
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("marshal claims: %w", err)
+	}
+	return s.encode(ctx, appID, ref, b)
+}
+
+// OpenClaims returns the claims sealed by SealClaims under ref. Claims stored
+// as plain JSON, before encryption was enabled, are read as is.
+func (s *Store) OpenClaims(ctx context.Context, appID, ref string, sealed []byte) (map[string]string, error) {
+	data, err := s.decode(ctx, appID, ref, sealed)
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]string
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, fmt.Errorf("unmarshal claims: %w", err)
+	}
+	return claims, nil
+}
+
 // GetAccessPattern returns the list of resources from the access pattern key,
 // or nil if not found.
 func (s *Store) GetAccessPattern(ctx context.Context, appID, contextKey string) ([]string, error) {
@@ -82,6 +389,15 @@ func (s *Store) GetAccessPattern(ctx context.Context, appID, contextKey string)
 	return resources, nil
 }
 
+// SetAccessPattern writes the resources hydrations of contextKey should fetch.
+func (s *Store) SetAccessPattern(ctx context.Context, appID, contextKey string, resources []string, ttl time.Duration) error {
+	b, err := json.Marshal(resources)
+	if err != nil {
+		return fmt.Errorf("marshal access pattern: %w", err)
+	}
+	return s.backend.Set(ctx, AccessPatternKey(appID, contextKey), b, ttl)
+}
+
 // ── Key builders ──────────────────────────────────────────────────────────────
 
 // ResourceCacheKey returns the namespaced cache key for a resource.
@@ -95,18 +411,41 @@ func MappingKey(appID, hydToken string) string {
 	return redisc.KeyPrefixMapping + appID + ":" + hydToken
 }
 
+// ClaimsKey returns the Redis key for the claims a contextKey was hydrated with.
+func ClaimsKey(appID, contextKey string) string {
+	return redisc.KeyPrefixClaims + appID + ":" + contextKey
+}
+
+// LockKey returns the Redis key for a contextKey's hydration lock.
+func LockKey(appID, contextKey string) string {
+	return redisc.KeyPrefixLock + appID + ":" + contextKey
+}
+
+// JobStatusKey returns the Redis key for a hydration job's status.
+func JobStatusKey(jobID string) string {
+	return redisc.KeyPrefixJobStatus + jobID
+}
+
+// AppKey returns the Redis key for an app registration.
+func AppKey(appID string) string {
+	return redisc.KeyPrefixApp + appID
+}
+
 // AccessPatternKey returns the Redis key for a user's access pattern.
 func AccessPatternKey(appID, contextKey string) string {
 	return appID + ":access_pattern:" + contextKey
 }
 
-// KeyForResource returns the cache key for a given resource name and validates it.
-func KeyForResource(appID, contextKey, resource string) (string, bool) {
-	switch services.ServiceName(resource) {
-	case services.ServiceProfile, services.ServicePreferences,
-		services.ServicePermissions, services.ServiceResources:
-		return ResourceCacheKey(appID, resource, contextKey), true
-	default:
+// AccessStatsKey returns the Redis key for a contextKey's read counters.
+func AccessStatsKey(appID, contextKey string) string {
+	return redisc.KeyPrefixAccessStats + appID + ":" + contextKey
+}
+
+// KeyForResource returns the cache key for a resource, validating the name
+// against the app's configured resources. A nil appConfig accepts nothing.
+func KeyForResource(appConfig *services.AppConfig, contextKey, resource string) (string, bool) {
+	if appConfig == nil || !appConfig.HasResource(resource) {
 		return "", false
 	}
+	return ResourceCacheKey(appConfig.AppID, resource, contextKey), true
 }
@@ -2,9 +2,18 @@ package config
 
 import (
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/accesspattern"
+	"github.com/yourorg/context-hydrator/internal/auth"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/cookie"
+	"github.com/yourorg/context-hydrator/internal/jobs"
+	"github.com/yourorg/context-hydrator/internal/jwks"
+	"github.com/yourorg/context-hydrator/internal/keyring"
 	redisc "github.com/yourorg/context-hydrator/internal/redis"
 	"github.com/yourorg/context-hydrator/internal/services"
 )
@@ -14,17 +23,42 @@ type Config struct {
 	Port string `envconfig:"PORT" default:"8080"`
 	// Context reader service port (used by cmd/context-reader)
 	ReaderPort string `envconfig:"READER_PORT" default:"8081"`
+	// App registry service port (used by cmd/app-registry)
+	RegistryPort string `envconfig:"REGISTRY_PORT" default:"8082"`
+	// Hydration worker health and metrics port (used by cmd/hydration-worker)
+	WorkerPort string `envconfig:"WORKER_PORT" default:"8083"`
+	// Hydration service internal health and metrics port (used by
+	// cmd/hydration-server; PORT is internet-facing and has no /metrics)
+	MetricsPort string `envconfig:"METRICS_PORT" default:"8084"`
 
 	LogLevel  string `envconfig:"LOG_LEVEL" default:"info"`
 	LogFormat string `envconfig:"LOG_FORMAT" default:"json"`
 
+	// Trace exporter: "none", "stdout" or "otlp". The OTLP endpoint is read
+	// from the standard OTEL_EXPORTER_OTLP_ENDPOINT variable.
+	TracingExporter    string  `envconfig:"TRACING_EXPORTER" default:"none"`
+	TracingSampleRatio float64 `envconfig:"TRACING_SAMPLE_RATIO" default:"1"`
+
 	// App identifier — used to namespace Redis keys and JWT claims.
 	// Defaults to "default" for local development.
 	AppID string `envconfig:"APP_ID" default:"default"`
+	// How long a resolved app registration is cached in-process before it is
+	// re-read from Redis. Bounds how stale a PUT/DELETE is on other instances.
+	RegistryCacheTTL time.Duration `envconfig:"REGISTRY_CACHE_TTL" default:"30s"`
+	// Bearer token required by the registry's POST, PUT and DELETE routes.
+	// Empty disables registry writes.
+	RegistryToken string `envconfig:"REGISTRY_TOKEN" default:""`
 
 	RedisAddr     string `envconfig:"REDIS_ADDR" default:"localhost:6379"`
 	RedisPassword string `envconfig:"REDIS_PASSWORD" default:""`
 	RedisDB       int    `envconfig:"REDIS_DB" default:"0"`
+	// Redis topology: "single" (REDIS_ADDR), "sentinel" (REDIS_ADDRS lists the
+	// sentinels monitoring REDIS_MASTER_NAME), "cluster" (REDIS_ADDRS lists
+	// seed nodes) or "memory" (in-process storage, cmd/server only).
+	RedisMode             string   `envconfig:"REDIS_MODE" default:"single"`
+	RedisAddrs            []string `envconfig:"REDIS_ADDRS" default:""`
+	RedisMasterName       string   `envconfig:"REDIS_MASTER_NAME" default:""`
+	RedisSentinelPassword string   `envconfig:"REDIS_SENTINEL_PASSWORD" default:""`
 
 	// Base URLs for backend services. URL templates are derived from these:
 	// {SERVICE_URL}/users/{user_id}/{resource}
@@ -35,46 +69,363 @@ type Config struct {
 
 	BackendTimeoutSecs int `envconfig:"BACKEND_TIMEOUT_SECS" default:"4"`
 
+	// Upstream retries and circuit breakers for the default app's resources.
+	// BACKEND_MAX_ATTEMPTS=1 disables retries; BACKEND_BREAKER_THRESHOLD=0
+	// disables the breaker.
+	BackendMaxAttempts         int           `envconfig:"BACKEND_MAX_ATTEMPTS" default:"2"`
+	BackendRetryBackoff        time.Duration `envconfig:"BACKEND_RETRY_BACKOFF" default:"50ms"`
+	BackendRetryMaxBackoff     time.Duration `envconfig:"BACKEND_RETRY_MAX_BACKOFF" default:"500ms"`
+	BackendRetryableStatus     []int         `envconfig:"BACKEND_RETRYABLE_STATUS" default:"502,503,504"`
+	BackendBreakerThreshold    int           `envconfig:"BACKEND_BREAKER_THRESHOLD" default:"5"`
+	BackendBreakerOpenDuration time.Duration `envconfig:"BACKEND_BREAKER_OPEN_DURATION" default:"30s"`
+
+	// After a successful hydration, further hydrations of the same contextKey
+	// are skipped for this long (repeat logins, retries, extra tabs). 0 disables.
+	HydrationSuppressWindow time.Duration `envconfig:"HYDRATION_SUPPRESS_WINDOW" default:"10s"`
+
+	// How long GET /hydrate/{jobID} can report a hydration after its last update.
+	HydrationStatusTTL time.Duration `envconfig:"HYDRATION_STATUS_TTL" default:"10m"`
+
+	// Hydration worker pool: POST /hydrate returns 503 once every worker is
+	// busy and the queue is full. At shutdown, queued and running hydrations
+	// are given up to the drain timeout to finish.
+	HydrationWorkers      int           `envconfig:"HYDRATION_WORKERS" default:"64"`
+	HydrationQueueSize    int           `envconfig:"HYDRATION_QUEUE_SIZE" default:"1000"`
+	HydrationDrainTimeout time.Duration `envconfig:"HYDRATION_DRAIN_TIMEOUT" default:"30s"`
+
+	// Where accepted hydrations run: "local" on this process's worker pool, or
+	// "stream" queued on hyd:jobs for cmd/hydration-worker. The worker uses
+	// HYDRATION_WORKERS as its concurrency.
+	HydrationDispatch             string        `envconfig:"HYDRATION_DISPATCH" default:"local"`
+	HydrationJobVisibilityTimeout time.Duration `envconfig:"HYDRATION_JOB_VISIBILITY_TIMEOUT" default:"30s"`
+	HydrationJobMaxDeliveries     int64         `envconfig:"HYDRATION_JOB_MAX_DELIVERIES" default:"5"`
+	HydrationJobStreamMaxLen      int64         `envconfig:"HYDRATION_JOB_STREAM_MAXLEN" default:"100000"`
+
+	// Stale-while-revalidate: reads re-hydrate an entry in the background once
+	// its remaining TTL drops below this fraction of the resource TTL. 0 disables.
+	RevalidateFraction float64 `envconfig:"REVALIDATE_FRACTION" default:"0.2"`
+
+	// In-process LRU tier in front of Redis for resource reads, bounded by
+	// entry count and total bytes. Entries live for at most LOCAL_CACHE_TTL and
+	// never past their Redis expiry. A zero LOCAL_CACHE_MAX_ENTRIES disables it.
+	LocalCacheMaxEntries int           `envconfig:"LOCAL_CACHE_MAX_ENTRIES" default:"0"`
+	LocalCacheMaxBytes   int64         `envconfig:"LOCAL_CACHE_MAX_BYTES" default:"67108864"`
+	LocalCacheTTL        time.Duration `envconfig:"LOCAL_CACHE_TTL" default:"30s"`
+
+	// Per-app AES-GCM encryption of cached resources, with data keys read from
+	// a local JSON key file and reloaded every refresh interval. Apps without
+	// keys in the file are stored in plaintext. Empty disables encryption.
+	EncryptionKeysFile    string        `envconfig:"ENCRYPTION_KEYS_FILE" default:""`
+	EncryptionKeysRefresh time.Duration `envconfig:"ENCRYPTION_KEYS_REFRESH" default:"1m"`
+
+	// Cached resources of at least this many bytes are stored gzipped. Readers
+	// of every version in a deployment must understand compressed entries
+	// before it is enabled. 0 (the default) disables compression.
+	CacheCompressionThreshold int `envconfig:"CACHE_COMPRESSION_THRESHOLD" default:"0"`
+
+	// Default-app resources the reader fetches from the backend on a cache
+	// miss. Empty (the default) disables read-through.
+	ReadThroughResources []string `envconfig:"READ_THROUGH_RESOURCES" default:""`
+
+	// Access-pattern learning on the reader: reads are buffered and flushed
+	// every flush interval, and patterns recomputed every interval. Read
+	// counts halve every half-life; counters and patterns of contextKeys no
+	// longer read expire after the TTL. The MIN_* and ALWAYS_INCLUDE settings
+	// apply to the default app; registered apps configure their own.
+	AccessPatternsEnabled      bool          `envconfig:"ACCESS_PATTERNS_ENABLED" default:"true"`
+	AccessPatternFlushInterval time.Duration `envconfig:"ACCESS_PATTERN_FLUSH_INTERVAL" default:"10s"`
+	AccessPatternInterval      time.Duration `envconfig:"ACCESS_PATTERN_INTERVAL" default:"1m"`
+	AccessPatternHalfLife      time.Duration `envconfig:"ACCESS_PATTERN_HALF_LIFE" default:"168h"`
+	AccessPatternTTL           time.Duration `envconfig:"ACCESS_PATTERN_TTL" default:"720h"`
+	AccessPatternMinReads      float64       `envconfig:"ACCESS_PATTERN_MIN_READS" default:"10"`
+	AccessPatternMinConfidence float64       `envconfig:"ACCESS_PATTERN_MIN_CONFIDENCE" default:"0.05"`
+	AccessPatternAlwaysInclude []string      `envconfig:"ACCESS_PATTERN_ALWAYS_INCLUDE"`
+
+	// Hydration-complete events for the default app: a MAXLEN-trimmed stream
+	// (hyd:stream:{appID}) and/or a Pub/Sub channel (hyd:events:{appID}).
+	EventsStream       bool  `envconfig:"EVENTS_STREAM" default:"true"`
+	EventsStreamMaxLen int64 `envconfig:"EVENTS_STREAM_MAXLEN" default:"10000"`
+	EventsPubSub       bool  `envconfig:"EVENTS_PUBSUB" default:"false"`
+
+	// Longest a GET /context/{contextKey}/events stream waits for resources
+	// before sending its final "complete" event.
+	SSEMaxWait time.Duration `envconfig:"SSE_MAX_WAIT" default:"10s"`
+
+	// POST /hydrate rate limits for the default app, in requests per minute
+	// (0 disables a limit). Registered apps configure their own.
+	RateLimitEnabled        bool `envconfig:"RATE_LIMIT_ENABLED" default:"true"`
+	RateLimitPerToken       int  `envconfig:"RATE_LIMIT_PER_TOKEN" default:"10"`
+	RateLimitPerIP          int  `envconfig:"RATE_LIMIT_PER_IP" default:"100"`
+	RateLimitPerApp         int  `envconfig:"RATE_LIMIT_PER_APP" default:"0"`
+	RateLimitTrustForwarded bool `envconfig:"RATE_LIMIT_TRUST_FORWARDED" default:"false"`
+
+	// Session-token authentication on the reader routes: "none" (local dev),
+	// "jwt" (HS256 with SESSION_JWT_SECRET and/or RS256 with SESSION_JWKS) or
+	// "introspection" (opaque tokens checked at SESSION_INTROSPECTION_URL).
+	// In jwt mode a configured introspection URL is also consulted.
+	SessionAuthMode                  string        `envconfig:"SESSION_AUTH_MODE" default:"none"`
+	SessionJWTSecret                 string        `envconfig:"SESSION_JWT_SECRET" default:""`
+	SessionJWKS                      string        `envconfig:"SESSION_JWKS" default:""` // file path or URL
+	SessionJWKSRefresh               time.Duration `envconfig:"SESSION_JWKS_REFRESH" default:"5m"`
+	SessionIssuer                    string        `envconfig:"SESSION_ISSUER" default:""`
+	SessionAudience                  string        `envconfig:"SESSION_AUDIENCE" default:""`
+	SessionIntrospectionURL          string        `envconfig:"SESSION_INTROSPECTION_URL" default:""`
+	SessionIntrospectionClientID     string        `envconfig:"SESSION_INTROSPECTION_CLIENT_ID" default:""`
+	SessionIntrospectionClientSecret string        `envconfig:"SESSION_INTROSPECTION_CLIENT_SECRET" default:""`
+
 	// Cookie decoding: "base64json" (local dev) or "jwt" (production)
 	CookieSecret   string `envconfig:"COOKIE_SECRET" default:""`
 	CookieEncoding string `envconfig:"COOKIE_ENCODING" default:"base64json"`
+	// Per-app JWT keys (kid-selected HS256 secrets, JWKS, iss/aud) for key
+	// rotation. Apps not in the file are verified with COOKIE_SECRET.
+	CookieKeysFile    string        `envconfig:"COOKIE_KEYS_FILE" default:""`
+	CookieJWKSRefresh time.Duration `envconfig:"COOKIE_JWKS_REFRESH" default:"5m"`
+
+	// Event-driven invalidation: consume hyd:invalidate:{appID} streams for
+	// these apps (defaults to APP_ID) and for registered apps, re-listed every
+	// INVALIDATION_DISCOVERY_INTERVAL (0 disables discovery). The hydration
+	// service runs the consumer.
+	InvalidationEnabled           bool          `envconfig:"INVALIDATION_ENABLED" default:"true"`
+	InvalidationApps              []string      `envconfig:"INVALIDATION_APPS" default:""`
+	InvalidationDiscoveryInterval time.Duration `envconfig:"INVALIDATION_DISCOVERY_INTERVAL" default:"1m"`
+	// Bearer token required by DELETE /data/{contextKey}/{resource}.
+	// Empty disables the endpoint.
+	InvalidationToken string `envconfig:"INVALIDATION_TOKEN" default:""`
 
 	ReadTimeout  time.Duration `envconfig:"READ_TIMEOUT" default:"5s"`
 	WriteTimeout time.Duration `envconfig:"WRITE_TIMEOUT" default:"10s"`
 }
 
+// REDIS_MODE values.
+const (
+	RedisSingle   = "single"
+	RedisSentinel = "sentinel"
+	RedisCluster  = "cluster"
+	RedisMemory   = "memory"
+)
+
+// HYDRATION_DISPATCH values.
+const (
+	DispatchLocal  = "local"
+	DispatchStream = "stream"
+)
+
 func Load() (*Config, error) {
 	var cfg Config
 	if err := envconfig.Process("", &cfg); err != nil {
 		return nil, err
 	}
+	if len(cfg.InvalidationApps) == 0 {
+		cfg.InvalidationApps = []string{cfg.AppID}
+	}
+	if cfg.HydrationDispatch != DispatchLocal && cfg.HydrationDispatch != DispatchStream {
+		return nil, fmt.Errorf("unknown HYDRATION_DISPATCH %q", cfg.HydrationDispatch)
+	}
+	switch cfg.RedisMode {
+	case RedisSingle, RedisMemory:
+	case RedisSentinel:
+		if len(cfg.RedisAddrs) == 0 || cfg.RedisMasterName == "" {
+			return nil, fmt.Errorf("REDIS_MODE=sentinel requires REDIS_ADDRS and REDIS_MASTER_NAME")
+		}
+	case RedisCluster:
+		if len(cfg.RedisAddrs) == 0 {
+			return nil, fmt.Errorf("REDIS_MODE=cluster requires REDIS_ADDRS")
+		}
+	default:
+		return nil, fmt.Errorf("unknown REDIS_MODE %q", cfg.RedisMode)
+	}
 	return &cfg, nil
 }
 
+// RedisOptions returns the client options for REDIS_MODE. Cluster mode has
+// no databases, so REDIS_DB is ignored there.
+func (c *Config) RedisOptions() *redis.UniversalOptions {
+	opts := &redis.UniversalOptions{
+		Addrs:    []string{c.RedisAddr},
+		Password: c.RedisPassword,
+		DB:       c.RedisDB,
+	}
+	switch c.RedisMode {
+	case RedisSentinel:
+		opts.Addrs = c.RedisAddrs
+		opts.MasterName = c.RedisMasterName
+		opts.SentinelPassword = c.RedisSentinelPassword
+	case RedisCluster:
+		opts.Addrs = c.RedisAddrs
+		opts.DB = 0
+		opts.IsClusterMode = true
+	}
+	return opts
+}
+
+// JobWorkerConfig returns the hydration job worker settings.
+func (c *Config) JobWorkerConfig() jobs.WorkerConfig {
+	return jobs.WorkerConfig{
+		Concurrency:       c.HydrationWorkers,
+		VisibilityTimeout: c.HydrationJobVisibilityTimeout,
+		MaxDeliveries:     c.HydrationJobMaxDeliveries,
+	}
+}
+
+// LocalCacheConfig returns the bounds of the in-process cache tier.
+func (c *Config) LocalCacheConfig() cache.LocalConfig {
+	return cache.LocalConfig{
+		MaxEntries: c.LocalCacheMaxEntries,
+		MaxBytes:   c.LocalCacheMaxBytes,
+		TTL:        c.LocalCacheTTL,
+	}
+}
+
+// KeyProvider loads the data keys cached resources are encrypted with. It
+// returns nil when ENCRYPTION_KEYS_FILE is unset.
+func (c *Config) KeyProvider() (keyring.Provider, error) {
+	if c.EncryptionKeysFile == "" {
+		return nil, nil
+	}
+	p := keyring.NewFileProvider(c.EncryptionKeysFile, c.EncryptionKeysRefresh)
+	if err := p.Load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// CookieDecoder builds the hydration cookie decoder, with the per-app keys of
+// COOKIE_KEYS_FILE when set. client fetches remote JWKS documents.
+func (c *Config) CookieDecoder(client *http.Client) (*cookie.Decoder, error) {
+	var opts []cookie.Option
+	if c.CookieKeysFile != "" {
+		apps, err := cookie.LoadKeys(c.CookieKeysFile, c.CookieJWKSRefresh, client)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, cookie.WithAppKeys(apps))
+	}
+	return cookie.NewDecoder(c.CookieEncoding, c.CookieSecret, opts...), nil
+}
+
+// AccessPatternConfig returns the access-pattern tracker settings.
+func (c *Config) AccessPatternConfig() accesspattern.Config {
+	return accesspattern.Config{
+		FlushInterval: c.AccessPatternFlushInterval,
+		LearnInterval: c.AccessPatternInterval,
+		HalfLife:      c.AccessPatternHalfLife,
+		TTL:           c.AccessPatternTTL,
+	}
+}
+
 // DefaultAppConfig builds an AppConfig from the environment-based service URLs.
+// It is seeded into the app registry as the default app, so a deployment keeps
+// serving APP_ID without any registration.
 // URL templates are derived from base service URLs, compatible with the mock backend
 // which serves at /{resource} paths under /users/{user_id}.
 func (c *Config) DefaultAppConfig() *services.AppConfig {
-	return &services.AppConfig{
+	retry := services.RetryPolicy{
+		MaxAttempts:     c.BackendMaxAttempts,
+		InitialBackoff:  c.BackendRetryBackoff,
+		MaxBackoff:      c.BackendRetryMaxBackoff,
+		RetryableStatus: c.BackendRetryableStatus,
+	}
+	breaker := services.BreakerConfig{
+		FailureThreshold: c.BackendBreakerThreshold,
+		OpenDuration:     c.BackendBreakerOpenDuration,
+	}
+	alwaysInclude := make([]services.ServiceName, len(c.AccessPatternAlwaysInclude))
+	for i, name := range c.AccessPatternAlwaysInclude {
+		alwaysInclude[i] = services.ServiceName(name)
+	}
+	app := &services.AppConfig{
 		AppID: c.AppID,
 		Resources: map[services.ServiceName]services.ResourceConfig{
 			services.ServiceProfile: {
 				URLTemplate: fmt.Sprintf("%s/users/{user_id}/profile", c.ProfileServiceURL),
 				TTL:         redisc.TTLProfile,
+				Retry:       retry,
+				Breaker:     breaker,
 			},
 			services.ServicePreferences: {
 				URLTemplate: fmt.Sprintf("%s/users/{user_id}/preferences", c.PreferencesServiceURL),
 				TTL:         redisc.TTLPreferences,
+				Retry:       retry,
+				Breaker:     breaker,
 			},
 			services.ServicePermissions: {
 				URLTemplate: fmt.Sprintf("%s/users/{user_id}/permissions", c.PermissionsServiceURL),
 				TTL:         redisc.TTLPermissions,
+				Retry:       retry,
+				Breaker:     breaker,
 			},
 			services.ServiceResources: {
 				URLTemplate: fmt.Sprintf("%s/users/{user_id}/resources", c.ResourcesServiceURL),
 				TTL:         redisc.TTLResources,
+				Retry:       retry,
+				Breaker:     breaker,
 			},
 		},
-		Secret: []byte(c.CookieSecret),
+		Secret:             []byte(c.CookieSecret),
+		ContextKeyClaims:   []string{"user_id"},
+		RevalidateFraction: c.RevalidateFraction,
+		Events: services.EventsConfig{
+			Stream:       c.EventsStream,
+			StreamMaxLen: c.EventsStreamMaxLen,
+			PubSub:       c.EventsPubSub,
+		},
+		RateLimit: services.RateLimitConfig{
+			PerToken: c.RateLimitPerToken,
+			PerIP:    c.RateLimitPerIP,
+			PerApp:   c.RateLimitPerApp,
+		},
+		AccessPatterns: services.AccessPatternConfig{
+			MinReads:      c.AccessPatternMinReads,
+			MinConfidence: c.AccessPatternMinConfidence,
+			AlwaysInclude: alwaysInclude,
+		},
+	}
+	for _, name := range c.ReadThroughResources {
+		if res, ok := app.Resources[services.ServiceName(name)]; ok {
+			res.ReadThrough = true
+			app.Resources[services.ServiceName(name)] = res
+		}
+	}
+	return app
+}
+
+// SessionVerifier builds the reader's session-token verifier from
+// SESSION_AUTH_MODE. It returns nil in "none" mode.
+func (c *Config) SessionVerifier(client *http.Client) (auth.Verifier, error) {
+	var introspector auth.Introspector
+	if c.SessionIntrospectionURL != "" {
+		introspector = &auth.HTTPIntrospector{
+			URL:          c.SessionIntrospectionURL,
+			ClientID:     c.SessionIntrospectionClientID,
+			ClientSecret: c.SessionIntrospectionClientSecret,
+			Client:       client,
+		}
+	}
+
+	switch c.SessionAuthMode {
+	case "none", "":
+		return nil, nil
+	case "jwt":
+		jwtCfg := auth.JWTConfig{
+			Secret:       []byte(c.SessionJWTSecret),
+			Issuer:       c.SessionIssuer,
+			Audience:     c.SessionAudience,
+			Introspector: introspector,
+		}
+		if c.SessionJWKS != "" {
+			jwtCfg.JWKS = jwks.NewSource(c.SessionJWKS, c.SessionJWKSRefresh, client)
+		}
+		v, err := auth.NewJWTVerifier(jwtCfg)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	case "introspection":
+		if introspector == nil {
+			return nil, fmt.Errorf("SESSION_INTROSPECTION_URL is required in introspection mode")
+		}
+		return &auth.IntrospectionVerifier{Introspector: introspector}, nil
+	default:
+		return nil, fmt.Errorf("unknown SESSION_AUTH_MODE %q", c.SessionAuthMode)
 	}
 }
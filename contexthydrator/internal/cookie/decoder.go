@@ -1,9 +1,16 @@
 package cookie
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -28,17 +35,32 @@ type Claims struct {
 
 type Decoder struct {
 	encoding string // "base64json" or "jwt"
-	secret   []byte
+	// secret verifies the HS256 tokens of apps without keys of their own.
+	secret []byte
+	apps   map[string]AppKeys
 }
 
-func NewDecoder(encoding, secret string) *Decoder {
-	return &Decoder{encoding: encoding, secret: []byte(secret)}
+// Option configures optional Decoder features.
+type Option func(*Decoder)
+
+// WithAppKeys verifies the JWTs of each app in apps with its own keys and
+// iss/aud, instead of the shared secret.
+func WithAppKeys(apps map[string]AppKeys) Option {
+	return func(d *Decoder) { d.apps = apps }
+}
+
+func NewDecoder(encoding, secret string, opts ...Option) *Decoder {
+	d := &Decoder{encoding: encoding, secret: []byte(secret)}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
-func (d *Decoder) Decode(raw string) (*Claims, error) {
+func (d *Decoder) Decode(ctx context.Context, raw string) (*Claims, error) {
 	switch d.encoding {
 	case "jwt":
-		return d.decodeJWT(raw)
+		return d.decodeJWT(ctx, raw)
 	default:
 		return d.decodeBase64JSON(raw)
 	}
@@ -62,19 +84,37 @@ func (d *Decoder) decodeBase64JSON(raw string) (*Claims, error) {
 	return &c, nil
 }
 
-func (d *Decoder) decodeJWT(raw string) (*Claims, error) {
-	type jwtClaims struct {
-		HydrationToken string `json:"hyd_token"`
-		AppID          string `json:"app_id"`
-		jwt.RegisteredClaims
+type jwtClaims struct {
+	HydrationToken string `json:"hyd_token"`
+	AppID          string `json:"app_id"`
+	jwt.RegisteredClaims
+}
+
+// decodeJWT verifies a hydration JWT with the keys of the app it names:
+// HS256 secrets or JWKS keys selected by kid, and the app's iss/aud. Apps
+// without keys of their own use the shared HS256 secret.
+func (d *Decoder) decodeJWT(ctx context.Context, raw string) (*Claims, error) {
+	// The app is read before the signature is checked, to pick its keys; the
+	// claim is verified along with the rest of the token below.
+	var unverified jwtClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(raw, &unverified); err != nil {
+		return nil, fmt.Errorf("jwt parse: %w", err)
 	}
 
-	token, err := jwt.ParseWithClaims(raw, &jwtClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+	var parser *jwt.Parser
+	var keyFunc jwt.Keyfunc
+	if keys, ok := d.apps[unverified.AppID]; ok {
+		parser, keyFunc = keys.parser(ctx)
+	} else {
+		// An empty HMAC key verifies signatures anyone can compute.
+		if len(d.secret) == 0 {
+			return nil, fmt.Errorf("%w: no keys for app %q", ErrInvalidToken, unverified.AppID)
 		}
-		return d.secret, nil
-	})
+		parser = jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+		keyFunc = func(*jwt.Token) (any, error) { return d.secret, nil }
+	}
+
+	token, err := parser.ParseWithClaims(raw, &jwtClaims{}, keyFunc)
 	if err != nil {
 		return nil, fmt.Errorf("jwt parse: %w", err)
 	}
@@ -95,3 +135,78 @@ func (d *Decoder) decodeJWT(raw string) (*Claims, error) {
 		AppID:          claims.AppID,
 	}, nil
 }
+
+// parser returns a parser enforcing the app's algorithms and iss/aud, and the
+// function resolving a token's verification key.
+func (k AppKeys) parser(ctx context.Context) (*jwt.Parser, jwt.Keyfunc) {
+	var methods []string
+	if len(k.HMAC) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if k.JWKS != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg(), jwt.SigningMethodEdDSA.Alg())
+	}
+	opts := []jwt.ParserOption{jwt.WithValidMethods(methods)}
+	if k.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(k.Issuer))
+	}
+	if k.Audience != "" {
+		opts = append(opts, jwt.WithAudience(k.Audience))
+	}
+
+	return jwt.NewParser(opts...), func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		if t.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+			return k.hmacKeys(kid, time.Now())
+		}
+		key, err := k.JWKS.Key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if !keyMatches(t.Method.Alg(), key) {
+			return nil, fmt.Errorf("key %q cannot verify %s", kid, t.Method.Alg())
+		}
+		return key, nil
+	}
+}
+
+// hmacKeys returns the secrets that may have signed a token with kid: the
+// key with that ID, or every key for tokens issued without a kid. Keys past
+// their overlap window are left out.
+func (k AppKeys) hmacKeys(kid string, now time.Time) (jwt.VerificationKeySet, error) {
+	var set jwt.VerificationKeySet
+	for _, key := range k.HMAC {
+		if (kid == "" || key.ID == kid) && (key.NotAfter.IsZero() || now.Before(key.NotAfter)) {
+			set.Keys = append(set.Keys, key.Secret)
+		}
+	}
+	if len(set.Keys) == 0 {
+		return set, fmt.Errorf("%w: kid %q", errUnknownKey, kid)
+	}
+	return set, nil
+}
+
+var (
+	// ErrInvalidToken is returned for JWTs of an app with no key to verify
+	// them with.
+	ErrInvalidToken = errors.New("invalid hydration token")
+
+	// errUnknownKey is returned for tokens whose kid names no valid key.
+	errUnknownKey = errors.New("unknown or retired key")
+)
+
+// keyMatches reports whether key is of the type alg verifies with.
+func keyMatches(alg string, key any) bool {
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		_, ok := key.(*rsa.PublicKey)
+		return ok
+	case jwt.SigningMethodES256.Alg():
+		k, ok := key.(*ecdsa.PublicKey)
+		return ok && k.Curve == elliptic.P256()
+	case jwt.SigningMethodEdDSA.Alg():
+		_, ok := key.(ed25519.PublicKey)
+		return ok
+	}
+	return false
+}
@@ -1,8 +1,19 @@
 package cookie
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,7 +26,7 @@ func TestDecodeBase64JSON(t *testing.T) {
 	encoded := base64.StdEncoding.EncodeToString(b)
 
 	d := NewDecoder("base64json", "")
-	got, err := d.Decode(encoded)
+	got, err := d.Decode(context.Background(), encoded)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -30,7 +41,7 @@ func TestDecodeBase64JSON_URLEncoding(t *testing.T) {
 	encoded := base64.URLEncoding.EncodeToString(b)
 
 	d := NewDecoder("base64json", "")
-	got, err := d.Decode(encoded)
+	got, err := d.Decode(context.Background(), encoded)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -44,7 +55,7 @@ func TestDecodeBase64JSON_MissingUserID(t *testing.T) {
 	encoded := base64.StdEncoding.EncodeToString(b)
 
 	d := NewDecoder("base64json", "")
-	_, err := d.Decode(encoded)
+	_, err := d.Decode(context.Background(), encoded)
 	if err == nil {
 		t.Fatal("expected error for missing user_id")
 	}
@@ -52,7 +63,7 @@ func TestDecodeBase64JSON_MissingUserID(t *testing.T) {
 
 func TestDecodeBase64JSON_InvalidBase64(t *testing.T) {
 	d := NewDecoder("base64json", "")
-	_, err := d.Decode("not-valid-base64!!!")
+	_, err := d.Decode(context.Background(), "not-valid-base64!!!")
 	if err == nil {
 		t.Fatal("expected error for invalid base64")
 	}
@@ -78,7 +89,7 @@ func TestDecodeJWT(t *testing.T) {
 	}
 
 	d := NewDecoder("jwt", secret)
-	got, err := d.Decode(signed)
+	got, err := d.Decode(context.Background(), signed)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -103,8 +114,182 @@ func TestDecodeJWT_WrongSecret(t *testing.T) {
 	signed, _ := token.SignedString([]byte("real-secret"))
 
 	d := NewDecoder("jwt", "wrong-secret")
-	_, err := d.Decode(signed)
+	_, err := d.Decode(context.Background(), signed)
 	if err == nil {
 		t.Fatal("expected error for wrong secret")
 	}
 }
+
+// signJWT signs a hydration token for app with the given method, key and kid.
+func signJWT(t *testing.T, method jwt.SigningMethod, key any, kid, app string, registered jwt.RegisteredClaims) string {
+	t.Helper()
+	if registered.ExpiresAt == nil {
+		registered.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+	}
+	token := jwt.NewWithClaims(method, jwtClaims{HydrationToken: "tok", AppID: app, RegisteredClaims: registered})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func TestDecodeJWT_KeyRotation(t *testing.T) {
+	current, previous := []byte("current-secret"), []byte("previous-secret")
+	d := NewDecoder("jwt", "legacy-secret", WithAppKeys(map[string]AppKeys{
+		"app": {HMAC: []HMACKey{
+			{ID: "2026-q4", Secret: current},
+			{ID: "2026-q3", Secret: previous, NotAfter: time.Now().Add(time.Hour)},
+			{ID: "2026-q2", Secret: []byte("retired-secret"), NotAfter: time.Now().Add(-time.Hour)},
+		}},
+	}))
+
+	cases := []struct {
+		name    string
+		key     []byte
+		kid     string
+		wantErr bool
+	}{
+		{"current key", current, "2026-q4", false},
+		{"previous key in overlap window", previous, "2026-q3", false},
+		{"no kid tries every key", previous, "", false},
+		{"retired key", []byte("retired-secret"), "2026-q2", true},
+		{"kid of another key", previous, "2026-q4", true},
+		{"unknown kid", current, "2025-q1", true},
+		{"legacy secret", []byte("legacy-secret"), "", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			signed := signJWT(t, jwt.SigningMethodHS256, tc.key, tc.kid, "app", jwt.RegisteredClaims{})
+			_, err := d.Decode(context.Background(), signed)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeJWT_EmptySecret(t *testing.T) {
+	d := NewDecoder("jwt", "", WithAppKeys(map[string]AppKeys{
+		"app": {HMAC: []HMACKey{{ID: "k1", Secret: []byte("app-secret")}}},
+	}))
+
+	// golang-jwt accepts an empty HS256 key, so anyone could sign this.
+	signed := signJWT(t, jwt.SigningMethodHS256, []byte{}, "", "other-app", jwt.RegisteredClaims{})
+	if _, err := d.Decode(context.Background(), signed); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestDecodeJWT_LegacySecretForUnlistedApp(t *testing.T) {
+	d := NewDecoder("jwt", "legacy-secret", WithAppKeys(map[string]AppKeys{
+		"app": {HMAC: []HMACKey{{ID: "k1", Secret: []byte("app-secret")}}},
+	}))
+
+	got, err := d.Decode(context.Background(), signJWT(t, jwt.SigningMethodHS256, []byte("legacy-secret"), "", "other-app", jwt.RegisteredClaims{}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.AppID != "other-app" {
+		t.Errorf("app_id: got %q, want %q", got.AppID, "other-app")
+	}
+}
+
+func TestDecodeJWT_JWKS(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	otherEC, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwksJSON := fmt.Sprintf(`{"keys": [
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": %q, "y": %q},
+		{"kty": "OKP", "kid": "ed-1", "crv": "Ed25519", "x": %q}]}`,
+		b64(ecKey.X.FillBytes(make([]byte, 32))), b64(ecKey.Y.FillBytes(make([]byte, 32))), b64(edPub))
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, []byte(jwksJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keysFile := filepath.Join(t.TempDir(), "cookie-keys.json")
+	if err := os.WriteFile(keysFile, []byte(fmt.Sprintf(
+		`{"app": {"issuer": "https://idp.example.com", "audience": "context-hydrator", "jwks": %q}}`, path)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	apps, err := LoadKeys(keysFile, time.Minute, nil)
+	if err != nil {
+		t.Fatalf("LoadKeys: %v", err)
+	}
+	d := NewDecoder("jwt", "", WithAppKeys(apps))
+
+	valid := jwt.RegisteredClaims{Issuer: "https://idp.example.com", Audience: jwt.ClaimStrings{"context-hydrator"}}
+	cases := []struct {
+		name    string
+		method  jwt.SigningMethod
+		key     crypto.Signer
+		kid     string
+		claims  jwt.RegisteredClaims
+		wantErr bool
+	}{
+		{"ES256", jwt.SigningMethodES256, ecKey, "ec-1", valid, false},
+		{"EdDSA", jwt.SigningMethodEdDSA, edKey, "ed-1", valid, false},
+		{"key of another type", jwt.SigningMethodES256, ecKey, "ed-1", valid, true},
+		{"wrong signing key", jwt.SigningMethodES256, otherEC, "ec-1", valid, true},
+		{"unknown kid", jwt.SigningMethodES256, ecKey, "ec-2", valid, true},
+		{"wrong issuer", jwt.SigningMethodES256, ecKey, "ec-1",
+			jwt.RegisteredClaims{Issuer: "https://evil.example.com", Audience: valid.Audience}, true},
+		{"wrong audience", jwt.SigningMethodES256, ecKey, "ec-1",
+			jwt.RegisteredClaims{Issuer: valid.Issuer, Audience: jwt.ClaimStrings{"other-service"}}, true},
+		{"missing audience", jwt.SigningMethodES256, ecKey, "ec-1", jwt.RegisteredClaims{Issuer: valid.Issuer}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			signed := signJWT(t, tc.method, tc.key, tc.kid, "app", tc.claims)
+			_, err := d.Decode(context.Background(), signed)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+
+	// HS256 is not accepted for an app verified only through its JWKS.
+	signed := signJWT(t, jwt.SigningMethodHS256, []byte("secret"), "ec-1", "app", valid)
+	if _, err := d.Decode(context.Background(), signed); err == nil {
+		t.Fatal("expected error for HS256 token")
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	cases := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{"valid", `{"app": {"keys": [{"kid": "k1", "secret": "c2VjcmV0"}, {"kid": "k0", "secret": "b2xk", "not_after": "2026-11-01T00:00:00Z"}]}}`, ""},
+		{"bad secret", `{"app": {"keys": [{"kid": "k1", "secret": "%%%"}]}}`, "want a base64 secret"},
+		{"no keys", `{"app": {"issuer": "https://idp.example.com"}}`, "has no keys"},
+		{"malformed", `{"app": [`, "decode"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cookie-keys.json")
+			if err := os.WriteFile(path, []byte(tc.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			apps, err := LoadKeys(path, time.Minute, nil)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got := apps["app"].HMAC; len(got) != 2 || string(got[0].Secret) != "secret" || got[1].NotAfter.IsZero() {
+					t.Errorf("HMAC keys = %+v", got)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
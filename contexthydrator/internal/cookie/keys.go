@@ -0,0 +1,84 @@
+package cookie
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/yourorg/context-hydrator/internal/jwks"
+)
+
+// HMACKey is an HS256 secret for an app's hydration JWTs.
+type HMACKey struct {
+	// ID is matched against the token's kid header.
+	ID     string
+	Secret []byte
+	// NotAfter ends the key's overlap window once it has been rotated out:
+	// tokens signed with it are rejected from then on. Zero keeps the key
+	// valid.
+	NotAfter time.Time
+}
+
+// AppKeys verifies one app's hydration JWTs.
+type AppKeys struct {
+	HMAC []HMACKey
+	// JWKS supplies RS256, ES256 and EdDSA keys, selected by kid.
+	JWKS *jwks.Source
+	// Issuer and Audience are required to match when set.
+	Issuer   string
+	Audience string
+}
+
+// keysFileApp is one app's entry in a cookie keys file.
+type keysFileApp struct {
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+	Keys     []struct {
+		KID      string    `json:"kid"`
+		Secret   string    `json:"secret"` // base64
+		NotAfter time.Time `json:"not_after"`
+	} `json:"keys"`
+	JWKS string `json:"jwks"` // file path or URL
+}
+
+// LoadKeys reads per-app verification keys from a JSON file. JWKS documents
+// are fetched on first use and refreshed every jwksRefresh:
+//
+//	{"payments-app": {
+//	  "issuer": "https://payments.example.com", "audience": "context-hydrator",
+//	  "keys": [{"kid": "2026-q4", "secret": "<base64>"},
+//	           {"kid": "2026-q3", "secret": "<base64>", "not_after": "2026-11-01T00:00:00Z"}],
+//	  "jwks": "https://payments.example.com/.well-known/jwks.json"}}
+func LoadKeys(path string, jwksRefresh time.Duration, client *http.Client) (map[string]AppKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cookie keys: %w", err)
+	}
+	var file map[string]keysFileApp
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("cookie keys: decode: %w", err)
+	}
+
+	apps := make(map[string]AppKeys, len(file))
+	for appID, app := range file {
+		keys := AppKeys{Issuer: app.Issuer, Audience: app.Audience}
+		for _, k := range app.Keys {
+			secret, err := base64.StdEncoding.DecodeString(k.Secret)
+			if err != nil || len(secret) == 0 {
+				return nil, fmt.Errorf("cookie keys: app %q kid %q: want a base64 secret", appID, k.KID)
+			}
+			keys.HMAC = append(keys.HMAC, HMACKey{ID: k.KID, Secret: secret, NotAfter: k.NotAfter})
+		}
+		if app.JWKS != "" {
+			keys.JWKS = jwks.NewSource(app.JWKS, jwksRefresh, client)
+		}
+		if len(keys.HMAC) == 0 && keys.JWKS == nil {
+			return nil, fmt.Errorf("cookie keys: app %q has no keys", appID)
+		}
+		apps[appID] = keys
+	}
+	return apps, nil
+}
@@ -0,0 +1,147 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// listenerBuffer is the number of progress messages buffered per listener.
+// Messages for a listener that falls further behind are dropped.
+const listenerBuffer = 16
+
+// Hub fans progress messages out to local listeners (SSE connections) over a
+// single Redis Pub/Sub connection per process. A context's channel is
+// subscribed when its first listener arrives and unsubscribed when the last
+// one leaves, so hydrations running on any instance reach every reader.
+type Hub struct {
+	pubsub *redis.PubSub
+	log    *slog.Logger
+
+	mu       sync.Mutex
+	channels map[string]*hubChannel
+}
+
+// hubChannel is one progress channel's listeners. The channel's SUBSCRIBE
+// and UNSUBSCRIBE are sent without holding Hub.mu, so a slow Redis does not
+// stall dispatch or other channels; ops orders them instead.
+type hubChannel struct {
+	listeners map[chan Progress]struct{} // guarded by Hub.mu
+
+	ops        sync.Mutex
+	subscribed bool // guarded by ops
+}
+
+func NewHub(client redis.UniversalClient, log *slog.Logger) *Hub {
+	return &Hub{
+		pubsub:   client.Subscribe(context.Background()),
+		log:      log,
+		channels: make(map[string]*hubChannel),
+	}
+}
+
+// Run dispatches messages to listeners until ctx is cancelled, then closes
+// the Pub/Sub connection.
+func (h *Hub) Run(ctx context.Context) {
+	msgs := h.pubsub.Channel()
+	defer h.pubsub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			var p Progress
+			if err := json.Unmarshal([]byte(msg.Payload), &p); err != nil {
+				h.log.WarnContext(ctx, "malformed progress message", "channel", msg.Channel, "error", err)
+				continue
+			}
+			h.dispatch(msg.Channel, p)
+		}
+	}
+}
+
+func (h *Hub) dispatch(channel string, p Progress) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c := h.channels[channel]
+	if c == nil {
+		return
+	}
+	for ch := range c.listeners {
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+// Subscribe registers a listener for the progress of appID and contextKey.
+// The returned cancel func must be called to release the listener.
+func (h *Hub) Subscribe(ctx context.Context, appID, contextKey string) (<-chan Progress, func(), error) {
+	channel := ProgressChannelKey(appID, contextKey)
+	ch := make(chan Progress, listenerBuffer)
+
+	h.mu.Lock()
+	c, ok := h.channels[channel]
+	if !ok {
+		c = &hubChannel{listeners: make(map[chan Progress]struct{})}
+		h.channels[channel] = c
+	}
+	c.listeners[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(c.listeners, ch)
+			h.mu.Unlock()
+			if err := h.sync(context.Background(), channel, c); err != nil {
+				h.log.Warn("progress unsubscribe failed", "channel", channel, "error", err)
+			}
+		})
+	}
+	if err := h.sync(ctx, channel, c); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return ch, cancel, nil
+}
+
+// sync subscribes c's channel if it has listeners and unsubscribes it if it
+// has none, dropping c once it is unsubscribed and still unused.
+func (h *Hub) sync(ctx context.Context, channel string, c *hubChannel) error {
+	c.ops.Lock()
+	defer c.ops.Unlock()
+	for {
+		h.mu.Lock()
+		want := len(c.listeners) > 0
+		if !want && !c.subscribed && h.channels[channel] == c {
+			delete(h.channels, channel)
+		}
+		h.mu.Unlock()
+
+		switch {
+		case want && !c.subscribed:
+			if err := h.pubsub.Subscribe(ctx, channel); err != nil {
+				return err
+			}
+			c.subscribed = true
+		case !want && c.subscribed:
+			if err := h.pubsub.Unsubscribe(context.Background(), channel); err != nil {
+				return err
+			}
+			// A listener may have arrived meanwhile: check again.
+			c.subscribed = false
+			continue
+		}
+		return nil
+	}
+}
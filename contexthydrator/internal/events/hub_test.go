@@ -0,0 +1,69 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/observability"
+)
+
+func TestHub_SubscribeAndRelease(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	hub := NewHub(client, observability.NewLogger("error", "text"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+	channel := ProgressChannelKey("app", "u1")
+
+	// Listeners coming and going concurrently leave the channel subscribed
+	// exactly while someone listens.
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			_, release, err := hub.Subscribe(ctx, "app", "u1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			release()
+		})
+	}
+	wg.Wait()
+	waitForSubscribers(t, mr, channel, 0)
+
+	ch, release, err := hub.Subscribe(ctx, "app", "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	waitForSubscribers(t, mr, channel, 1)
+	if err := NewPublisher(client).PublishProgress(ctx, "app", "u1", Progress{Type: ProgressComplete}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case p := <-ch:
+		if p.Type != ProgressComplete {
+			t.Errorf("progress: got %+v", p)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("progress not delivered")
+	}
+}
+
+// waitForSubscribers waits for Redis to have processed the hub's SUBSCRIBE
+// and UNSUBSCRIBE commands, which are sent without waiting for a reply.
+func waitForSubscribers(t *testing.T, mr *miniredis.Miniredis, channel string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for mr.PubSubNumSub(channel)[channel] != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("subscribers: got %d, want %d", mr.PubSubNumSub(channel)[channel], want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
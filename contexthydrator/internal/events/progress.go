@@ -0,0 +1,42 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+)
+
+// Progress message types.
+const (
+	// ProgressResource reports one resource written to (or failed to reach) the cache.
+	ProgressResource = "resource"
+	// ProgressComplete marks the end of a full hydration run.
+	ProgressComplete = "complete"
+)
+
+// Progress is published on a context's progress channel while it is being
+// hydrated. Unlike HydrationEvent it is always published and never persisted:
+// it only reaches readers subscribed at that moment.
+type Progress struct {
+	Type     string `json:"type"`               // "resource" | "complete"
+	Resource string `json:"resource,omitempty"` // set when type == "resource"
+	Status   string `json:"status,omitempty"`   // "ok" | "failed"
+	Error    string `json:"error,omitempty"`
+}
+
+// PublishProgress sends p to the progress channel for appID and contextKey.
+func (p *Publisher) PublishProgress(ctx context.Context, appID, contextKey string, msg Progress) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal progress: %w", err)
+	}
+	return p.client.Publish(ctx, ProgressChannelKey(appID, contextKey), b).Err()
+}
+
+// ProgressChannelKey returns the Pub/Sub channel carrying hydration progress
+// for one context key.
+func ProgressChannelKey(appID, contextKey string) string {
+	return redisc.KeyPrefixProgress + appID + ":" + contextKey
+}
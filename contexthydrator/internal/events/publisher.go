@@ -0,0 +1,92 @@
+// Package events publishes hydration-complete events so downstream services
+// (e.g. BFFs) can react once a user's context is warm.
+//
+// Each app chooses its sinks (services.EventsConfig):
+//   - Stream: XADD to hyd:stream:{appID}, trimmed with an approximate MAXLEN.
+//     Persistent and replayable via consumer groups.
+//   - PubSub: PUBLISH to hyd:events:{appID}. Lost if nobody is subscribed.
+//
+// Both carry the same JSON document; stream entries hold it in the "event" field.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+// Resource statuses reported in a HydrationEvent.
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// HydrationEvent reports the outcome of one hydration run.
+type HydrationEvent struct {
+	AppID       string                    `json:"app_id"`
+	ContextKey  string                    `json:"context_key"`
+	Resources   map[string]ResourceResult `json:"resources"`
+	ElapsedMS   int64                     `json:"elapsed_ms"`
+	CompletedAt time.Time                 `json:"completed_at"`
+}
+
+// ResourceResult is the outcome for one resource.
+type ResourceResult struct {
+	Status string `json:"status"`          // "ok" | "failed"
+	Error  string `json:"error,omitempty"` // set when status == "failed"
+}
+
+type Publisher struct {
+	client redis.UniversalClient
+}
+
+func NewPublisher(client redis.UniversalClient) *Publisher {
+	return &Publisher{client: client}
+}
+
+// PublishHydration sends ev to the sinks enabled in cfg. Both sinks are
+// attempted; the returned error joins any failures.
+func (p *Publisher) PublishHydration(ctx context.Context, cfg services.EventsConfig, ev *HydrationEvent) error {
+	if !cfg.Stream && !cfg.PubSub {
+		return nil
+	}
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	var errs []error
+	if cfg.Stream {
+		err := p.client.XAdd(ctx, &redis.XAddArgs{
+			Stream: StreamKey(ev.AppID),
+			MaxLen: cfg.StreamMaxLen,
+			Approx: true,
+			Values: map[string]any{"event": b},
+		}).Err()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stream: %w", err))
+		}
+	}
+	if cfg.PubSub {
+		if err := p.client.Publish(ctx, ChannelKey(ev.AppID), b).Err(); err != nil {
+			errs = append(errs, fmt.Errorf("pubsub: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StreamKey returns the hydration event stream for an app.
+func StreamKey(appID string) string {
+	return redisc.KeyPrefixEventStream + appID
+}
+
+// ChannelKey returns the hydration event Pub/Sub channel for an app.
+func ChannelKey(appID string) string {
+	return redisc.KeyPrefixEventChannel + appID
+}
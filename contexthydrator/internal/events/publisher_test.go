@@ -0,0 +1,88 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+func testEvent() *HydrationEvent {
+	return &HydrationEvent{
+		AppID:      "app",
+		ContextKey: "u1",
+		Resources: map[string]ResourceResult{
+			"profile":     {Status: StatusOK},
+			"permissions": {Status: StatusFailed, Error: "upstream permissions: status 503"},
+		},
+		ElapsedMS:   42,
+		CompletedAt: time.Now().UTC(),
+	}
+}
+
+func TestPublishHydration_StreamTrimmed(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	p := NewPublisher(client)
+	ctx := context.Background()
+
+	cfg := services.EventsConfig{Stream: true, StreamMaxLen: 2}
+	for range 5 {
+		if err := p.PublishHydration(ctx, cfg, testEvent()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	msgs, err := client.XRange(ctx, StreamKey("app"), "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("stream length: got %d, want 2", len(msgs))
+	}
+	var got HydrationEvent
+	if err := json.Unmarshal([]byte(msgs[0].Values["event"].(string)), &got); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	if got.Resources["permissions"].Status != StatusFailed {
+		t.Errorf("permissions status: got %q", got.Resources["permissions"].Status)
+	}
+}
+
+func TestPublishHydration_PubSub(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	p := NewPublisher(client)
+	ctx := context.Background()
+
+	sub := client.Subscribe(ctx, ChannelKey("app"))
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := services.EventsConfig{PubSub: true}
+	if err := p.PublishHydration(ctx, cfg, testEvent()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case msg := <-sub.Channel():
+		var got HydrationEvent
+		if err := json.Unmarshal([]byte(msg.Payload), &got); err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		if got.ContextKey != "u1" {
+			t.Errorf("context_key: got %q", got.ContextKey)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event received")
+	}
+	if mr.Exists(StreamKey("app")) {
+		t.Error("stream should not be written when disabled")
+	}
+}
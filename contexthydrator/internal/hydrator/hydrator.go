@@ -2,27 +2,77 @@ package hydrator
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/events"
+	"github.com/yourorg/context-hydrator/internal/metrics"
 	"github.com/yourorg/context-hydrator/internal/services"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/yourorg/context-hydrator/internal/hydrator")
+
 type Hydrator struct {
 	store          *cache.Store
 	backend        *services.Backend
 	log            *slog.Logger
 	backendTimeout time.Duration
+	publisher      *events.Publisher
+	suppressWindow time.Duration
+	statusTTL      time.Duration
+
+	// running holds the {appID}:{contextKey} of hydrations in flight in this process.
+	running sync.Map
+	// revalidating holds the cache keys with a stale-while-revalidate refresh in flight.
+	revalidating sync.Map
+	// reading holds the read-through fetches in flight, by cache key.
+	readMu  sync.Mutex
+	reading map[string]*readCall
+}
+
+// Option configures optional Hydrator features.
+type Option func(*Hydrator)
+
+// WithPublisher publishes a hydration-complete event after every run,
+// to the sinks selected by the app's EventsConfig, and per-resource progress
+// on the context's progress channel.
+func WithPublisher(p *events.Publisher) Option {
+	return func(h *Hydrator) { h.publisher = p }
 }
 
-func New(store *cache.Store, backend *services.Backend, log *slog.Logger, backendTimeout time.Duration) *Hydrator {
-	return &Hydrator{
+// WithSuppressWindow skips hydrations of a contextKey for d after one succeeds,
+// by keeping its Redis lock for d instead of releasing it.
+func WithSuppressWindow(d time.Duration) Option {
+	return func(h *Hydrator) { h.suppressWindow = d }
+}
+
+// WithJobStatusTTL keeps job statuses for GET /hydrate/{jobID} for d instead
+// of DefaultJobStatusTTL.
+func WithJobStatusTTL(d time.Duration) Option {
+	return func(h *Hydrator) { h.statusTTL = d }
+}
+
+func New(store *cache.Store, backend *services.Backend, log *slog.Logger, backendTimeout time.Duration, opts ...Option) *Hydrator {
+	h := &Hydrator{
 		store:          store,
 		backend:        backend,
 		log:            log,
 		backendTimeout: backendTimeout,
+		statusTTL:      DefaultJobStatusTTL,
+		reading:        make(map[string]*readCall),
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // RunHydration executes the full hydration pipeline for a context key.
@@ -31,8 +81,78 @@ func New(store *cache.Store, backend *services.Backend, log *slog.Logger, backen
 // appConfig defines which resources to fetch and their URL templates.
 // contextKey is the namespaced identity (e.g. "user-123" or "user-123:profile-456").
 // claims are the key-value pairs substituted into URL templates (e.g. {"user_id": "123"}).
+//
+// Only one hydration per contextKey runs at a time: concurrent calls in this
+// process return immediately, and across instances the Redis lock
+// hyd:lock:{appID}:{contextKey} does the same. The lock is kept for the
+// suppression window after a successful run, so repeat logins and retries
+// within it are skipped too.
 func (h *Hydrator) RunHydration(bgCtx context.Context, appConfig *services.AppConfig, contextKey string, claims map[string]string) {
-	start := time.Now()
+	h.Run(bgCtx, appConfig, contextKey, claims)
+}
+
+// Outcome summarises one hydration run.
+type Outcome struct {
+	// Skipped is set when the run was deduplicated: a hydration of the same
+	// contextKey was running or completed within the suppression window.
+	Skipped   bool
+	Succeeded int
+	Failed    int
+}
+
+// Run is RunHydration for callers that act on the result. If bgCtx carries a
+// job ID (ContextWithJobID), the run's progress and final state are recorded
+// in its JobStatus.
+func (h *Hydrator) Run(bgCtx context.Context, appConfig *services.AppConfig, contextKey string, claims map[string]string) Outcome {
+	job := h.startJob(bgCtx, appConfig.AppID)
+	out := h.run(bgCtx, appConfig, contextKey, claims, job)
+	job.finish(bgCtx, out.state())
+	return out
+}
+
+// RunAttempt is Run for callers that may run the job again, such as the job
+// worker retrying runs in which every resource failed. It records the run's
+// progress but leaves the job running: the caller settles its state with
+// FinishJob once it knows whether the job is done.
+func (h *Hydrator) RunAttempt(bgCtx context.Context, appConfig *services.AppConfig, contextKey string, claims map[string]string) Outcome {
+	return h.run(bgCtx, appConfig, contextKey, claims, h.startJob(bgCtx, appConfig.AppID))
+}
+
+// state is the final job state of a run that will not be retried.
+func (o Outcome) state() string {
+	if o.Skipped {
+		return JobSkipped
+	}
+	return JobComplete
+}
+
+func (h *Hydrator) run(bgCtx context.Context, appConfig *services.AppConfig, contextKey string, claims map[string]string, job *jobRun) Outcome {
+	runKey := appConfig.AppID + ":" + contextKey
+	if _, running := h.running.LoadOrStore(runKey, struct{}{}); running {
+		h.deduplicated(bgCtx, appConfig.AppID, contextKey, "in_process")
+		return Outcome{Skipped: true}
+	}
+	defer h.running.Delete(runKey)
+
+	token := rand.Text()
+	locked, err := h.store.AcquireLock(bgCtx, appConfig.AppID, contextKey, token, h.backendTimeout+lockGrace)
+	if err != nil {
+		// Fail open: a Redis outage should not stop hydration.
+		h.log.WarnContext(bgCtx, "hydration lock unavailable",
+			"app_id", appConfig.AppID, "context_key", contextKey, "error", err)
+	} else if !locked {
+		h.deduplicated(bgCtx, appConfig.AppID, contextKey, "lock")
+		return Outcome{Skipped: true}
+	}
+	job.running(bgCtx)
+
+	// The run outlives the request that started it, so it gets its own trace,
+	// linked to the request span carried by bgCtx (if any).
+	bgCtx, span := tracer.Start(bgCtx, "hydration",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(bgCtx)),
+		trace.WithAttributes(attribute.String("app_id", appConfig.AppID)))
+	defer span.End()
 
 	ctx, cancel := context.WithTimeout(bgCtx, h.backendTimeout)
 	defer cancel()
@@ -40,15 +160,119 @@ func (h *Hydrator) RunHydration(bgCtx context.Context, appConfig *services.AppCo
 	// Step 1: resolve which resources to fetch
 	resourcesToFetch := ResolveResources(ctx, h.store, appConfig, contextKey, h.log)
 
+	succeeded, failed := h.hydrate(ctx, bgCtx, appConfig, contextKey, claims, resourcesToFetch, job, true)
+
+	if locked {
+		h.unlock(bgCtx, appConfig.AppID, contextKey, token, succeeded > 0)
+	}
+
+	// Tell readers waiting on /context/{contextKey}/events that this run is
+	// done; resources it did not fetch will not arrive.
+	h.progress(bgCtx, appConfig.AppID, contextKey, events.Progress{Type: events.ProgressComplete})
+	return Outcome{Succeeded: succeeded, Failed: failed}
+}
+
+// Revalidate starts a background refresh of one resource whose cached entry is
+// close to expiry, using the claims recorded by the last hydration of
+// contextKey. Concurrent calls for the same entry share one refresh. A refresh
+// publishes no hydration event or progress: it is not a hydration, and
+// consumers would take it for one that fetched only this resource.
+//
+// Returns true if a refresh is in flight when the call returns; false when
+// there are no recorded claims to refresh with.
+func (h *Hydrator) Revalidate(ctx context.Context, appConfig *services.AppConfig, contextKey string, resource services.ServiceName) bool {
+	key := cache.ResourceCacheKey(appConfig.AppID, string(resource), contextKey)
+	if _, running := h.revalidating.Load(key); running {
+		return true
+	}
+
+	claims, err := h.store.GetClaims(ctx, appConfig.AppID, contextKey)
+	if err != nil {
+		if !errors.Is(err, cache.ErrCacheMiss) {
+			h.log.WarnContext(ctx, "revalidation skipped, claims unavailable",
+				"app_id", appConfig.AppID, "context_key", contextKey, "resource", resource, "error", err)
+		}
+		return false
+	}
+	if _, running := h.revalidating.LoadOrStore(key, struct{}{}); running {
+		return true
+	}
+
+	go func() {
+		defer h.revalidating.Delete(key)
+
+		bgCtx, span := tracer.Start(context.Background(), "revalidate",
+			trace.WithAttributes(attribute.String("app_id", appConfig.AppID), attribute.String("resource", string(resource))))
+		defer span.End()
+		ctx, cancel := context.WithTimeout(bgCtx, h.backendTimeout)
+		defer cancel()
+
+		h.hydrate(ctx, bgCtx, appConfig, contextKey, claims, []services.ServiceName{resource}, nil, false)
+	}()
+	return true
+}
+
+// lockGrace is added to the backend timeout for the lock TTL, covering the
+// cache writes after the last fetch returns.
+const lockGrace = 5 * time.Second
+
+func (h *Hydrator) deduplicated(ctx context.Context, appID, contextKey, reason string) {
+	metrics.HydrationsDeduplicated.WithLabelValues(appID, reason).Inc()
+	h.log.DebugContext(ctx, "hydration skipped, already running or recently completed",
+		"app_id", appID, "context_key", contextKey, "reason", reason)
+}
+
+// unlock releases the hydration lock, or holds it for the suppression window
+// if the run cached anything.
+func (h *Hydrator) unlock(ctx context.Context, appID, contextKey, token string, succeeded bool) {
+	var err error
+	if succeeded && h.suppressWindow > 0 {
+		err = h.store.HoldLock(ctx, appID, contextKey, token, h.suppressWindow)
+	} else {
+		err = h.store.ReleaseLock(ctx, appID, contextKey, token)
+	}
+	if err != nil {
+		h.log.WarnContext(ctx, "hydration lock release failed",
+			"app_id", appID, "context_key", contextKey, "error", err)
+	}
+}
+
+// hydrate fetches resources from the backends and writes successes to the
+// cache. ctx bounds the backend calls; bgCtx is used for cache writes and logs.
+// Per-resource outcomes are recorded on job, if any, and with publish also
+// reported as progress and in a hydration event. It returns the number of
+// resources cached and the number that failed.
+func (h *Hydrator) hydrate(ctx, bgCtx context.Context, appConfig *services.AppConfig, contextKey string, claims map[string]string, resources []services.ServiceName, job *jobRun, publish bool) (int, int) {
+	start := time.Now()
+
+	inFlight := metrics.HydrationsInFlight.WithLabelValues(appConfig.AppID)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	// Record the claims so the reader can revalidate entries without the
+	// hydration token. They live as long as the longest-lived resource.
+	if err := h.store.StoreClaims(bgCtx, appConfig.AppID, contextKey, claims, maxTTL(appConfig)); err != nil {
+		h.log.WarnContext(bgCtx, "claims write failed",
+			"app_id", appConfig.AppID, "context_key", contextKey, "error", err)
+	}
+
 	// Step 2: parallel backend calls using URL templates
-	results := h.backend.FetchWithConfig(ctx, appConfig, resourcesToFetch, claims)
+	results := h.backend.StreamWithConfig(ctx, appConfig, resources, claims)
 
-	// Step 3: write successful results to cache
+	// Step 3: write each successful result to cache as it arrives, so fast
+	// resources are readable while slow ones are still being fetched.
 	var successCount, failCount int
-	for _, result := range results {
+	outcomes := make(map[string]events.ResourceResult, len(resources))
+	for result := range results {
 		if result.Err != nil {
 			failCount++
-			h.log.WarnContext(bgCtx, "backend fetch failed",
+			metrics.HydrationErrors.WithLabelValues(appConfig.AppID, string(result.Service), metrics.StageFetch).Inc()
+			h.record(bgCtx, appConfig.AppID, contextKey, outcomes, job, publish, result, events.ResourceResult{Status: events.StatusFailed, Error: result.Err.Error()})
+			msg := "backend fetch failed"
+			if errors.Is(result.Err, services.ErrCircuitOpen) {
+				msg = "circuit open"
+			}
+			h.log.WarnContext(bgCtx, msg,
 				"app_id", appConfig.AppID,
 				"context_key", contextKey,
 				"service", result.Service,
@@ -59,12 +283,14 @@ func (h *Hydrator) RunHydration(bgCtx context.Context, appConfig *services.AppCo
 		resCfg, ok := appConfig.Resources[result.Service]
 		if !ok {
 			failCount++
+			h.record(bgCtx, appConfig.AppID, contextKey, outcomes, job, publish, result, events.ResourceResult{Status: events.StatusFailed, Error: "unknown resource"})
 			continue
 		}
 
-		cacheKey := cache.ResourceCacheKey(appConfig.AppID, string(result.Service), contextKey)
-		if err := h.store.Set(bgCtx, cacheKey, result.Data, resCfg.TTL); err != nil {
+		if err := h.write(bgCtx, appConfig.AppID, contextKey, result, resCfg.TTL); err != nil {
 			failCount++
+			metrics.HydrationErrors.WithLabelValues(appConfig.AppID, string(result.Service), metrics.StageCacheWrite).Inc()
+			h.record(bgCtx, appConfig.AppID, contextKey, outcomes, job, publish, result, events.ResourceResult{Status: events.StatusFailed, Error: "cache write failed"})
 			h.log.WarnContext(bgCtx, "cache write failed",
 				"app_id", appConfig.AppID,
 				"context_key", contextKey,
@@ -73,13 +299,78 @@ func (h *Hydrator) RunHydration(bgCtx context.Context, appConfig *services.AppCo
 			continue
 		}
 		successCount++
+		metrics.HydrationLatency.WithLabelValues(appConfig.AppID, string(result.Service)).Observe(metrics.Milliseconds(time.Since(start)))
+		h.record(bgCtx, appConfig.AppID, contextKey, outcomes, job, publish, result, events.ResourceResult{Status: events.StatusOK})
 	}
 
+	elapsed := time.Since(start)
 	h.log.InfoContext(bgCtx, "hydration complete",
 		"app_id", appConfig.AppID,
 		"context_key", contextKey,
 		"success_count", successCount,
 		"fail_count", failCount,
-		"elapsed_ms", time.Since(start).Milliseconds(),
+		"elapsed_ms", elapsed.Milliseconds(),
 	)
+
+	if publish && h.publisher != nil {
+		ev := &events.HydrationEvent{
+			AppID:       appConfig.AppID,
+			ContextKey:  contextKey,
+			Resources:   outcomes,
+			ElapsedMS:   elapsed.Milliseconds(),
+			CompletedAt: time.Now().UTC(),
+		}
+		if err := h.publisher.PublishHydration(bgCtx, appConfig.Events, ev); err != nil {
+			h.log.WarnContext(bgCtx, "hydration event publish failed",
+				"app_id", appConfig.AppID, "context_key", contextKey, "error", err)
+		}
+	}
+	return successCount, failCount
+}
+
+// write caches one fetched resource under its own span.
+func (h *Hydrator) write(ctx context.Context, appID, contextKey string, result services.ServiceResult, ttl time.Duration) error {
+	ctx, span := tracer.Start(ctx, "cache.write",
+		trace.WithAttributes(attribute.String("resource", string(result.Service)), attribute.Int("bytes", len(result.Data))))
+	defer span.End()
+
+	err := h.store.SetResource(ctx, appID, string(result.Service), contextKey, result.Data, ttl)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "cache write failed")
+	}
+	return err
+}
+
+// record stores the outcome for one resource and reports it on the job status
+// and, with publish, the context's progress channel as soon as it is known.
+func (h *Hydrator) record(ctx context.Context, appID, contextKey string, outcomes map[string]events.ResourceResult, job *jobRun, publish bool, result services.ServiceResult, res events.ResourceResult) {
+	outcomes[string(result.Service)] = res
+	if publish {
+		h.progress(ctx, appID, contextKey, events.Progress{
+			Type:     events.ProgressResource,
+			Resource: string(result.Service),
+			Status:   res.Status,
+			Error:    res.Error,
+		})
+	}
+	job.resource(ctx, result.Service, res.Status, res.Error, result.Elapsed)
+}
+
+func (h *Hydrator) progress(ctx context.Context, appID, contextKey string, p events.Progress) {
+	if h.publisher == nil {
+		return
+	}
+	if err := h.publisher.PublishProgress(ctx, appID, contextKey, p); err != nil {
+		h.log.WarnContext(ctx, "progress publish failed",
+			"app_id", appID, "context_key", contextKey, "error", err)
+	}
+}
+
+func maxTTL(appConfig *services.AppConfig) time.Duration {
+	var ttl time.Duration
+	for _, res := range appConfig.Resources {
+		ttl = max(ttl, res.TTL)
+	}
+	return ttl
 }
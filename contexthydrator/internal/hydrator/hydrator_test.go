@@ -0,0 +1,199 @@
+package hydrator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/events"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+func TestRunHydration_Deduplicates(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	log := observability.NewLogger("error", "text")
+	backend := services.NewBackend(services.BackendConfig{}, upstream.Client())
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour},
+		},
+	}
+	claims := map[string]string{"user_id": "u1"}
+
+	// Two instances sharing Redis, several concurrent calls on each.
+	a := New(store, backend, log, 2*time.Second, WithSuppressWindow(time.Minute))
+	b := New(store, backend, log, 2*time.Second, WithSuppressWindow(time.Minute))
+
+	var wg sync.WaitGroup
+	wg.Go(func() { a.RunHydration(context.Background(), app, "u1", claims) })
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for _, h := range []*Hydrator{a, a, b, b} {
+		wg.Go(func() { h.RunHydration(context.Background(), app, "u1", claims) })
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("upstream calls while running: got %d, want 1", got)
+	}
+	if ttl := mr.TTL(cache.LockKey("test-app", "u1")); ttl != time.Minute {
+		t.Errorf("lock TTL after success: got %v, want the suppression window", ttl)
+	}
+
+	// Suppressed within the window, on either instance.
+	b.RunHydration(context.Background(), app, "u1", claims)
+	if got := calls.Load(); got != 1 {
+		t.Errorf("upstream calls within suppression window: got %d, want 1", got)
+	}
+
+	// Runs again once the window lapses.
+	mr.FastForward(time.Minute)
+	b.RunHydration(context.Background(), app, "u1", claims)
+	if got := calls.Load(); got != 2 {
+		t.Errorf("upstream calls after window: got %d, want 2", got)
+	}
+}
+
+func TestRunHydration_ReleasesLockWithoutWindow(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	h := New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()),
+		observability.NewLogger("error", "text"), time.Second)
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour},
+		},
+	}
+
+	h.RunHydration(context.Background(), app, "u1", map[string]string{"user_id": "u1"})
+	if mr.Exists(cache.LockKey("test-app", "u1")) {
+		t.Error("lock should be released when no suppression window is set")
+	}
+}
+
+func TestRevalidate_PublishesNothing(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := cache.NewStore(cache.NewRedisBackend(client))
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour},
+		},
+		Events: services.EventsConfig{Stream: true, StreamMaxLen: 100},
+	}
+	h := New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()), observability.NewLogger("error", "text"), time.Second,
+		WithPublisher(events.NewPublisher(client)))
+	ctx := context.Background()
+	if err := store.StoreClaims(ctx, "test-app", "u1", map[string]string{"user_id": "u1"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	progress := client.Subscribe(ctx, events.ProgressChannelKey("test-app", "u1"))
+	defer progress.Close()
+	if _, err := progress.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if !h.Revalidate(ctx, app, "u1", services.ServiceProfile) {
+		t.Fatal("revalidation not started")
+	}
+	for deadline := time.Now().Add(2 * time.Second); !mr.Exists(cache.ResourceCacheKey("test-app", "profile", "u1")); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("resource not refreshed")
+		}
+	}
+
+	select {
+	case msg := <-progress.Channel():
+		t.Errorf("revalidation published progress: %s", msg.Payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if mr.Exists(events.StreamKey("test-app")) {
+		t.Error("revalidation published a hydration event")
+	}
+}
+
+func TestReadThrough_Deduplicates(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte(`{"name":"Ada"}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	h := New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()), observability.NewLogger("error", "text"), 2*time.Second)
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour, ReadThrough: true},
+		},
+	}
+	session := map[string]string{"user_id": "u1"}
+
+	var wg sync.WaitGroup
+	results := make([]string, 5)
+	for i := range results {
+		wg.Go(func() {
+			data, err := h.ReadThrough(context.Background(), app, "u1", services.ServiceProfile, session)
+			if err != nil {
+				t.Errorf("read-through: %v", err)
+			}
+			results[i] = string(data)
+		})
+	}
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("upstream calls: got %d, want 1", got)
+	}
+	for i, got := range results {
+		if got != `{"name":"Ada"}` {
+			t.Errorf("caller %d: got %q", i, got)
+		}
+	}
+	if !mr.Exists(cache.ResourceCacheKey("test-app", "profile", "u1")) {
+		t.Error("result not cached")
+	}
+}
@@ -4,29 +4,38 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"slices"
 
 	"github.com/yourorg/context-hydrator/internal/cache"
 	"github.com/yourorg/context-hydrator/internal/services"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // ResolveResources returns the resource list from the access pattern cache,
 // falling back to all resources defined in appConfig if not found or on error.
-func ResolveResources(ctx context.Context, store *cache.Store, appConfig *services.AppConfig, contextKey string, log *slog.Logger) []services.ServiceName {
+func ResolveResources(ctx context.Context, store *cache.Store, appConfig *services.AppConfig, contextKey string, log *slog.Logger) (resolved []services.ServiceName) {
+	ctx, span := tracer.Start(ctx, "ResolveResources")
+	source := "access_pattern"
+	defer func() {
+		span.SetAttributes(attribute.String("source", source), attribute.Int("resources", len(resolved)))
+		span.End()
+	}()
+
 	resources, err := store.GetAccessPattern(ctx, appConfig.AppID, contextKey)
 	if err != nil {
 		if !errors.Is(err, cache.ErrCacheMiss) {
 			log.WarnContext(ctx, "failed to load access pattern, using defaults",
 				"app_id", appConfig.AppID, "context_key", contextKey, "error", err)
 		}
-		return allConfiguredResources(appConfig)
+		source = "default"
+		return appConfig.ResourceNames()
 	}
 
 	// Validate against app's configured resources
 	valid := make([]services.ServiceName, 0, len(resources))
 	for _, r := range resources {
-		svc := services.ServiceName(r)
-		if _, ok := appConfig.Resources[svc]; ok {
-			valid = append(valid, svc)
+		if appConfig.HasResource(r) {
+			valid = append(valid, services.ServiceName(r))
 		} else {
 			log.WarnContext(ctx, "unknown resource in access pattern, skipping",
 				"app_id", appConfig.AppID, "context_key", contextKey, "resource", r)
@@ -34,15 +43,15 @@ func ResolveResources(ctx context.Context, store *cache.Store, appConfig *servic
 	}
 
 	if len(valid) == 0 {
-		return allConfiguredResources(appConfig)
+		source = "default"
+		return appConfig.ResourceNames()
 	}
-	return valid
-}
 
-func allConfiguredResources(appConfig *services.AppConfig) []services.ServiceName {
-	out := make([]services.ServiceName, 0, len(appConfig.Resources))
-	for name := range appConfig.Resources {
-		out = append(out, name)
+	// The pattern may predate a change to the app's always-include set.
+	for _, r := range appConfig.AccessPatterns.AlwaysInclude {
+		if appConfig.HasResource(string(r)) && !slices.Contains(valid, r) {
+			valid = append(valid, r)
+		}
 	}
-	return out
+	return valid
 }
@@ -0,0 +1,111 @@
+package hydrator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/yourorg/context-hydrator/internal/metrics"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+var (
+	// ErrQueueFull is returned by Submit when every worker is busy and the
+	// queue is at capacity.
+	ErrQueueFull = errors.New("hydration queue full")
+	// ErrPoolClosed is returned by Submit once Drain has been called.
+	ErrPoolClosed = errors.New("hydration pool closed")
+)
+
+type job struct {
+	ctx        context.Context
+	appConfig  *services.AppConfig
+	contextKey string
+	claims     map[string]string
+}
+
+// Pool runs hydrations on a fixed number of workers fed by a bounded queue,
+// so a login storm queues work instead of spawning unbounded goroutines.
+type Pool struct {
+	h       *Hydrator
+	workers int
+	jobs    chan job
+	busy    atomic.Int64
+
+	mu     sync.RWMutex // guards closed and sends on jobs
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewPool starts workers goroutines running hydrations from a queue holding
+// up to queueSize jobs.
+func NewPool(h *Hydrator, workers, queueSize int) *Pool {
+	p := &Pool{
+		h:       h,
+		workers: max(workers, 1),
+		jobs:    make(chan job, max(queueSize, 0)),
+	}
+	metrics.HydrationWorkers.Set(float64(p.workers))
+	p.wg.Add(p.workers)
+	for range p.workers {
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues a hydration without blocking. bgCtx is passed to RunHydration
+// as-is; it must not be cancelled with the request.
+func (p *Pool) Submit(bgCtx context.Context, appConfig *services.AppConfig, contextKey string, claims map[string]string) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+	select {
+	case p.jobs <- job{ctx: bgCtx, appConfig: appConfig, contextKey: contextKey, claims: claims}:
+		metrics.HydrationQueueDepth.Set(float64(len(p.jobs)))
+		return nil
+	default:
+		metrics.HydrationsRejected.WithLabelValues(appConfig.AppID).Inc()
+		return ErrQueueFull
+	}
+}
+
+// Stats reports the current queue depth and busy workers.
+func (p *Pool) Stats() (queued, busy, workers int) {
+	return len(p.jobs), int(p.busy.Load()), p.workers
+}
+
+// Drain stops accepting work and waits for queued and running hydrations to
+// finish, or for ctx to be done. It returns ctx.Err() if the wait was cut short.
+func (p *Pool) Drain(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for j := range p.jobs {
+		metrics.HydrationQueueDepth.Set(float64(len(p.jobs)))
+		metrics.HydrationWorkersBusy.Set(float64(p.busy.Add(1)))
+		p.h.RunHydration(j.ctx, j.appConfig, j.contextKey, j.claims)
+		metrics.HydrationWorkersBusy.Set(float64(p.busy.Add(-1)))
+	}
+}
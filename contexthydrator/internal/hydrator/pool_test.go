@@ -0,0 +1,102 @@
+package hydrator
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+func TestPool_QueueFullAndDrain(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	h := New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()),
+		observability.NewLogger("error", "text"), 2*time.Second)
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour},
+		},
+	}
+	submit := func(p *Pool, user string) error {
+		return p.Submit(context.Background(), app, user, map[string]string{"user_id": user})
+	}
+
+	p := NewPool(h, 1, 1)
+	if err := submit(p, "u1"); err != nil {
+		t.Fatalf("first submit: %v", err)
+	}
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := submit(p, "u2"); err != nil {
+		t.Fatalf("queued submit: %v", err)
+	}
+	if err := submit(p, "u3"); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("submit with full queue: got %v, want ErrQueueFull", err)
+	}
+	if queued, busy, workers := p.Stats(); queued != 1 || busy != 1 || workers != 1 {
+		t.Errorf("stats: got queued=%d busy=%d workers=%d", queued, busy, workers)
+	}
+
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := p.Drain(ctx); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("hydrations run: got %d, want 2 (running and queued)", got)
+	}
+	if err := submit(p, "u4"); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("submit after drain: got %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestPool_DrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	mr := miniredis.RunT(t)
+	store := cache.NewStore(cache.NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	h := New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()),
+		observability.NewLogger("error", "text"), 5*time.Second)
+	app := &services.AppConfig{
+		AppID: "test-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: upstream.URL + "/users/{user_id}/profile", TTL: time.Hour},
+		},
+	}
+
+	p := NewPool(h, 1, 1)
+	if err := p.Submit(context.Background(), app, "u1", map[string]string{"user_id": "u1"}); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("drain: got %v, want context.DeadlineExceeded", err)
+	}
+}
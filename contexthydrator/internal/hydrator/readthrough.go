@@ -0,0 +1,92 @@
+package hydrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/metrics"
+	"github.com/yourorg/context-hydrator/internal/services"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrNoClaims is returned by ReadThrough when there are no claims to resolve
+// the resource's URL template with.
+var ErrNoClaims = errors.New("no claims for context key")
+
+// readCall is one read-through fetch, shared by concurrent misses of the
+// same entry.
+type readCall struct {
+	done chan struct{}
+	data json.RawMessage
+	err  error
+}
+
+// ReadThrough fetches one resource on a reader cache miss, caches it with its
+// TTL and returns it. The URL template is resolved with the claims recorded by
+// the contextKey's last hydration, or sessionClaims if there are none.
+// Concurrent misses of the same entry share one fetch; a caller that gives up
+// does not cancel it for the others.
+func (h *Hydrator) ReadThrough(ctx context.Context, appConfig *services.AppConfig, contextKey string, resource services.ServiceName, sessionClaims map[string]string) (json.RawMessage, error) {
+	key := cache.ResourceCacheKey(appConfig.AppID, string(resource), contextKey)
+
+	h.readMu.Lock()
+	call, shared := h.reading[key]
+	if !shared {
+		call = &readCall{done: make(chan struct{})}
+		h.reading[key] = call
+		go func() {
+			call.data, call.err = h.readThrough(trace.SpanContextFromContext(ctx), appConfig, contextKey, resource, sessionClaims)
+			h.readMu.Lock()
+			delete(h.reading, key)
+			h.readMu.Unlock()
+			close(call.done)
+		}()
+	}
+	h.readMu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.data, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (h *Hydrator) readThrough(parent trace.SpanContext, appConfig *services.AppConfig, contextKey string, resource services.ServiceName, sessionClaims map[string]string) (json.RawMessage, error) {
+	bgCtx, span := tracer.Start(trace.ContextWithSpanContext(context.Background(), parent), "read_through",
+		trace.WithAttributes(attribute.String("app_id", appConfig.AppID), attribute.String("resource", string(resource))))
+	defer span.End()
+	ctx, cancel := context.WithTimeout(bgCtx, h.backendTimeout)
+	defer cancel()
+
+	claims, err := h.store.GetClaims(ctx, appConfig.AppID, contextKey)
+	if err != nil {
+		if !errors.Is(err, cache.ErrCacheMiss) {
+			h.log.WarnContext(bgCtx, "claims read failed",
+				"app_id", appConfig.AppID, "context_key", contextKey, "error", err)
+		}
+		claims = sessionClaims
+	}
+	if len(claims) == 0 {
+		metrics.ReadThroughs.WithLabelValues(appConfig.AppID, string(resource), "failed").Inc()
+		return nil, ErrNoClaims
+	}
+
+	result := <-h.backend.StreamWithConfig(ctx, appConfig, []services.ServiceName{resource}, claims)
+	if result.Err != nil {
+		metrics.ReadThroughs.WithLabelValues(appConfig.AppID, string(resource), "failed").Inc()
+		return nil, result.Err
+	}
+
+	if err := h.write(bgCtx, appConfig.AppID, contextKey, result, appConfig.Resources[resource].TTL); err != nil {
+		// The caller still gets the data; the next read misses again.
+		metrics.HydrationErrors.WithLabelValues(appConfig.AppID, string(resource), metrics.StageCacheWrite).Inc()
+		h.log.WarnContext(bgCtx, "cache write failed",
+			"app_id", appConfig.AppID, "context_key", contextKey, "service", resource, "error", err)
+	}
+	metrics.ReadThroughs.WithLabelValues(appConfig.AppID, string(resource), "ok").Inc()
+	return result.Data, nil
+}
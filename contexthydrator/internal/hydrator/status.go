@@ -0,0 +1,180 @@
+package hydrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+// Job states reported by GET /hydrate/{jobID}.
+const (
+	JobQueued   = "queued"
+	JobRunning  = "running"
+	JobComplete = "complete"
+	// JobSkipped means the hydration was deduplicated: another run of the
+	// same contextKey was in flight or completed within the suppression window.
+	JobSkipped = "skipped"
+	// JobRetrying means a queued job's attempt failed and the job worker will
+	// run it again; JobFailed that it gave up and dead-lettered the job.
+	JobRetrying = "retrying"
+	JobFailed   = "failed"
+)
+
+// DefaultJobStatusTTL is how long job statuses are kept when not configured.
+const DefaultJobStatusTTL = 10 * time.Minute
+
+// JobStatus is the progress of one accepted hydration, stored at
+// hyd:jobstatus:{jobID}.
+type JobStatus struct {
+	JobID       string                    `json:"job_id"`
+	AppID       string                    `json:"app_id"`
+	State       string                    `json:"state"`
+	Resources   map[string]ResourceStatus `json:"resources,omitempty"`
+	QueuedAt    time.Time                 `json:"queued_at"`
+	StartedAt   *time.Time                `json:"started_at,omitempty"`
+	CompletedAt *time.Time                `json:"completed_at,omitempty"`
+}
+
+// ResourceStatus is the outcome of one resource of a hydration job.
+type ResourceStatus struct {
+	Status      string    `json:"status"`          // "ok" | "failed"
+	Error       string    `json:"error,omitempty"` // set when status == "failed"
+	LatencyMS   int64     `json:"latency_ms"`      // upstream fetch, including retries
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// Terminal reports whether the job will not change any more.
+func (s *JobStatus) Terminal() bool {
+	return s.State == JobComplete || s.State == JobSkipped || s.State == JobFailed
+}
+
+type jobIDKey struct{}
+
+// ContextWithJobID tags the hydration started with ctx, so its progress is
+// recorded under jobID. The ID travels with the context through the pool and
+// the job stream.
+func ContextWithJobID(ctx context.Context, jobID string) context.Context {
+	return context.WithValue(ctx, jobIDKey{}, jobID)
+}
+
+// JobIDFromContext returns the job ID set by ContextWithJobID, or "".
+func JobIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(jobIDKey{}).(string)
+	return id
+}
+
+// Accept records a queued job. Call it before handing the hydration off, so
+// the status exists as soon as the job ID is returned.
+func (h *Hydrator) Accept(ctx context.Context, appID, jobID string) error {
+	return h.saveStatus(ctx, &JobStatus{
+		JobID:    jobID,
+		AppID:    appID,
+		State:    JobQueued,
+		QueuedAt: time.Now().UTC(),
+	})
+}
+
+// JobStatus returns the status of jobID, or cache.ErrCacheMiss if it is
+// unknown or has expired.
+func (h *Hydrator) JobStatus(ctx context.Context, jobID string) (*JobStatus, error) {
+	data, err := h.store.Get(ctx, cache.JobStatusKey(jobID))
+	if err != nil {
+		return nil, err
+	}
+	var st JobStatus
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("unmarshal job status: %w", err)
+	}
+	return &st, nil
+}
+
+func (h *Hydrator) saveStatus(ctx context.Context, st *JobStatus) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("marshal job status: %w", err)
+	}
+	return h.store.Set(ctx, cache.JobStatusKey(st.JobID), b, h.statusTTL)
+}
+
+// jobRun updates a job's status as its hydration progresses. A nil *jobRun
+// (no job ID, e.g. a revalidation) records nothing.
+type jobRun struct {
+	h      *Hydrator
+	status *JobStatus
+}
+
+// FinishJob sets the state of jobID after a RunAttempt, once the caller
+// knows whether the job is done (JobComplete, JobSkipped, JobFailed) or will
+// run again (JobRetrying). An empty jobID records nothing.
+func (h *Hydrator) FinishJob(ctx context.Context, appID, jobID, state string) {
+	h.loadJob(ctx, appID, jobID).finish(ctx, state)
+}
+
+// startJob loads the queued status of the job tagged on ctx, if any.
+func (h *Hydrator) startJob(ctx context.Context, appID string) *jobRun {
+	return h.loadJob(ctx, appID, JobIDFromContext(ctx))
+}
+
+func (h *Hydrator) loadJob(ctx context.Context, appID, jobID string) *jobRun {
+	if jobID == "" {
+		return nil
+	}
+	st, err := h.JobStatus(ctx, jobID)
+	if err != nil {
+		// Expired or never written: start over rather than lose the outcome.
+		st = &JobStatus{JobID: jobID, AppID: appID, QueuedAt: time.Now().UTC()}
+	}
+	return &jobRun{h: h, status: st}
+}
+
+func (j *jobRun) running(ctx context.Context) {
+	if j == nil {
+		return
+	}
+	now := time.Now().UTC()
+	j.status.State = JobRunning
+	j.status.StartedAt = &now
+	j.status.CompletedAt = nil
+	j.status.Resources = nil
+	j.save(ctx)
+}
+
+func (j *jobRun) resource(ctx context.Context, name services.ServiceName, status, errMsg string, latency time.Duration) {
+	if j == nil {
+		return
+	}
+	if j.status.Resources == nil {
+		j.status.Resources = make(map[string]ResourceStatus)
+	}
+	j.status.Resources[string(name)] = ResourceStatus{
+		Status:      status,
+		Error:       errMsg,
+		LatencyMS:   latency.Milliseconds(),
+		CompletedAt: time.Now().UTC(),
+	}
+	j.save(ctx)
+}
+
+func (j *jobRun) finish(ctx context.Context, state string) {
+	if j == nil {
+		return
+	}
+	j.status.State = state
+	j.status.CompletedAt = nil
+	if j.status.Terminal() {
+		now := time.Now().UTC()
+		j.status.CompletedAt = &now
+	}
+	j.save(ctx)
+}
+
+func (j *jobRun) save(ctx context.Context) {
+	if err := j.h.saveStatus(ctx, j.status); err != nil {
+		j.h.log.WarnContext(ctx, "job status write failed",
+			"app_id", j.status.AppID, "job_id", j.status.JobID, "error", err)
+	}
+}
@@ -0,0 +1,323 @@
+// Package invalidation implements event-driven cache invalidation.
+//
+// Backends publish an event to the app's invalidation stream when data changes:
+//
+//	XADD hyd:invalidate:{appID} * app_id payments-app context_key u1:acc-99 resource limits
+//
+// The consumer deletes the cached resource (appID:resource:{contextKey}) so the next read misses
+// and the resource is re-hydrated. Events may also be published as a single
+// "event" field holding the JSON object {"app_id", "context_key", "resource"}.
+//
+// The consumer reads the streams of a fixed list of apps and, if discovery is
+// enabled, of every app in the registry, which it re-lists periodically so
+// apps registered at runtime are picked up without a restart.
+package invalidation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"github.com/yourorg/context-hydrator/internal/registry"
+)
+
+const (
+	// ConsumerGroup is shared by all hydrator instances so each event is
+	// processed once.
+	ConsumerGroup = "hydrator"
+
+	readBlock     = 5 * time.Second
+	readCount     = 100
+	deadLetterMax = 10000
+)
+
+// Event identifies one cached resource to invalidate.
+type Event struct {
+	AppID      string `json:"app_id"`
+	ContextKey string `json:"context_key"`
+	Resource   string `json:"resource"`
+}
+
+// errMalformed marks events that can never succeed; they are dead-lettered
+// and acknowledged instead of being retried.
+var errMalformed = errors.New("malformed event")
+
+type Consumer struct {
+	client   redis.UniversalClient
+	store    *cache.Store
+	apps     *registry.Registry
+	log      *slog.Logger
+	consumer string
+	appIDs   []string
+	discover time.Duration
+	block    time.Duration
+}
+
+// NewConsumer creates a consumer for the invalidation streams of appIDs and,
+// if discover is positive, of the registered apps, re-listed every discover.
+// consumer names this instance within the consumer group (e.g. the hostname).
+func NewConsumer(client redis.UniversalClient, store *cache.Store, apps *registry.Registry, log *slog.Logger, consumer string, appIDs []string, discover time.Duration) *Consumer {
+	return &Consumer{
+		client:   client,
+		store:    store,
+		apps:     apps,
+		log:      log,
+		consumer: consumer,
+		appIDs:   appIDs,
+		discover: discover,
+		block:    readBlock,
+	}
+}
+
+// Run consumes invalidation events until ctx is cancelled.
+//
+// Each app's stream is read separately, since the streams of different apps
+// may live in different Redis Cluster slots. Entries delivered to this
+// consumer but not acknowledged (e.g. after a crash or a Redis error while
+// deleting) are re-read from the pending list before new entries are consumed.
+// Discovered apps that are deregistered stop being consumed.
+func (c *Consumer) Run(ctx context.Context) {
+	c.log.InfoContext(ctx, "invalidation consumer started",
+		"app_ids", c.appIDs, "discover", c.discover, "consumer", c.consumer)
+
+	var wg sync.WaitGroup
+	for _, appID := range c.appIDs {
+		c.createGroup(ctx, appID, "$")
+		wg.Go(func() { c.consume(ctx, appID) })
+	}
+
+	if c.discover > 0 {
+		c.discoverApps(ctx, &wg)
+	}
+	wg.Wait()
+	c.log.InfoContext(ctx, "invalidation consumer stopped")
+}
+
+// discoverApps consumes the streams of registered apps that are not in the
+// static list, re-listing them every c.discover until ctx is cancelled.
+func (c *Consumer) discoverApps(ctx context.Context, wg *sync.WaitGroup) {
+	static := make(map[string]bool, len(c.appIDs))
+	for _, appID := range c.appIDs {
+		static[appID] = true
+	}
+	discovered := make(map[string]context.CancelFunc)
+
+	ticker := time.NewTicker(c.discover)
+	defer ticker.Stop()
+	for {
+		c.refreshApps(ctx, wg, static, discovered)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshApps starts consuming newly registered apps and stops consuming
+// deregistered ones. discovered holds the running apps' cancel functions.
+func (c *Consumer) refreshApps(ctx context.Context, wg *sync.WaitGroup, static map[string]bool, discovered map[string]context.CancelFunc) {
+	appIDs, err := c.apps.AppIDs(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			c.log.WarnContext(ctx, "list registered apps failed", "error", err)
+		}
+		return
+	}
+
+	registered := make(map[string]bool, len(appIDs))
+	for _, appID := range appIDs {
+		registered[appID] = true
+		if static[appID] || discovered[appID] != nil {
+			continue
+		}
+		// From the start of the stream, so events published since the app
+		// was registered are still applied.
+		c.createGroup(ctx, appID, "0")
+		appCtx, cancel := context.WithCancel(ctx)
+		discovered[appID] = cancel
+		wg.Go(func() { c.consume(appCtx, appID) })
+		c.log.InfoContext(ctx, "invalidation consumer added app", "app_id", appID)
+	}
+	for appID, cancel := range discovered {
+		if !registered[appID] {
+			cancel()
+			delete(discovered, appID)
+			c.log.InfoContext(ctx, "invalidation consumer removed app", "app_id", appID)
+		}
+	}
+}
+
+// createGroup creates the consumer group of appID's stream, starting at id,
+// unless it exists.
+func (c *Consumer) createGroup(ctx context.Context, appID, id string) {
+	err := c.client.XGroupCreateMkStream(ctx, StreamKey(appID), ConsumerGroup, id).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		c.log.ErrorContext(ctx, "create invalidation consumer group failed", "app_id", appID, "error", err)
+	}
+}
+
+// consume reads one app's stream until ctx is cancelled.
+func (c *Consumer) consume(ctx context.Context, appID string) {
+	readPending := true
+	for ctx.Err() == nil {
+		startID := ">"
+		if readPending {
+			startID = "0"
+		}
+
+		res, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    ConsumerGroup,
+			Consumer: c.consumer,
+			Streams:  []string{StreamKey(appID), startID},
+			Count:    readCount,
+			Block:    c.block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				c.log.WarnContext(ctx, "invalidation read failed", "app_id", appID, "error", err)
+				sleep(ctx, time.Second)
+			}
+			continue
+		}
+
+		var delivered, failed int
+		for _, stream := range res {
+			for _, msg := range stream.Messages {
+				delivered++
+				if !c.handle(ctx, appID, msg) {
+					failed++
+				}
+			}
+		}
+
+		// Keep draining the pending list until it is empty, then switch to new
+		// entries. A transient failure sends us back to the pending list.
+		readPending = (readPending && delivered > 0) || failed > 0
+		if failed > 0 {
+			sleep(ctx, time.Second)
+		}
+	}
+}
+
+// handle processes one stream entry. It returns false if the entry was left
+// pending for a retry.
+func (c *Consumer) handle(ctx context.Context, streamAppID string, msg redis.XMessage) bool {
+	err := c.apply(ctx, streamAppID, msg.Values)
+	switch {
+	case err == nil:
+	case errors.Is(err, errMalformed):
+		c.log.WarnContext(ctx, "invalidation event dead-lettered",
+			"app_id", streamAppID, "id", msg.ID, "error", err)
+		if err := c.deadLetter(ctx, streamAppID, msg, err); err != nil {
+			c.log.ErrorContext(ctx, "dead-letter write failed", "app_id", streamAppID, "id", msg.ID, "error", err)
+			return false
+		}
+	default:
+		c.log.WarnContext(ctx, "invalidation failed, will retry",
+			"app_id", streamAppID, "id", msg.ID, "error", err)
+		return false
+	}
+
+	if err := c.client.XAck(ctx, StreamKey(streamAppID), ConsumerGroup, msg.ID).Err(); err != nil {
+		c.log.WarnContext(ctx, "invalidation ack failed", "app_id", streamAppID, "id", msg.ID, "error", err)
+		return false
+	}
+	return true
+}
+
+func (c *Consumer) apply(ctx context.Context, streamAppID string, values map[string]any) error {
+	ev, err := ParseEvent(values)
+	if err != nil {
+		return err
+	}
+	// An event may only invalidate keys of the app whose stream it was
+	// published on.
+	if ev.AppID != "" && ev.AppID != streamAppID {
+		return fmt.Errorf("%w: app_id %q published on stream for %q", errMalformed, ev.AppID, streamAppID)
+	}
+
+	appConfig, err := c.apps.AppConfig(ctx, streamAppID)
+	if errors.Is(err, registry.ErrAppNotFound) {
+		return fmt.Errorf("%w: unknown app %q", errMalformed, streamAppID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, ok := cache.KeyForResource(appConfig, ev.ContextKey, ev.Resource); !ok {
+		return fmt.Errorf("%w: unknown resource %q", errMalformed, ev.Resource)
+	}
+	if err := c.store.DeleteResource(ctx, appConfig.AppID, ev.Resource, ev.ContextKey); err != nil {
+		return err
+	}
+
+	c.log.InfoContext(ctx, "cache invalidated",
+		"app_id", streamAppID, "context_key", ev.ContextKey, "resource", ev.Resource)
+	return nil
+}
+
+func (c *Consumer) deadLetter(ctx context.Context, appID string, msg redis.XMessage, cause error) error {
+	values := make(map[string]any, len(msg.Values)+2)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["source_id"] = msg.ID
+	values["error"] = cause.Error()
+	return c.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: DeadLetterKey(appID),
+		MaxLen: deadLetterMax,
+		Approx: true,
+		Values: values,
+	}).Err()
+}
+
+// ParseEvent reads an event from stream entry fields, accepting either
+// individual app_id/context_key/resource fields or a JSON "event" field.
+func ParseEvent(values map[string]any) (Event, error) {
+	var ev Event
+	if raw, ok := values["event"].(string); ok {
+		if err := json.Unmarshal([]byte(raw), &ev); err != nil {
+			return Event{}, fmt.Errorf("%w: %v", errMalformed, err)
+		}
+	} else {
+		ev.AppID, _ = values["app_id"].(string)
+		ev.ContextKey, _ = values["context_key"].(string)
+		ev.Resource, _ = values["resource"].(string)
+	}
+	if ev.ContextKey == "" {
+		return Event{}, fmt.Errorf("%w: missing context_key", errMalformed)
+	}
+	if ev.Resource == "" {
+		return Event{}, fmt.Errorf("%w: missing resource", errMalformed)
+	}
+	return ev, nil
+}
+
+// StreamKey returns the invalidation stream for an app.
+func StreamKey(appID string) string {
+	return redisc.KeyPrefixInvalidate + appID
+}
+
+// DeadLetterKey returns the stream holding an app's malformed events.
+func DeadLetterKey(appID string) string {
+	return redisc.KeyPrefixInvalidateDLQ + appID
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,151 @@
+package invalidation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	"github.com/yourorg/context-hydrator/internal/registry"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+func TestParseEvent_Fields(t *testing.T) {
+	ev, err := ParseEvent(map[string]any{"app_id": "a", "context_key": "u1", "resource": "profile"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ev.ContextKey != "u1" || ev.Resource != "profile" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
+
+func TestParseEvent_JSON(t *testing.T) {
+	ev, err := ParseEvent(map[string]any{"event": `{"app_id":"a","context_key":"u1","resource":"limits"}`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ev.Resource != "limits" {
+		t.Errorf("resource: got %q, want %q", ev.Resource, "limits")
+	}
+}
+
+func TestParseEvent_Malformed(t *testing.T) {
+	for _, values := range []map[string]any{
+		{"context_key": "u1"},
+		{"resource": "profile"},
+		{"event": `{not json`},
+	} {
+		if _, err := ParseEvent(values); !errors.Is(err, errMalformed) {
+			t.Errorf("%v: expected errMalformed, got %v", values, err)
+		}
+	}
+}
+
+func TestConsumer_InvalidatesAndDeadLetters(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := cache.NewStore(cache.NewRedisBackend(client))
+	apps := registry.New(store, time.Minute, &services.AppConfig{
+		AppID: "app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: "http://svc/{user_id}", TTL: time.Hour},
+		},
+	})
+	log := observability.NewLogger("error", "text")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	key := cache.ResourceCacheKey("app", "profile", "u1")
+	if err := store.Set(ctx, key, json.RawMessage(`{}`), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewConsumer(client, store, apps, log, "test", []string{"app"}, 0)
+	c.block = 50 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+
+	// Wait for the consumer group to exist before publishing.
+	waitFor(t, func() bool { return mr.Exists(StreamKey("app")) })
+
+	client.XAdd(ctx, &redis.XAddArgs{Stream: StreamKey("app"), Values: map[string]any{
+		"app_id": "app", "context_key": "u1", "resource": "profile",
+	}})
+	client.XAdd(ctx, &redis.XAddArgs{Stream: StreamKey("app"), Values: map[string]any{
+		"app_id": "app", "context_key": "u1", "resource": "bogus",
+	}})
+
+	waitFor(t, func() bool { return !mr.Exists(key) })
+	waitFor(t, func() bool {
+		n, _ := client.XLen(ctx, DeadLetterKey("app")).Result()
+		return n == 1
+	})
+	waitFor(t, func() bool {
+		pending, _ := client.XPending(ctx, StreamKey("app"), ConsumerGroup).Result()
+		return pending != nil && pending.Count == 0
+	})
+
+	cancel()
+	<-done
+}
+
+func TestConsumer_DiscoversRegisteredApps(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := cache.NewStore(cache.NewRedisBackend(client))
+	apps := registry.New(store, time.Minute)
+	log := observability.NewLogger("error", "text")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewConsumer(client, store, apps, log, "test", nil, 20*time.Millisecond)
+	c.block = 50 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+
+	// Registered while the consumer runs, and published to before the
+	// consumer has picked the app up.
+	reg := &registry.AppRegistration{
+		AppID:     "new-app",
+		Resources: map[string]registry.ResourceRegistration{"profile": {URL: "http://svc/{user_id}", TTL: "1h"}},
+	}
+	if err := apps.Register(ctx, reg); err != nil {
+		t.Fatal(err)
+	}
+	key := cache.ResourceCacheKey("new-app", "profile", "u1")
+	if err := store.Set(ctx, key, json.RawMessage(`{}`), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	client.XAdd(ctx, &redis.XAddArgs{Stream: StreamKey("new-app"), Values: map[string]any{
+		"app_id": "new-app", "context_key": "u1", "resource": "profile",
+	}})
+
+	waitFor(t, func() bool { return !mr.Exists(key) })
+
+	cancel()
+	<-done
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition not met before deadline")
+}
@@ -0,0 +1,96 @@
+// Package jobs is the durable hydration job queue.
+//
+// With HYDRATION_DISPATCH=stream, POST /hydrate resolves the contextKey and
+// claims and appends a job to the hyd:jobs stream instead of hydrating in
+// process:
+//
+//	XADD hyd:jobs MAXLEN ~ 100000 * job {"app_id":"payments-app","context_key":"u1:acc-99","sealed_claims":"..."}
+//
+// The claims are sealed with cache.Store.SealClaims, so they are encrypted
+// like cached resources for apps with an encryption key.
+//
+// cmd/hydration-worker consumes the stream through a consumer group. A job is
+// acknowledged once its hydration runs; if every resource fails it is left
+// pending and redelivered after the visibility timeout, up to a maximum number
+// of deliveries, after which it is moved to hyd:jobs-dlq.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Job is one queued hydration.
+type Job struct {
+	// ID is the job ID returned by POST /hydrate; the worker records the
+	// hydration's progress under it.
+	ID         string `json:"job_id,omitempty"`
+	AppID      string `json:"app_id"`
+	ContextKey string `json:"context_key"`
+	// SealedClaims are the claims to hydrate with, sealed under claimsRef.
+	SealedClaims []byte `json:"sealed_claims,omitempty"`
+	// Claims are plaintext claims of jobs queued before claims were sealed.
+	Claims     map[string]string `json:"claims,omitempty"`
+	EnqueuedAt time.Time         `json:"enqueued_at"`
+	// Trace carries the W3C trace context of the request that queued the
+	// job, so the worker's hydration trace links back to it.
+	Trace map[string]string `json:"trace,omitempty"`
+}
+
+// claimsRef is the name a job's claims are sealed under. It binds them to the
+// job's app and contextKey.
+func claimsRef(appID, contextKey string) string {
+	return redisc.KeyHydrationJobs + ":" + appID + ":" + contextKey
+}
+
+// Queue appends hydration jobs to the job stream.
+type Queue struct {
+	client redis.UniversalClient
+	store  *cache.Store
+	maxLen int64
+}
+
+// NewQueue creates a producer for the job stream, trimmed to about maxLen
+// entries. Trimming can drop pending jobs, so maxLen should be far above the
+// expected backlog. store seals the jobs' claims.
+func NewQueue(client redis.UniversalClient, store *cache.Store, maxLen int64) *Queue {
+	return &Queue{client: client, store: store, maxLen: maxLen}
+}
+
+// Enqueue appends a job for contextKey. ctx's span, if any, is recorded for
+// trace linking, and its job ID (hydrator.ContextWithJobID) is carried along.
+func (q *Queue) Enqueue(ctx context.Context, appID, contextKey string, claims map[string]string) error {
+	sealed, err := q.store.SealClaims(ctx, appID, claimsRef(appID, contextKey), claims)
+	if err != nil {
+		return fmt.Errorf("seal claims: %w", err)
+	}
+	job := Job{
+		ID:           hydrator.JobIDFromContext(ctx),
+		AppID:        appID,
+		ContextKey:   contextKey,
+		SealedClaims: sealed,
+		EnqueuedAt:   time.Now().UTC(),
+		Trace:        map[string]string{},
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(job.Trace))
+
+	b, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("marshal job: %w", err)
+	}
+	return q.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: redisc.KeyHydrationJobs,
+		MaxLen: q.maxLen,
+		Approx: true,
+		Values: map[string]any{"job": b},
+	}).Err()
+}
@@ -0,0 +1,327 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/metrics"
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"github.com/yourorg/context-hydrator/internal/registry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ConsumerGroup is shared by all hydration workers so each job is
+	// processed once.
+	ConsumerGroup = "hydration-workers"
+
+	readBlock     = 5 * time.Second
+	claimBatch    = 10
+	deadLetterMax = 10000
+)
+
+// errMalformed marks jobs that can never succeed; they are dead-lettered on
+// first delivery instead of being retried.
+var errMalformed = errors.New("malformed job")
+
+// WorkerConfig tunes a Worker.
+type WorkerConfig struct {
+	// Concurrency is the number of jobs processed at once.
+	Concurrency int
+	// VisibilityTimeout is how long a delivered job may stay unacknowledged
+	// before another consumer claims it. It must exceed the longest hydration.
+	VisibilityTimeout time.Duration
+	// MaxDeliveries is the number of attempts before a job is dead-lettered.
+	MaxDeliveries int64
+}
+
+// Worker consumes hydration jobs and runs them on a Hydrator.
+type Worker struct {
+	client   redis.UniversalClient
+	store    *cache.Store
+	apps     *registry.Registry
+	hyd      *hydrator.Hydrator
+	log      *slog.Logger
+	consumer string
+	cfg      WorkerConfig
+	block    time.Duration
+}
+
+// NewWorker creates a worker. consumer names this instance within the
+// consumer group (e.g. the hostname); each concurrent loop appends its index.
+// store opens the claims sealed by Queue.
+func NewWorker(client redis.UniversalClient, store *cache.Store, apps *registry.Registry, hyd *hydrator.Hydrator, log *slog.Logger, consumer string, cfg WorkerConfig) *Worker {
+	cfg.Concurrency = max(cfg.Concurrency, 1)
+	cfg.MaxDeliveries = max(cfg.MaxDeliveries, 1)
+	return &Worker{
+		client:   client,
+		store:    store,
+		apps:     apps,
+		hyd:      hyd,
+		log:      log,
+		consumer: consumer,
+		cfg:      cfg,
+		block:    readBlock,
+	}
+}
+
+// Concurrency returns the number of jobs the worker runs at once.
+func (w *Worker) Concurrency() int {
+	return w.cfg.Concurrency
+}
+
+// Run consumes jobs until ctx is cancelled, then returns once every loop has
+// finished the job it was running.
+func (w *Worker) Run(ctx context.Context) {
+	// "0" rather than "$": jobs queued before the first worker started are
+	// still processed.
+	err := w.client.XGroupCreateMkStream(ctx, redisc.KeyHydrationJobs, ConsumerGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		w.log.ErrorContext(ctx, "create hydration job consumer group failed", "error", err)
+	}
+
+	w.log.InfoContext(ctx, "hydration worker started",
+		"consumer", w.consumer, "concurrency", w.cfg.Concurrency)
+
+	var wg sync.WaitGroup
+	for i := range w.cfg.Concurrency {
+		consumer := w.consumer + "-" + strconv.Itoa(i)
+		wg.Go(func() { w.loop(ctx, consumer) })
+	}
+	wg.Wait()
+	w.log.InfoContext(ctx, "hydration worker stopped")
+}
+
+func (w *Worker) loop(ctx context.Context, consumer string) {
+	var lastClaim time.Time
+	for ctx.Err() == nil {
+		// Take over jobs whose consumer died or stalled, including jobs left
+		// pending for a retry.
+		if time.Since(lastClaim) >= w.cfg.VisibilityTimeout/2 {
+			lastClaim = time.Now()
+			if w.reclaim(ctx, consumer) {
+				continue
+			}
+		}
+
+		res, err := w.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    ConsumerGroup,
+			Consumer: consumer,
+			Streams:  []string{redisc.KeyHydrationJobs, ">"},
+			Count:    1,
+			Block:    w.block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				w.log.WarnContext(ctx, "hydration job read failed", "error", err)
+				sleep(ctx, time.Second)
+			}
+			continue
+		}
+		for _, stream := range res {
+			for _, msg := range stream.Messages {
+				w.handle(ctx, msg, 1)
+			}
+		}
+	}
+}
+
+// reclaim claims jobs idle for longer than the visibility timeout and
+// processes them. It returns true if any were claimed.
+func (w *Worker) reclaim(ctx context.Context, consumer string) bool {
+	pending, err := w.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: redisc.KeyHydrationJobs,
+		Group:  ConsumerGroup,
+		Idle:   w.cfg.VisibilityTimeout,
+		Start:  "-",
+		End:    "+",
+		Count:  claimBatch,
+	}).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			w.log.WarnContext(ctx, "hydration job pending check failed", "error", err)
+		}
+		return false
+	}
+	if len(pending) == 0 {
+		return false
+	}
+
+	deliveries := make(map[string]int64, len(pending))
+	ids := make([]string, 0, len(pending))
+	for _, p := range pending {
+		// XCLAIM counts as one more delivery.
+		deliveries[p.ID] = p.RetryCount + 1
+		ids = append(ids, p.ID)
+	}
+
+	msgs, err := w.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   redisc.KeyHydrationJobs,
+		Group:    ConsumerGroup,
+		Consumer: consumer,
+		MinIdle:  w.cfg.VisibilityTimeout,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			w.log.WarnContext(ctx, "hydration job claim failed", "error", err)
+		}
+		return false
+	}
+	for _, msg := range msgs {
+		if ctx.Err() != nil {
+			break
+		}
+		w.handle(ctx, msg, deliveries[msg.ID])
+	}
+	return len(msgs) > 0
+}
+
+// handle processes one delivery of a job. Jobs are acknowledged once
+// hydrated, skipped or dead-lettered; a run in which every resource failed is
+// left pending and redelivered after the visibility timeout. The job status
+// follows: "complete" or "skipped" once acknowledged, "retrying" while left
+// for redelivery and "failed" once dead-lettered.
+func (w *Worker) handle(ctx context.Context, msg redis.XMessage, delivery int64) {
+	// Finish the ack even if shutdown starts mid-job, so it is not redelivered.
+	ctx = context.WithoutCancel(ctx)
+
+	job, err := parseJob(msg.Values)
+	if err != nil {
+		w.deadLetter(ctx, msg, "", err)
+		return
+	}
+	if delivery > w.cfg.MaxDeliveries {
+		// The consumer running it died each time, e.g. it ran out of memory.
+		w.deadLetter(ctx, msg, job.AppID, fmt.Errorf("exceeded %d deliveries", w.cfg.MaxDeliveries))
+		w.hyd.FinishJob(ctx, job.AppID, job.ID, hydrator.JobFailed)
+		return
+	}
+
+	appConfig, err := w.apps.AppConfig(ctx, job.AppID)
+	if errors.Is(err, registry.ErrAppNotFound) {
+		w.deadLetter(ctx, msg, job.AppID, fmt.Errorf("%w: unknown app %q", errMalformed, job.AppID))
+		w.hyd.FinishJob(ctx, job.AppID, job.ID, hydrator.JobFailed)
+		return
+	}
+	if err != nil {
+		w.log.WarnContext(ctx, "hydration job app lookup failed, will retry",
+			"app_id", job.AppID, "id", msg.ID, "error", err)
+		metrics.HydrationJobs.WithLabelValues(job.AppID, "retry").Inc()
+		w.hyd.FinishJob(ctx, job.AppID, job.ID, hydrator.JobRetrying)
+		return
+	}
+
+	claims := job.Claims
+	if job.SealedClaims != nil {
+		claims, err = w.store.OpenClaims(ctx, job.AppID, claimsRef(job.AppID, job.ContextKey), job.SealedClaims)
+		if errors.Is(err, cache.ErrUndecryptable) {
+			// The key may not have reached this instance's keyring yet.
+			w.log.WarnContext(ctx, "hydration job claims undecryptable, will retry",
+				"app_id", job.AppID, "id", msg.ID, "error", err)
+			metrics.HydrationJobs.WithLabelValues(job.AppID, "retry").Inc()
+			w.hyd.FinishJob(ctx, job.AppID, job.ID, hydrator.JobRetrying)
+			return
+		}
+		if err != nil {
+			w.deadLetter(ctx, msg, job.AppID, fmt.Errorf("%w: %v", errMalformed, err))
+			w.hyd.FinishJob(ctx, job.AppID, job.ID, hydrator.JobFailed)
+			return
+		}
+	}
+
+	linked := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(job.Trace))
+	bgCtx := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(linked))
+	if job.ID != "" {
+		bgCtx = hydrator.ContextWithJobID(bgCtx, job.ID)
+	}
+	out := w.hyd.RunAttempt(bgCtx, appConfig, job.ContextKey, claims)
+
+	if out.Succeeded == 0 && out.Failed > 0 {
+		if delivery >= w.cfg.MaxDeliveries {
+			w.deadLetter(ctx, msg, job.AppID, fmt.Errorf("all %d resources failed on delivery %d", out.Failed, delivery))
+			w.hyd.FinishJob(ctx, job.AppID, job.ID, hydrator.JobFailed)
+			return
+		}
+		w.log.WarnContext(ctx, "hydration job failed, will retry",
+			"app_id", job.AppID, "context_key", job.ContextKey, "id", msg.ID, "delivery", delivery)
+		metrics.HydrationJobs.WithLabelValues(job.AppID, "retry").Inc()
+		w.hyd.FinishJob(ctx, job.AppID, job.ID, hydrator.JobRetrying)
+		return
+	}
+
+	outcome, state := "ok", hydrator.JobComplete
+	if out.Skipped {
+		outcome, state = "skipped", hydrator.JobSkipped
+	}
+	metrics.HydrationJobs.WithLabelValues(job.AppID, outcome).Inc()
+	w.ack(ctx, msg.ID)
+	w.hyd.FinishJob(ctx, job.AppID, job.ID, state)
+}
+
+func (w *Worker) deadLetter(ctx context.Context, msg redis.XMessage, appID string, cause error) {
+	w.log.WarnContext(ctx, "hydration job dead-lettered", "app_id", appID, "id", msg.ID, "error", cause)
+	metrics.HydrationJobs.WithLabelValues(appID, "dead_letter").Inc()
+
+	values := make(map[string]any, len(msg.Values)+2)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["source_id"] = msg.ID
+	values["error"] = cause.Error()
+	err := w.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: redisc.KeyHydrationJobsDLQ,
+		MaxLen: deadLetterMax,
+		Approx: true,
+		Values: values,
+	}).Err()
+	if err != nil {
+		// Left pending; the next claim dead-letters it again.
+		w.log.ErrorContext(ctx, "dead-letter write failed", "id", msg.ID, "error", err)
+		return
+	}
+	w.ack(ctx, msg.ID)
+}
+
+func (w *Worker) ack(ctx context.Context, id string) {
+	if err := w.client.XAck(ctx, redisc.KeyHydrationJobs, ConsumerGroup, id).Err(); err != nil {
+		w.log.WarnContext(ctx, "hydration job ack failed", "id", id, "error", err)
+	}
+}
+
+func parseJob(values map[string]any) (*Job, error) {
+	raw, ok := values["job"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing job field", errMalformed)
+	}
+	var job Job
+	if err := json.Unmarshal([]byte(raw), &job); err != nil {
+		return nil, fmt.Errorf("%w: %v", errMalformed, err)
+	}
+	if job.AppID == "" || job.ContextKey == "" {
+		return nil, fmt.Errorf("%w: missing app_id or context_key", errMalformed)
+	}
+	return &job, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/keyring"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"github.com/yourorg/context-hydrator/internal/registry"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+func TestWorker_HydratesRetriesAndDeadLetters(t *testing.T) {
+	var failing atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/broken/") {
+			failing.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	keyFile := filepath.Join(t.TempDir(), "keys.json")
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, keyring.KeySize))
+	if err := os.WriteFile(keyFile, []byte(`{"app": {"current": 1, "keys": {"1": "`+key+`"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys := keyring.NewFileProvider(keyFile, time.Minute)
+	if err := keys.Load(); err != nil {
+		t.Fatal(err)
+	}
+	store := cache.NewStore(cache.NewRedisBackend(client), cache.WithEncryption(keys))
+	log := observability.NewLogger("error", "text")
+	apps := registry.New(store, time.Minute, &services.AppConfig{
+		AppID: "app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: upstream.URL + "/{user_id}/profile", TTL: time.Hour},
+		},
+	})
+	hyd := hydrator.New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()), log, time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Queued before any worker exists: still consumed.
+	q := NewQueue(client, store, 1000)
+	for _, user := range []string{"u1", "broken"} {
+		hyd.Accept(ctx, "app", "job-"+user)
+		if err := q.Enqueue(hydrator.ContextWithJobID(ctx, "job-"+user), "app", user, map[string]string{"user_id": user}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	queued, _ := client.XRange(ctx, redisc.KeyHydrationJobs, "-", "+").Result()
+	for _, msg := range queued {
+		if job := msg.Values["job"].(string); strings.Contains(job, "user_id") {
+			t.Errorf("job claims stored in plaintext: %s", job)
+		}
+	}
+	// Queued before claims were sealed.
+	client.XAdd(ctx, &redis.XAddArgs{Stream: redisc.KeyHydrationJobs, Values: map[string]any{
+		"job": `{"app_id":"app","context_key":"u2","claims":{"user_id":"u2"}}`,
+	}})
+	client.XAdd(ctx, &redis.XAddArgs{Stream: redisc.KeyHydrationJobs, Values: map[string]any{"job": "{not json"}})
+
+	w := NewWorker(client, store, apps, hyd, log, "test", WorkerConfig{
+		Concurrency:       1,
+		VisibilityTimeout: 100 * time.Millisecond,
+		MaxDeliveries:     2,
+	})
+	w.block = 20 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	// Wait for u1 and u2 to be cached, both bad jobs to be dead-lettered, nothing
+	// left pending and both job statuses settled.
+	for deadline := time.Now().Add(3 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		cached := mr.Exists(cache.ResourceCacheKey("app", "profile", "u1")) && mr.Exists(cache.ResourceCacheKey("app", "profile", "u2"))
+		dead, _ := client.XLen(ctx, redisc.KeyHydrationJobsDLQ).Result()
+		pending, _ := client.XPending(ctx, redisc.KeyHydrationJobs, ConsumerGroup).Result()
+		settled := true
+		for _, id := range []string{"job-u1", "job-broken"} {
+			st, err := hyd.JobStatus(ctx, id)
+			settled = settled && err == nil && st.Terminal()
+		}
+		if cached && dead == 2 && pending != nil && pending.Count == 0 && settled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cached %v, dead letters %d, pending %+v", cached, dead, pending)
+		}
+	}
+	if got := failing.Load(); got != 2 {
+		t.Errorf("attempts for failing job: got %d, want 2", got)
+	}
+	// The failing job is only reported finished once it is dead-lettered.
+	for user, want := range map[string]string{"u1": hydrator.JobComplete, "broken": hydrator.JobFailed} {
+		if st, err := hyd.JobStatus(ctx, "job-"+user); err != nil || st.State != want || st.CompletedAt == nil {
+			t.Errorf("%s job status: got %+v, %v, want %s", user, st, err, want)
+		}
+	}
+
+	dead, _ := client.XRange(ctx, redisc.KeyHydrationJobsDLQ, "-", "+").Result()
+	for _, msg := range dead {
+		if msg.Values["error"] == "" || msg.Values["source_id"] == "" {
+			t.Errorf("dead letter missing error or source_id: %v", msg.Values)
+		}
+	}
+
+	cancel()
+	<-done
+}
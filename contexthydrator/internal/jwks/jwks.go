@@ -0,0 +1,249 @@
+// Package jwks loads JSON Web Key Sets (RFC 7517) from a local file or URL
+// and resolves verification keys by key ID.
+//
+// RSA, EC (P-256, P-384, P-521) and OKP (Ed25519) keys are supported; keys
+// marked "use": "enc" are ignored.
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrKeyNotFound is returned when no key matches the requested key ID.
+var ErrKeyNotFound = errors.New("jwks: key not found")
+
+// minRefetch limits how often an unknown kid forces a reload, so tokens with
+// random kids cannot be used to hammer the JWKS endpoint.
+const minRefetch = 30 * time.Second
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Parse decodes a JWK Set into public keys indexed by kid.
+func Parse(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwks: decode: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: %w", k.Kid, err)
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey returns nil, nil for key types this package does not handle.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBig(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("n: %w", err)
+		}
+		e, err := decodeBig(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("e: %w", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBig(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeBig(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBig(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// Source is a JWK Set loaded from a file path or an http(s) URL and
+// reloaded every refresh interval. Safe for concurrent use.
+type Source struct {
+	location string
+	refresh  time.Duration
+	client   *http.Client
+
+	mu   sync.Mutex
+	keys map[string]crypto.PublicKey
+	// attempted is the time of the last load, successful or not, so a
+	// failing endpoint is retried at the normal cadence rather than per token.
+	attempted time.Time
+	// loadErr is the error of the last failed load while no key set has
+	// loaded yet, returned by Key in place of ErrKeyNotFound.
+	loadErr error
+}
+
+// NewSource creates a Source for location. Keys are loaded on first use;
+// call Load to fail fast at startup.
+func NewSource(location string, refresh time.Duration, client *http.Client) *Source {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Source{location: location, refresh: refresh, client: client}
+}
+
+// Load (re)reads the key set.
+func (s *Source) Load(ctx context.Context) error {
+	s.mu.Lock()
+	s.attempted = time.Now()
+	s.mu.Unlock()
+
+	keys, err := s.fetch(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		if s.keys == nil {
+			s.loadErr = err
+		}
+		return err
+	}
+	s.keys = keys
+	s.loadErr = nil
+	return nil
+}
+
+func (s *Source) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	data, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Key returns the key with the given kid. An empty kid matches the only key
+// of a single-key set. The set is reloaded when it is older than the refresh
+// interval or, at most every 30s, when kid is unknown (a newly rotated key).
+// If a reload fails the previous keys keep being served. Until a key set has
+// loaded, Key returns the last load error rather than ErrKeyNotFound, so an
+// unreachable JWKS is not mistaken for an unknown key.
+func (s *Source) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	age := time.Since(s.attempted)
+	key, found := s.lookup(kid)
+	loaded := s.keys != nil
+	s.mu.Unlock()
+
+	stale := (!loaded && age > time.Second) || (s.refresh > 0 && age > s.refresh)
+	if stale || (!found && age > minRefetch) {
+		if err := s.Load(ctx); err != nil && !loaded {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	key, found = s.lookup(kid)
+	loadErr := s.loadErr
+	s.mu.Unlock()
+	if !found {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		return nil, fmt.Errorf("%w: kid %q", ErrKeyNotFound, kid)
+	}
+	return key, nil
+}
+
+// lookup must be called with s.mu held.
+func (s *Source) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+func (s *Source) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(s.location, "http://") && !strings.HasPrefix(s.location, "https://") {
+		data, err := os.ReadFile(strings.TrimPrefix(s.location, "file://"))
+		if err != nil {
+			return nil, fmt.Errorf("jwks: %w", err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.location, nil)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: fetch %s: %w", s.location, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: fetch %s: status %d", s.location, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
@@ -0,0 +1,114 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func TestParse_KeyTypes(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	doc := `{"keys":[
+		{"kty":"RSA","kid":"r1","n":"` + b64(rsaKey.N.Bytes()) + `","e":"` + b64(big.NewInt(int64(rsaKey.E)).Bytes()) + `"},
+		{"kty":"EC","kid":"e1","crv":"P-256","x":"` + b64(ecKey.X.Bytes()) + `","y":"` + b64(ecKey.Y.Bytes()) + `"},
+		{"kty":"OKP","kid":"o1","crv":"Ed25519","x":"` + b64(edPub) + `"},
+		{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"},
+		{"kty":"oct","kid":"sym","k":"c2VjcmV0"}
+	]}`
+	keys, err := Parse([]byte(doc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 keys, got %d", len(keys))
+	}
+	if k, ok := keys["r1"].(*rsa.PublicKey); !ok || k.N.Cmp(rsaKey.N) != 0 {
+		t.Error("r1: RSA key mismatch")
+	}
+	if k, ok := keys["e1"].(*ecdsa.PublicKey); !ok || !k.Equal(&ecKey.PublicKey) {
+		t.Error("e1: EC key mismatch")
+	}
+	if k, ok := keys["o1"].(ed25519.PublicKey); !ok || !k.Equal(edPub) {
+		t.Error("o1: Ed25519 key mismatch")
+	}
+}
+
+func TestSource_File(t *testing.T) {
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	os.WriteFile(path, []byte(`{"keys":[{"kty":"OKP","kid":"k1","crv":"Ed25519","x":"`+b64(edPub)+`"}]}`), 0o600)
+
+	src := NewSource(path, time.Minute, nil)
+	if _, err := src.Key(context.Background(), "k1"); err != nil {
+		t.Fatalf("k1: %v", err)
+	}
+	// A single-key set also matches tokens without a kid.
+	if _, err := src.Key(context.Background(), ""); err != nil {
+		t.Fatalf("empty kid: %v", err)
+	}
+	if _, err := src.Key(context.Background(), "k2"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("k2: expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestSource_URLRefresh(t *testing.T) {
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write([]byte(`{"keys":[{"kty":"OKP","kid":"k1","crv":"Ed25519","x":"` + b64(edPub) + `"}]}`))
+	}))
+	defer srv.Close()
+
+	src := NewSource(srv.URL, time.Hour, srv.Client())
+	for range 3 {
+		if _, err := src.Key(context.Background(), "k1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetches: got %d, want 1 (cached)", n)
+	}
+
+	// Unknown kids trigger a reload, but at most once per minRefetch.
+	src.attempted = time.Now().Add(-2 * minRefetch)
+	src.Key(context.Background(), "rotated")
+	src.Key(context.Background(), "rotated")
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetches after unknown kid: got %d, want 2", n)
+	}
+}
+
+func TestSource_FailedFirstLoad(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	src := NewSource(srv.URL, time.Minute, srv.Client())
+	// The first call loads and fails; the second falls inside the retry
+	// window and must still report the outage, not an unknown key.
+	for i := range 2 {
+		if _, err := src.Key(context.Background(), "k1"); err == nil || errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("call %d: expected the load error, got %v", i+1, err)
+		}
+	}
+}
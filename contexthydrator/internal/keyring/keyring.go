@@ -0,0 +1,161 @@
+// Package keyring supplies the per-app data keys cached resources are
+// encrypted with.
+//
+// Every key has a version. New entries are encrypted with the app's current
+// key and record its version, so a key can be rotated by adding a new version
+// and making it current: entries written under older versions stay readable
+// as long as those versions are kept.
+package keyring
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrKeyNotFound is returned when an app has no key of the requested version.
+var ErrKeyNotFound = errors.New("keyring: key not found")
+
+// KeySize is the length of a data key: AES-256.
+const KeySize = 32
+
+// Key is a versioned data key.
+type Key struct {
+	Version  uint32
+	Material []byte
+}
+
+// Provider supplies data keys per app.
+type Provider interface {
+	// Current returns the key new entries of appID are encrypted with. ok is
+	// false if the app's entries are not encrypted.
+	Current(ctx context.Context, appID string) (key Key, ok bool, err error)
+	// Version returns a key of appID by version, to decrypt entries written
+	// before a rotation.
+	Version(ctx context.Context, appID string, version uint32) (Key, error)
+}
+
+// appKeys is one app's entry in a key file.
+type appKeys struct {
+	Current uint32            `json:"current"`
+	Keys    map[string]string `json:"keys"` // version → base64 key
+}
+
+// parse decodes a key file into keys by app and version, and each app's
+// current version.
+func parse(data []byte) (map[string]map[uint32]Key, map[string]uint32, error) {
+	var file map[string]appKeys
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, nil, fmt.Errorf("keyring: decode: %w", err)
+	}
+	keys := make(map[string]map[uint32]Key, len(file))
+	current := make(map[string]uint32, len(file))
+	for appID, app := range file {
+		keys[appID] = make(map[uint32]Key, len(app.Keys))
+		for v, encoded := range app.Keys {
+			version, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				return nil, nil, fmt.Errorf("keyring: app %q: invalid version %q", appID, v)
+			}
+			material, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil || len(material) != KeySize {
+				return nil, nil, fmt.Errorf("keyring: app %q version %d: want a base64 %d-byte key", appID, version, KeySize)
+			}
+			keys[appID][uint32(version)] = Key{Version: uint32(version), Material: material}
+		}
+		if _, ok := keys[appID][app.Current]; !ok {
+			return nil, nil, fmt.Errorf("keyring: app %q: current version %d has no key", appID, app.Current)
+		}
+		current[appID] = app.Current
+	}
+	return keys, current, nil
+}
+
+// FileProvider reads keys from a local JSON file, reloading it every refresh
+// interval so rotations are picked up without a restart. Keys are base64 and
+// listed by version; apps missing from the file are not encrypted:
+//
+//	{"payments-app": {"current": 2, "keys": {"1": "<base64>", "2": "<base64>"}}}
+//
+// Safe for concurrent use.
+type FileProvider struct {
+	path    string
+	refresh time.Duration
+
+	mu      sync.Mutex
+	keys    map[string]map[uint32]Key
+	current map[string]uint32
+	// attempted is the time of the last load, successful or not.
+	attempted time.Time
+}
+
+// NewFileProvider creates a FileProvider for path. Call Load to read the
+// file at startup.
+func NewFileProvider(path string, refresh time.Duration) *FileProvider {
+	return &FileProvider{path: path, refresh: refresh}
+}
+
+// Load (re)reads the key file.
+func (p *FileProvider) Load() error {
+	p.mu.Lock()
+	p.attempted = time.Now()
+	p.mu.Unlock()
+	return p.load()
+}
+
+func (p *FileProvider) load() error {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("keyring: %w", err)
+	}
+	keys, current, err := parse(data)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.keys, p.current = keys, current
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *FileProvider) Current(_ context.Context, appID string) (Key, bool, error) {
+	p.reloadIfStale()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	version, ok := p.current[appID]
+	if !ok {
+		return Key{}, false, nil
+	}
+	return p.keys[appID][version], true, nil
+}
+
+func (p *FileProvider) Version(_ context.Context, appID string, version uint32) (Key, error) {
+	p.reloadIfStale()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key, ok := p.keys[appID][version]
+	if !ok {
+		return Key{}, fmt.Errorf("%w: app %q version %d", ErrKeyNotFound, appID, version)
+	}
+	return key, nil
+}
+
+// reloadIfStale reloads the file once it is older than the refresh
+// interval. If the reload fails the previous keys keep being served.
+func (p *FileProvider) reloadIfStale() {
+	p.mu.Lock()
+	stale := p.refresh > 0 && time.Since(p.attempted) > p.refresh
+	if stale {
+		p.attempted = time.Now()
+	}
+	p.mu.Unlock()
+	if stale {
+		p.load()
+	}
+}
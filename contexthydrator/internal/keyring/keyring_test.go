@@ -0,0 +1,72 @@
+package keyring
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func key(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune(b)), KeySize)))
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileProvider_Rotation(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeFile(t, path, `{"payments-app": {"current": 1, "keys": {"1": "`+key('a')+`"}}}`)
+
+	p := NewFileProvider(path, time.Millisecond)
+	if err := p.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if k, ok, err := p.Current(ctx, "payments-app"); err != nil || !ok || k.Version != 1 {
+		t.Fatalf("current: %+v %v %v", k, ok, err)
+	}
+	if _, ok, _ := p.Current(ctx, "other-app"); ok {
+		t.Error("app without keys should not be encrypted")
+	}
+
+	writeFile(t, path, `{"payments-app": {"current": 2, "keys": {"1": "`+key('a')+`", "2": "`+key('b')+`"}}}`)
+	time.Sleep(5 * time.Millisecond)
+	if k, _, _ := p.Current(ctx, "payments-app"); k.Version != 2 {
+		t.Errorf("current after rotation: got version %d, want 2", k.Version)
+	}
+	if _, err := p.Version(ctx, "payments-app", 1); err != nil {
+		t.Errorf("old version: %v", err)
+	}
+	if _, err := p.Version(ctx, "payments-app", 3); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unknown version: got %v, want ErrKeyNotFound", err)
+	}
+
+	// A broken file keeps the previous keys.
+	writeFile(t, path, `{not json`)
+	time.Sleep(5 * time.Millisecond)
+	if k, _, _ := p.Current(ctx, "payments-app"); k.Version != 2 {
+		t.Errorf("after failed reload: got version %d, want 2", k.Version)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	cases := map[string]string{
+		"short key":       `{"a": {"current": 1, "keys": {"1": "c2hvcnQ="}}}`,
+		"bad version":     `{"a": {"current": 1, "keys": {"one": "` + key('a') + `"}}}`,
+		"missing current": `{"a": {"current": 2, "keys": {"1": "` + key('a') + `"}}}`,
+	}
+	for name, data := range cases {
+		if _, _, err := parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package metrics defines the Prometheus metrics exported on /metrics.
+//
+// Every series carries app_id; per-resource series also carry resource.
+// Both label sets are bounded by the app registry. Cache hit rate is derived
+// from the hit and miss counters:
+//
+//	sum by (app_id, resource) (rate(cache_hit_total[5m]))
+//	  / sum by (app_id, resource) (rate(cache_hit_total[5m]) + rate(cache_miss_total[5m]))
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Cache tiers for CacheTierReads.
+const (
+	TierLocal = "local"
+	TierRedis = "redis"
+)
+
+// Error stages for HydrationErrors.
+const (
+	StageFetch      = "fetch"
+	StageCacheWrite = "cache_write"
+)
+
+var latencyBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+var (
+	// HydrationLatency is the time from the start of a hydration to a
+	// resource being written to the cache.
+	HydrationLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hydration_latency_ms",
+		Help:    "Time to fetch and cache one resource during hydration, in milliseconds.",
+		Buckets: latencyBuckets,
+	}, []string{"app_id", "resource"})
+
+	HydrationErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hydration_errors_total",
+		Help: "Resources that failed to hydrate, by stage (fetch, cache_write).",
+	}, []string{"app_id", "resource", "stage"})
+
+	HydrationsInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hydrations_in_flight",
+		Help: "Hydration goroutines currently running, including revalidations.",
+	}, []string{"app_id"})
+
+	// HydrationsDeduplicated counts skipped hydrations, by reason: "in_process"
+	// (already running here) or "lock" (running elsewhere or recently completed).
+	HydrationsDeduplicated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hydrations_deduplicated_total",
+		Help: "Hydrations skipped because one for the same contextKey was running or recently completed.",
+	}, []string{"app_id", "reason"})
+
+	// Hydration worker pool. Utilisation is
+	// hydration_workers_busy / hydration_workers.
+	HydrationQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "hydration_queue_depth",
+		Help: "Hydrations queued and waiting for a worker.",
+	})
+	HydrationWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "hydration_workers",
+		Help: "Size of the hydration worker pool.",
+	})
+	HydrationWorkersBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "hydration_workers_busy",
+		Help: "Hydration workers currently running a hydration.",
+	})
+	HydrationsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hydrations_rejected_total",
+		Help: "POST /hydrate requests rejected with 503 because the hydration queue was full.",
+	}, []string{"app_id"})
+
+	// HydrationJobs counts queued hydration jobs processed by the worker, by
+	// outcome: "ok", "skipped", "retry" or "dead_letter".
+	HydrationJobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hydration_jobs_total",
+		Help: "Hydration jobs processed by the worker, by outcome.",
+	}, []string{"app_id", "outcome"})
+
+	CacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_hit_total",
+		Help: "Reader cache hits, including stale entries served while revalidating.",
+	}, []string{"app_id", "resource"})
+
+	CacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_miss_total",
+		Help: "Reader cache misses.",
+	}, []string{"app_id", "resource"})
+
+	// CacheTierReads counts resource reads per cache tier: "local" (the
+	// in-process LRU, when enabled) and "redis". result is "hit" or "miss".
+	CacheTierReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_tier_reads_total",
+		Help: "Resource reads per cache tier (local, redis), by result (hit, miss).",
+	}, []string{"tier", "result"})
+
+	// ReadThroughs counts reader cache misses fetched from the backend, by
+	// outcome: "ok" or "failed".
+	ReadThroughs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "read_through_total",
+		Help: "Reader cache misses fetched from the backend, by outcome.",
+	}, []string{"app_id", "resource", "outcome"})
+
+	// UpstreamLatency is labelled by HTTP status code, or "error" when no
+	// response was received.
+	UpstreamLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upstream_request_duration_ms",
+		Help:    "Backend fetch latency in milliseconds, by response status code.",
+		Buckets: latencyBuckets,
+	}, []string{"app_id", "resource", "status_code"})
+
+	// UpstreamCircuitOpen is 1 while an upstream host's circuit breaker is open
+	// or half-open, 0 once it closes.
+	UpstreamCircuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "upstream_circuit_open",
+		Help: "1 while the upstream host's circuit breaker is open.",
+	}, []string{"host"})
+)
+
+// ObserveUpstream records one backend call. status is 0 when the request failed
+// before a response was received.
+func ObserveUpstream(appID, resource string, status int, elapsed time.Duration) {
+	code := "error"
+	if status != 0 {
+		code = strconv.Itoa(status)
+	}
+	UpstreamLatency.WithLabelValues(appID, resource, code).Observe(Milliseconds(elapsed))
+}
+
+// Milliseconds converts d to fractional milliseconds for the _ms histograms.
+func Milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Handler serves the default registry, including Go runtime and process metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
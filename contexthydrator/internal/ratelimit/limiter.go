@@ -0,0 +1,115 @@
+// Package ratelimit enforces the per-app POST /hydrate limits with
+// fixed one-minute windows counted in Redis, so the limits hold across all
+// hydration-service instances.
+//
+// Each request increments up to three counters:
+//
+//	ratelimit:{appID}:{hyd_token_hash}:{minute-bucket}   per token
+//	ratelimit:{appID}:ip:{ip}:{minute-bucket}            per source IP
+//	ratelimit:{appID}:app:{minute-bucket}                per app
+//
+// Counters expire after two minutes.
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+const (
+	window     = time.Minute
+	counterTTL = 2 * window
+)
+
+// Scopes name the limit that rejected a request.
+const (
+	ScopeToken = "token"
+	ScopeIP    = "ip"
+	ScopeApp   = "app"
+)
+
+// Decision is the outcome of a rate limit check.
+type Decision struct {
+	Allowed bool
+	// Scope and RetryAfter are set when the request is rejected.
+	Scope      string
+	RetryAfter time.Duration
+}
+
+type Limiter struct {
+	client redis.UniversalClient
+	now    func() time.Time
+}
+
+func New(client redis.UniversalClient) *Limiter {
+	return &Limiter{client: client, now: time.Now}
+}
+
+// Allow counts one request against each limit enabled in cfg and reports
+// whether all of them are still within bounds. tokenHash and ip may be empty,
+// in which case the corresponding limit is skipped.
+func (l *Limiter) Allow(ctx context.Context, appID string, cfg services.RateLimitConfig, tokenHash, ip string) (Decision, error) {
+	now := l.now()
+	bucket := strconv.FormatInt(now.Unix()/int64(window/time.Second), 10)
+
+	type check struct {
+		scope string
+		limit int
+		count *redis.IntCmd
+	}
+	var checks []check
+	_, err := l.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		add := func(scope string, limit int, key string) {
+			if limit <= 0 {
+				return
+			}
+			checks = append(checks, check{scope: scope, limit: limit, count: pipe.Incr(ctx, key)})
+			pipe.Expire(ctx, key, counterTTL)
+		}
+		if tokenHash != "" {
+			add(ScopeToken, cfg.PerToken, TokenKey(appID, tokenHash, bucket))
+		}
+		if ip != "" {
+			add(ScopeIP, cfg.PerIP, IPKey(appID, ip, bucket))
+		}
+		add(ScopeApp, cfg.PerApp, AppKey(appID, bucket))
+		return nil
+	})
+	if err != nil {
+		return Decision{}, fmt.Errorf("rate limit: %w", err)
+	}
+
+	for _, c := range checks {
+		if c.count.Val() > int64(c.limit) {
+			return Decision{Scope: c.scope, RetryAfter: now.Truncate(window).Add(window).Sub(now)}, nil
+		}
+	}
+	return Decision{Allowed: true}, nil
+}
+
+// HashToken returns the identifier used for a hydration token in counter
+// keys, so raw tokens never appear in Redis key names.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
+
+func TokenKey(appID, tokenHash, bucket string) string {
+	return redisc.KeyPrefixRateLimit + appID + ":" + tokenHash + ":" + bucket
+}
+
+func IPKey(appID, ip, bucket string) string {
+	return redisc.KeyPrefixRateLimit + appID + ":ip:" + ip + ":" + bucket
+}
+
+func AppKey(appID, bucket string) string {
+	return redisc.KeyPrefixRateLimit + appID + ":app:" + bucket
+}
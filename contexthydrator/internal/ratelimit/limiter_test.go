@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+func newTestLimiter(t *testing.T, now time.Time) (*Limiter, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	l := New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	l.now = func() time.Time { return now }
+	return l, mr
+}
+
+func TestAllow_PerToken(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 45, 0, time.UTC)
+	l, mr := newTestLimiter(t, now)
+	ctx := context.Background()
+	cfg := services.RateLimitConfig{PerToken: 2}
+
+	for i := range 2 {
+		d, err := l.Allow(ctx, "app", cfg, "tok", "10.0.0.1")
+		if err != nil || !d.Allowed {
+			t.Fatalf("request %d: got %+v, %v", i+1, d, err)
+		}
+	}
+	d, err := l.Allow(ctx, "app", cfg, "tok", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allowed || d.Scope != ScopeToken {
+		t.Fatalf("expected token limit, got %+v", d)
+	}
+	if d.RetryAfter != 15*time.Second {
+		t.Errorf("retry after: got %s, want 15s", d.RetryAfter)
+	}
+
+	// Other tokens have their own budget.
+	if d, _ := l.Allow(ctx, "app", cfg, "other", "10.0.0.1"); !d.Allowed {
+		t.Errorf("other token rejected: %+v", d)
+	}
+
+	key := TokenKey("app", "tok", "29454480")
+	if !mr.Exists(key) {
+		t.Fatalf("expected counter %s", key)
+	}
+	if ttl := mr.TTL(key); ttl != 2*time.Minute {
+		t.Errorf("counter ttl: got %s, want 2m", ttl)
+	}
+}
+
+func TestAllow_PerIPAndApp(t *testing.T) {
+	l, _ := newTestLimiter(t, time.Now())
+	ctx := context.Background()
+
+	cfg := services.RateLimitConfig{PerIP: 1}
+	l.Allow(ctx, "app", cfg, "a", "10.0.0.1")
+	if d, _ := l.Allow(ctx, "app", cfg, "b", "10.0.0.1"); d.Allowed || d.Scope != ScopeIP {
+		t.Errorf("expected ip limit, got %+v", d)
+	}
+
+	cfg = services.RateLimitConfig{PerApp: 1}
+	l.Allow(ctx, "other-app", cfg, "a", "10.0.0.2")
+	if d, _ := l.Allow(ctx, "other-app", cfg, "b", "10.0.0.3"); d.Allowed || d.Scope != ScopeApp {
+		t.Errorf("expected app limit, got %+v", d)
+	}
+}
+
+func TestAllow_Unlimited(t *testing.T) {
+	l, mr := newTestLimiter(t, time.Now())
+	for range 5 {
+		if d, _ := l.Allow(context.Background(), "app", services.RateLimitConfig{}, "tok", "10.0.0.1"); !d.Allowed {
+			t.Fatalf("unexpected rejection: %+v", d)
+		}
+	}
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Errorf("expected no counters, got %v", keys)
+	}
+}
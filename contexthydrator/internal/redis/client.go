@@ -20,19 +20,58 @@ const (
 // Key prefixes
 const (
 	KeyPrefixMapping = "hyd:mapping:"
+	KeyPrefixApp     = "hyd:app:"
+	KeyPrefixClaims  = "hyd:claims:"
+	// Set of registered app IDs, for services that run per-app loops.
+	KeyAppIndex = "hyd:apps"
+
+	// Hydration-complete events: persistent stream and Pub/Sub channel per app.
+	KeyPrefixEventStream  = "hyd:stream:"
+	KeyPrefixEventChannel = "hyd:events:"
+	// Per-context progress channel (hyd:progress:{appID}:{contextKey}) feeding
+	// the reader's SSE endpoint.
+	KeyPrefixProgress = "hyd:progress:"
+
+	// Invalidation event streams, one per app, and their dead-letter streams.
+	KeyPrefixInvalidate    = "hyd:invalidate:"
+	KeyPrefixInvalidateDLQ = "hyd:invalidate-dlq:"
+
+	// Hydration lock (hyd:lock:{appID}:{contextKey}), held while a hydration
+	// runs and kept for the suppression window after it succeeds.
+	KeyPrefixLock = "hyd:lock:"
+
+	// Durable hydration jobs, consumed by cmd/hydration-worker, and the
+	// dead-letter stream for jobs that exhausted their deliveries.
+	KeyHydrationJobs    = "hyd:jobs"
+	KeyHydrationJobsDLQ = "hyd:jobs-dlq"
+
+	// Status of an accepted hydration (hyd:jobstatus:{jobID}), kept briefly
+	// for GET /hydrate/{jobID}.
+	KeyPrefixJobStatus = "hyd:jobstatus:"
+
+	// Decayed per-resource read counts (hyd:access:{appID}:{contextKey}) and
+	// the set of "{appID}:{contextKey}" members read since the last learning pass.
+	KeyPrefixAccessStats = "hyd:access:"
+	KeyAccessDirty       = "hyd:access-dirty"
+
+	// Pub/Sub channel carrying the keys of rewritten or deleted resources, so
+	// every reader drops its in-process copy.
+	KeyCacheEvict = "hyd:cache-evict"
+
+	// Fixed-window /hydrate counters: ratelimit:{appID}:{subject}:{minute-bucket}.
+	KeyPrefixRateLimit = "ratelimit:"
 )
 
-func NewClient(addr, password string, db int) (*redis.Client, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: password,
-		DB:       db,
-	})
+// NewClient connects to a single node, a Sentinel-managed primary (with
+// opts.MasterName) or a Cluster (with opts.IsClusterMode), and pings it.
+func NewClient(opts *redis.UniversalOptions) (redis.UniversalClient, error) {
+	client := redis.NewUniversalClient(opts)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("redis ping failed: %w", err)
 	}
 
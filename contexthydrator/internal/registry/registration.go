@@ -0,0 +1,299 @@
+package registry
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+// AppRegistration is the self-service registration document submitted by an
+// app team and stored in the registry. Durations are kept as strings ("12h",
+// "30d") so the stored document round-trips exactly as it was submitted.
+type AppRegistration struct {
+	AppID             string                          `json:"app_id"`
+	DisplayName       string                          `json:"display_name,omitempty"`
+	ContextKeyClaims  []string                        `json:"context_key_claims,omitempty"`
+	Resources         map[string]ResourceRegistration `json:"resources"`
+	HydrationTokenTTL string                          `json:"hydration_token_ttl,omitempty"`
+	// RateLimit caps /hydrate requests per minute across the whole app; 0 is unlimited.
+	RateLimit int `json:"rate_limit,omitempty"`
+	// RateLimits sets the per-token and per-IP limits. Omitted means
+	// services.DefaultRateLimitPerToken and services.DefaultRateLimitPerIP.
+	RateLimits *RateLimitRegistration `json:"rate_limits,omitempty"`
+	// RevalidateFraction overrides services.DefaultRevalidateFraction;
+	// 0 disables stale-while-revalidate for the app.
+	RevalidateFraction *float64 `json:"revalidate_fraction,omitempty"`
+	// Events selects the hydration-complete event sinks. Omitted means
+	// stream only, trimmed to services.DefaultEventStreamMaxLen.
+	Events *EventsRegistration `json:"events,omitempty"`
+	// AccessPatterns tunes which resources hydrations fetch once reads have
+	// been learned. Omitted means services.DefaultAccessPatternMinReads and
+	// services.DefaultAccessPatternMinConfidence, with no always-include set.
+	AccessPatterns *AccessPatternRegistration `json:"access_patterns,omitempty"`
+}
+
+// AccessPatternRegistration configures access-pattern learning for an app.
+type AccessPatternRegistration struct {
+	MinReads      *float64 `json:"min_reads,omitempty"`
+	MinConfidence *float64 `json:"min_confidence,omitempty"`
+	AlwaysInclude []string `json:"always_include,omitempty"`
+}
+
+// EventsRegistration selects where hydration-complete events are published.
+type EventsRegistration struct {
+	Stream       bool  `json:"stream"`
+	StreamMaxLen int64 `json:"stream_maxlen,omitempty"`
+	PubSub       bool  `json:"pubsub"`
+}
+
+// RateLimitRegistration holds per-minute /hydrate limits; 0 is unlimited.
+type RateLimitRegistration struct {
+	PerToken int `json:"per_token"`
+	PerIP    int `json:"per_ip"`
+}
+
+// ResourceRegistration defines how one resource is fetched and cached.
+type ResourceRegistration struct {
+	URL string `json:"url"`
+	TTL string `json:"ttl"`
+	// Retry and CircuitBreaker guard the upstream call. Omitted means
+	// services.DefaultRetryPolicy and services.DefaultBreakerConfig.
+	Retry          *RetryRegistration   `json:"retry,omitempty"`
+	CircuitBreaker *BreakerRegistration `json:"circuit_breaker,omitempty"`
+	// ReadThrough fetches the resource on a reader cache miss; off by default.
+	ReadThrough bool `json:"read_through,omitempty"`
+}
+
+// RetryRegistration configures upstream retries; max_attempts 1 disables them.
+type RetryRegistration struct {
+	MaxAttempts     int    `json:"max_attempts"`
+	InitialBackoff  string `json:"initial_backoff,omitempty"`
+	MaxBackoff      string `json:"max_backoff,omitempty"`
+	RetryableStatus []int  `json:"retryable_status,omitempty"`
+}
+
+// BreakerRegistration configures the upstream host's circuit breaker;
+// failure_threshold 0 disables it.
+type BreakerRegistration struct {
+	FailureThreshold int    `json:"failure_threshold"`
+	OpenDuration     string `json:"open_duration,omitempty"`
+}
+
+// maxRetryAttempts bounds RetryRegistration.MaxAttempts.
+const maxRetryAttempts = 10
+
+// App IDs and resource names are embedded in Redis keys, so they must not
+// contain the ":" separator or whitespace.
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Validate checks the registration and returns the first problem found.
+func (a *AppRegistration) Validate() error {
+	if !namePattern.MatchString(a.AppID) {
+		return fmt.Errorf("app_id must match %s", namePattern)
+	}
+	if len(a.Resources) == 0 {
+		return fmt.Errorf("at least one resource is required")
+	}
+	for name, res := range a.Resources {
+		if !namePattern.MatchString(name) {
+			return fmt.Errorf("resource %q: name must match %s", name, namePattern)
+		}
+		u, err := url.Parse(res.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("resource %q: url must be an absolute http(s) URL", name)
+		}
+		ttl, err := ParseDuration(res.TTL)
+		if err != nil || ttl <= 0 {
+			return fmt.Errorf("resource %q: ttl must be a positive duration", name)
+		}
+		if err := res.Retry.validate(); err != nil {
+			return fmt.Errorf("resource %q: %w", name, err)
+		}
+		if err := res.CircuitBreaker.validate(); err != nil {
+			return fmt.Errorf("resource %q: %w", name, err)
+		}
+	}
+	if a.HydrationTokenTTL != "" {
+		if ttl, err := ParseDuration(a.HydrationTokenTTL); err != nil || ttl <= 0 {
+			return fmt.Errorf("hydration_token_ttl must be a positive duration")
+		}
+	}
+	if a.RateLimit < 0 {
+		return fmt.Errorf("rate_limit must not be negative")
+	}
+	if r := a.RateLimits; r != nil && (r.PerToken < 0 || r.PerIP < 0) {
+		return fmt.Errorf("rate_limits must not be negative")
+	}
+	if f := a.RevalidateFraction; f != nil && (*f < 0 || *f >= 1) {
+		return fmt.Errorf("revalidate_fraction must be in [0, 1)")
+	}
+	if a.Events != nil && a.Events.StreamMaxLen < 0 {
+		return fmt.Errorf("events.stream_maxlen must not be negative")
+	}
+	if p := a.AccessPatterns; p != nil {
+		if p.MinReads != nil && *p.MinReads < 0 {
+			return fmt.Errorf("access_patterns.min_reads must not be negative")
+		}
+		if p.MinConfidence != nil && (*p.MinConfidence < 0 || *p.MinConfidence > 1) {
+			return fmt.Errorf("access_patterns.min_confidence must be in [0, 1]")
+		}
+		for _, name := range p.AlwaysInclude {
+			if _, ok := a.Resources[name]; !ok {
+				return fmt.Errorf("access_patterns.always_include: unknown resource %q", name)
+			}
+		}
+	}
+	return nil
+}
+
+// AppConfig converts a validated registration into the runtime hydration config.
+func (a *AppRegistration) AppConfig() (*services.AppConfig, error) {
+	resources := make(map[services.ServiceName]services.ResourceConfig, len(a.Resources))
+	for name, res := range a.Resources {
+		ttl, err := ParseDuration(res.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("resource %q: %w", name, err)
+		}
+		resources[services.ServiceName(name)] = services.ResourceConfig{
+			URLTemplate: res.URL,
+			TTL:         ttl,
+			Retry:       res.Retry.policy(),
+			Breaker:     res.CircuitBreaker.config(),
+			ReadThrough: res.ReadThrough,
+		}
+	}
+	revalidate := services.DefaultRevalidateFraction
+	if a.RevalidateFraction != nil {
+		revalidate = *a.RevalidateFraction
+	}
+	events := services.EventsConfig{Stream: true, StreamMaxLen: services.DefaultEventStreamMaxLen}
+	if e := a.Events; e != nil {
+		events = services.EventsConfig{Stream: e.Stream, StreamMaxLen: e.StreamMaxLen, PubSub: e.PubSub}
+		if events.StreamMaxLen == 0 {
+			events.StreamMaxLen = services.DefaultEventStreamMaxLen
+		}
+	}
+	rateLimit := services.RateLimitConfig{
+		PerToken: services.DefaultRateLimitPerToken,
+		PerIP:    services.DefaultRateLimitPerIP,
+		PerApp:   a.RateLimit,
+	}
+	if r := a.RateLimits; r != nil {
+		rateLimit.PerToken = r.PerToken
+		rateLimit.PerIP = r.PerIP
+	}
+	access := services.AccessPatternConfig{
+		MinReads:      services.DefaultAccessPatternMinReads,
+		MinConfidence: services.DefaultAccessPatternMinConfidence,
+	}
+	if p := a.AccessPatterns; p != nil {
+		if p.MinReads != nil {
+			access.MinReads = *p.MinReads
+		}
+		if p.MinConfidence != nil {
+			access.MinConfidence = *p.MinConfidence
+		}
+		for _, name := range p.AlwaysInclude {
+			access.AlwaysInclude = append(access.AlwaysInclude, services.ServiceName(name))
+		}
+	}
+	return &services.AppConfig{
+		AppID:              a.AppID,
+		Resources:          resources,
+		ContextKeyClaims:   a.ContextKeyClaims,
+		RevalidateFraction: revalidate,
+		Events:             events,
+		RateLimit:          rateLimit,
+		AccessPatterns:     access,
+	}, nil
+}
+
+func (r *RetryRegistration) validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.MaxAttempts < 1 || r.MaxAttempts > maxRetryAttempts {
+		return fmt.Errorf("retry.max_attempts must be between 1 and %d", maxRetryAttempts)
+	}
+	for field, s := range map[string]string{"initial_backoff": r.InitialBackoff, "max_backoff": r.MaxBackoff} {
+		if s == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(s); err != nil || d < 0 {
+			return fmt.Errorf("retry.%s must be a non-negative duration", field)
+		}
+	}
+	for _, code := range r.RetryableStatus {
+		if code < 400 || code > 599 {
+			return fmt.Errorf("retry.retryable_status: %d is not an error status", code)
+		}
+	}
+	return nil
+}
+
+// policy converts a validated registration; omitted fields take their
+// values from services.DefaultRetryPolicy.
+func (r *RetryRegistration) policy() services.RetryPolicy {
+	p := services.DefaultRetryPolicy
+	if r == nil {
+		return p
+	}
+	p.MaxAttempts = r.MaxAttempts
+	if d, err := time.ParseDuration(r.InitialBackoff); err == nil {
+		p.InitialBackoff = d
+	}
+	if d, err := time.ParseDuration(r.MaxBackoff); err == nil {
+		p.MaxBackoff = d
+	}
+	if r.RetryableStatus != nil {
+		p.RetryableStatus = r.RetryableStatus
+	}
+	return p
+}
+
+func (b *BreakerRegistration) validate() error {
+	if b == nil {
+		return nil
+	}
+	if b.FailureThreshold < 0 {
+		return fmt.Errorf("circuit_breaker.failure_threshold must not be negative")
+	}
+	if b.OpenDuration != "" {
+		if d, err := time.ParseDuration(b.OpenDuration); err != nil || d <= 0 {
+			return fmt.Errorf("circuit_breaker.open_duration must be a positive duration")
+		}
+	}
+	return nil
+}
+
+// config converts a validated registration; an omitted open_duration takes
+// its value from services.DefaultBreakerConfig.
+func (b *BreakerRegistration) config() services.BreakerConfig {
+	c := services.DefaultBreakerConfig
+	if b == nil {
+		return c
+	}
+	c.FailureThreshold = b.FailureThreshold
+	if d, err := time.ParseDuration(b.OpenDuration); err == nil {
+		c.OpenDuration = d
+	}
+	return c
+}
+
+// ParseDuration extends time.ParseDuration with a "d" (day) suffix,
+// e.g. "30d", as used for hydration token lifetimes.
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,151 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+func validRegistration() *AppRegistration {
+	return &AppRegistration{
+		AppID:            "payments-app",
+		ContextKeyClaims: []string{"user_id", "account_id"},
+		Resources: map[string]ResourceRegistration{
+			"profile": {URL: "https://payments.internal/users/{user_id}/profile", TTL: "12h"},
+			"limits":  {URL: "https://payments.internal/accounts/{account_id}/limits", TTL: "5m"},
+		},
+		HydrationTokenTTL: "30d",
+		RateLimit:         100,
+	}
+}
+
+func TestValidate_OK(t *testing.T) {
+	if err := validRegistration().Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidate_Errors(t *testing.T) {
+	cases := map[string]func(*AppRegistration){
+		"empty app_id":     func(a *AppRegistration) { a.AppID = "" },
+		"colon in app_id":  func(a *AppRegistration) { a.AppID = "pay:ments" },
+		"no resources":     func(a *AppRegistration) { a.Resources = nil },
+		"bad resource url": func(a *AppRegistration) { a.Resources["profile"] = ResourceRegistration{URL: "/relative", TTL: "1h"} },
+		"bad resource ttl": func(a *AppRegistration) {
+			a.Resources["profile"] = ResourceRegistration{URL: "http://svc/p", TTL: "soon"}
+		},
+		"zero ttl": func(a *AppRegistration) {
+			a.Resources["profile"] = ResourceRegistration{URL: "http://svc/p", TTL: "0s"}
+		},
+		"bad resource name": func(a *AppRegistration) {
+			a.Resources["pro:file"] = ResourceRegistration{URL: "http://svc/p", TTL: "1h"}
+		},
+		"negative rate limit": func(a *AppRegistration) { a.RateLimit = -1 },
+		"negative per-ip limit": func(a *AppRegistration) {
+			a.RateLimits = &RateLimitRegistration{PerToken: 5, PerIP: -1}
+		},
+		"zero retry attempts": func(a *AppRegistration) {
+			a.Resources["profile"] = ResourceRegistration{URL: "http://svc/p", TTL: "1h", Retry: &RetryRegistration{}}
+		},
+		"bad retry backoff": func(a *AppRegistration) {
+			a.Resources["profile"] = ResourceRegistration{URL: "http://svc/p", TTL: "1h",
+				Retry: &RetryRegistration{MaxAttempts: 3, InitialBackoff: "soon"}}
+		},
+		"non-error retryable status": func(a *AppRegistration) {
+			a.Resources["profile"] = ResourceRegistration{URL: "http://svc/p", TTL: "1h",
+				Retry: &RetryRegistration{MaxAttempts: 3, RetryableStatus: []int{200}}}
+		},
+		"unknown always-include resource": func(a *AppRegistration) {
+			a.AccessPatterns = &AccessPatternRegistration{AlwaysInclude: []string{"billing"}}
+		},
+		"min_confidence above 1": func(a *AppRegistration) {
+			c := 1.5
+			a.AccessPatterns = &AccessPatternRegistration{MinConfidence: &c}
+		},
+		"negative breaker threshold": func(a *AppRegistration) {
+			a.Resources["profile"] = ResourceRegistration{URL: "http://svc/p", TTL: "1h",
+				CircuitBreaker: &BreakerRegistration{FailureThreshold: -1}}
+		},
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			reg := validRegistration()
+			mutate(reg)
+			if err := reg.Validate(); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+}
+
+func TestAppConfig(t *testing.T) {
+	cfg, err := validRegistration().AppConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.AppID != "payments-app" {
+		t.Errorf("app_id: got %q", cfg.AppID)
+	}
+	limits, ok := cfg.Resources[services.ServiceName("limits")]
+	if !ok {
+		t.Fatal("limits resource missing")
+	}
+	if limits.TTL != 5*time.Minute {
+		t.Errorf("limits ttl: got %s, want 5m", limits.TTL)
+	}
+	if limits.ReadThrough {
+		t.Error("read-through should be off unless registered")
+	}
+	want := services.RateLimitConfig{
+		PerToken: services.DefaultRateLimitPerToken,
+		PerIP:    services.DefaultRateLimitPerIP,
+		PerApp:   100,
+	}
+	if cfg.RateLimit != want {
+		t.Errorf("rate limit: got %+v, want %+v", cfg.RateLimit, want)
+	}
+}
+
+func TestAppConfig_RetryAndBreaker(t *testing.T) {
+	reg := validRegistration()
+	reg.Resources["limits"] = ResourceRegistration{
+		URL:            "https://payments.internal/accounts/{account_id}/limits",
+		TTL:            "5m",
+		Retry:          &RetryRegistration{MaxAttempts: 4, InitialBackoff: "100ms", RetryableStatus: []int{429, 503}},
+		CircuitBreaker: &BreakerRegistration{FailureThreshold: 0},
+	}
+	cfg, err := reg.AppConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	profile := cfg.Resources["profile"]
+	if profile.Retry.MaxAttempts != services.DefaultRetryPolicy.MaxAttempts || profile.Breaker != services.DefaultBreakerConfig {
+		t.Errorf("profile: got retry %+v breaker %+v, want defaults", profile.Retry, profile.Breaker)
+	}
+
+	limits := cfg.Resources["limits"]
+	if limits.Retry.MaxAttempts != 4 || limits.Retry.InitialBackoff != 100*time.Millisecond {
+		t.Errorf("limits retry: got %+v", limits.Retry)
+	}
+	if limits.Retry.MaxBackoff != services.DefaultRetryPolicy.MaxBackoff {
+		t.Errorf("limits max backoff: got %s, want default", limits.Retry.MaxBackoff)
+	}
+	if len(limits.Retry.RetryableStatus) != 2 || limits.Retry.RetryableStatus[0] != 429 {
+		t.Errorf("limits retryable status: got %v", limits.Retry.RetryableStatus)
+	}
+	if limits.Breaker.FailureThreshold != 0 {
+		t.Errorf("limits breaker: got %+v, want disabled", limits.Breaker)
+	}
+}
+
+func TestParseDuration_Days(t *testing.T) {
+	got, err := ParseDuration("30d")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 30*24*time.Hour {
+		t.Errorf("got %s, want 720h", got)
+	}
+}
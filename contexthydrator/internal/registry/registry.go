@@ -0,0 +1,188 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yourorg/context-hydrator/internal/cache"
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+var (
+	ErrAppNotFound = errors.New("app not found")
+	ErrAppExists   = errors.New("app already registered")
+)
+
+// Registry stores app registrations in Redis and resolves them to runtime
+// AppConfigs. Resolved configs are cached in-process for a short TTL so the
+// hot /hydrate path does not pay a Redis round trip per request.
+//
+// Apps passed as defaults (typically the env-configured APP_ID) are always
+// resolvable and are used when no registration exists in Redis.
+type Registry struct {
+	store     *cache.Store
+	cacheTTL  time.Duration
+	defaultID string
+	defaults  map[string]*services.AppConfig
+
+	mu    sync.RWMutex
+	local map[string]cachedApp
+}
+
+type cachedApp struct {
+	cfg     *services.AppConfig
+	expires time.Time
+}
+
+// New creates a Registry. The first default app becomes the default app ID
+// for requests that do not name an app.
+func New(store *cache.Store, cacheTTL time.Duration, defaults ...*services.AppConfig) *Registry {
+	r := &Registry{
+		store:    store,
+		cacheTTL: cacheTTL,
+		defaults: make(map[string]*services.AppConfig, len(defaults)),
+		local:    make(map[string]cachedApp),
+	}
+	for i, d := range defaults {
+		if i == 0 {
+			r.defaultID = d.AppID
+		}
+		r.defaults[d.AppID] = d
+	}
+	return r
+}
+
+// DefaultAppID returns the app ID used when a request does not name one.
+func (r *Registry) DefaultAppID() string {
+	return r.defaultID
+}
+
+// AppConfig resolves the runtime config for appID: in-process cache first,
+// then the Redis registration, then the static defaults.
+func (r *Registry) AppConfig(ctx context.Context, appID string) (*services.AppConfig, error) {
+	r.mu.RLock()
+	entry, ok := r.local[appID]
+	r.mu.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.cfg, nil
+	}
+
+	reg, err := r.Get(ctx, appID)
+	var cfg *services.AppConfig
+	switch {
+	case err == nil:
+		cfg, err = reg.AppConfig()
+		if err != nil {
+			return nil, fmt.Errorf("app %s: %w", appID, err)
+		}
+	case errors.Is(err, ErrAppNotFound):
+		d, ok := r.defaults[appID]
+		if !ok {
+			return nil, ErrAppNotFound
+		}
+		cfg = d
+	default:
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.local[appID] = cachedApp{cfg: cfg, expires: time.Now().Add(r.cacheTTL)}
+	r.mu.Unlock()
+	return cfg, nil
+}
+
+// Get returns the stored registration for appID.
+func (r *Registry) Get(ctx context.Context, appID string) (*AppRegistration, error) {
+	data, err := r.store.Get(ctx, cache.AppKey(appID))
+	if errors.Is(err, cache.ErrCacheMiss) {
+		return nil, ErrAppNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var reg AppRegistration
+	if err := json.Unmarshal(data, &reg); err != nil {
+		return nil, fmt.Errorf("unmarshal registration: %w", err)
+	}
+	return &reg, nil
+}
+
+// AppIDs lists the registered apps. Static defaults are not included, nor are
+// apps registered before the index existed until they are next updated.
+func (r *Registry) AppIDs(ctx context.Context) ([]string, error) {
+	return r.store.SMembers(ctx, redisc.KeyAppIndex)
+}
+
+// Register stores a new registration. Returns ErrAppExists if appID is taken,
+// including by a concurrent registration.
+func (r *Registry) Register(ctx context.Context, reg *AppRegistration) error {
+	b, err := json.Marshal(reg)
+	if err != nil {
+		return fmt.Errorf("marshal registration: %w", err)
+	}
+	// Indexed first, so a stored registration is always listed. Indexing an
+	// app that turns out to be taken is harmless: it is registered.
+	if err := r.store.SAdd(ctx, redisc.KeyAppIndex, reg.AppID); err != nil {
+		return err
+	}
+	// Registrations never expire — TTL 0 persists the key.
+	ok, err := r.store.SetNX(ctx, cache.AppKey(reg.AppID), b, 0)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAppExists
+	}
+	r.forget(reg.AppID)
+	return nil
+}
+
+// Update replaces an existing registration. Returns ErrAppNotFound if absent.
+func (r *Registry) Update(ctx context.Context, reg *AppRegistration) error {
+	if _, err := r.Get(ctx, reg.AppID); err != nil {
+		return err
+	}
+	return r.put(ctx, reg)
+}
+
+// Delete removes a registration. Returns ErrAppNotFound if absent.
+func (r *Registry) Delete(ctx context.Context, appID string) error {
+	if _, err := r.Get(ctx, appID); err != nil {
+		return err
+	}
+	if err := r.store.Delete(ctx, cache.AppKey(appID)); err != nil {
+		return err
+	}
+	r.forget(appID)
+	return r.store.SRem(ctx, redisc.KeyAppIndex, appID)
+}
+
+func (r *Registry) put(ctx context.Context, reg *AppRegistration) error {
+	b, err := json.Marshal(reg)
+	if err != nil {
+		return fmt.Errorf("marshal registration: %w", err)
+	}
+	// Also indexes apps registered before the index existed.
+	if err := r.store.SAdd(ctx, redisc.KeyAppIndex, reg.AppID); err != nil {
+		return err
+	}
+	// Registrations never expire — TTL 0 persists the key.
+	if err := r.store.Set(ctx, cache.AppKey(reg.AppID), b, 0); err != nil {
+		return err
+	}
+	r.forget(reg.AppID)
+	return nil
+}
+
+// forget drops the in-process entry so this instance sees changes immediately.
+// Other instances pick them up once their cacheTTL elapses.
+func (r *Registry) forget(appID string) {
+	r.mu.Lock()
+	delete(r.local, appID)
+	r.mu.Unlock()
+}
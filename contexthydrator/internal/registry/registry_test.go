@@ -0,0 +1,59 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourorg/context-hydrator/internal/cache"
+)
+
+func TestRegister_Concurrent(t *testing.T) {
+	r := New(cache.NewStore(cache.NewMemoryBackend()), time.Minute)
+
+	var registered, conflicts atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			err := r.Register(context.Background(), validRegistration())
+			switch {
+			case err == nil:
+				registered.Add(1)
+			case errors.Is(err, ErrAppExists):
+				conflicts.Add(1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if registered.Load() != 1 || conflicts.Load() != 7 {
+		t.Fatalf("registered %d, conflicts %d; want 1 and 7", registered.Load(), conflicts.Load())
+	}
+	if _, err := r.Get(context.Background(), "payments-app"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+}
+
+func TestAppIDs(t *testing.T) {
+	ctx := context.Background()
+	r := New(cache.NewStore(cache.NewMemoryBackend()), time.Minute)
+
+	reg := validRegistration()
+	if err := r.Register(ctx, reg); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := r.AppIDs(ctx); err != nil || len(ids) != 1 || ids[0] != reg.AppID {
+		t.Fatalf("after register: %v, %v", ids, err)
+	}
+	if err := r.Delete(ctx, reg.AppID); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := r.AppIDs(ctx); err != nil || len(ids) != 0 {
+		t.Fatalf("after delete: %v, %v", ids, err)
+	}
+}
@@ -6,10 +6,22 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/yourorg/context-hydrator/internal/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/yourorg/context-hydrator/internal/services")
+
 type BackendConfig struct {
 	ProfileURL     string
 	PreferencesURL string
@@ -18,67 +30,140 @@ type BackendConfig struct {
 }
 
 type Backend struct {
-	cfg    BackendConfig
-	client *http.Client
+	cfg      BackendConfig
+	client   *http.Client
+	breakers *breakers
 }
 
 func NewBackend(cfg BackendConfig, client *http.Client) *Backend {
-	return &Backend{cfg: cfg, client: client}
+	return &Backend{cfg: cfg, client: client, breakers: newBreakers()}
 }
 
-// FetchWithConfig fetches the given resources in parallel using URL templates
-// from appConfig, substituting claims into each template.
-func (b *Backend) FetchWithConfig(ctx context.Context, appConfig *AppConfig, resources []ServiceName, claims map[string]string) []ServiceResult {
-	results := make([]ServiceResult, len(resources))
+// StreamWithConfig fetches the given resources in parallel using URL
+// templates from appConfig, substituting claims into each template. Each
+// result is delivered as soon as its fetch returns; the channel is closed
+// after the last one.
+func (b *Backend) StreamWithConfig(ctx context.Context, appConfig *AppConfig, resources []ServiceName, claims map[string]string) <-chan ServiceResult {
+	results := make(chan ServiceResult, len(resources))
 	var wg sync.WaitGroup
 
-	for i, svcName := range resources {
+	for _, svcName := range resources {
 		resCfg, ok := appConfig.Resources[svcName]
 		if !ok {
-			results[i] = ServiceResult{Service: svcName, Err: fmt.Errorf("no config for resource %s", svcName)}
+			results <- ServiceResult{Service: svcName, Err: fmt.Errorf("no config for resource %s", svcName)}
 			continue
 		}
 
 		wg.Add(1)
-		go func(idx int, name ServiceName, cfg ResourceConfig) {
+		go func(name ServiceName, cfg ResourceConfig) {
 			defer wg.Done()
-			results[idx] = b.fetchWithTemplate(ctx, name, cfg.URLTemplate, claims)
-		}(i, svcName, resCfg)
+			results <- b.fetchWithTemplate(ctx, appConfig.AppID, name, cfg, claims)
+		}(svcName, resCfg)
 	}
 
-	wg.Wait()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 	return results
 }
 
-func (b *Backend) fetchWithTemplate(ctx context.Context, name ServiceName, urlTemplate string, claims map[string]string) ServiceResult {
-	url := resolveTemplate(urlTemplate, claims)
+func (b *Backend) fetchWithTemplate(ctx context.Context, appID string, name ServiceName, cfg ResourceConfig, claims map[string]string) (result ServiceResult) {
+	ctx, span := tracer.Start(ctx, "upstream.fetch",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("resource", string(name))))
+	start := time.Now()
+	defer func() {
+		result.Elapsed = time.Since(start)
+		if result.Err != nil {
+			span.RecordError(result.Err)
+			span.SetStatus(codes.Error, "fetch failed")
+		}
+		span.End()
+	}()
+
+	url := resolveTemplate(cfg.URLTemplate, claims)
+	maxAttempts := max(cfg.Retry.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		span.SetAttributes(attribute.Int("attempts", attempt))
+		res, retryable := b.attempt(ctx, appID, name, url, cfg)
+		if res.Err == nil || !retryable || attempt >= maxAttempts {
+			return res
+		}
+
+		wait := cfg.Retry.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return res
+		}
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.String("error", res.Err.Error()),
+			attribute.Int64("backoff_ms", wait.Milliseconds())))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return res
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt makes a single upstream call through the host's circuit breaker.
+// retryable reports whether the failure may succeed on another attempt.
+func (b *Backend) attempt(ctx context.Context, appID string, name ServiceName, url string, cfg ResourceConfig) (result ServiceResult, retryable bool) {
+	span := trace.SpanFromContext(ctx)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return ServiceResult{Service: name, Err: fmt.Errorf("build request: %w", err)}
+		return ServiceResult{Service: name, Err: fmt.Errorf("build request: %w", err)}, false
 	}
 	req.Header.Set("Accept", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	span.SetAttributes(semconv.ServerAddress(req.URL.Hostname()))
+
+	host := req.URL.Host
+	if !b.breakers.allow(host, cfg.Breaker) {
+		return ServiceResult{Service: name, Err: fmt.Errorf("upstream %s (%s): %w", name, host, ErrCircuitOpen)}, false
+	}
 
+	start := time.Now()
 	resp, err := b.client.Do(req)
 	if err != nil {
-		return ServiceResult{Service: name, Err: fmt.Errorf("http get: %w", err)}
+		metrics.ObserveUpstream(appID, string(name), 0, time.Since(start))
+		if ctx.Err() != nil {
+			b.breakers.record(host, cfg.Breaker, callIgnored)
+			return ServiceResult{Service: name, Err: fmt.Errorf("http get: %w", err)}, false
+		}
+		b.breakers.record(host, cfg.Breaker, callFailed)
+		return ServiceResult{Service: name, Err: fmt.Errorf("http get: %w", err)}, true
 	}
 	defer resp.Body.Close()
+	metrics.ObserveUpstream(appID, string(name), resp.StatusCode, time.Since(start))
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		b.breakers.record(host, cfg.Breaker, callFailed)
+	} else {
+		b.breakers.record(host, cfg.Breaker, callSucceeded)
+	}
 
 	if resp.StatusCode != http.StatusOK {
-		return ServiceResult{Service: name, Err: fmt.Errorf("upstream %s: status %d", name, resp.StatusCode)}
+		err := fmt.Errorf("upstream %s: status %d", name, resp.StatusCode)
+		return ServiceResult{Service: name, Err: err}, slices.Contains(cfg.Retry.RetryableStatus, resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return ServiceResult{Service: name, Err: fmt.Errorf("read body: %w", err)}
+		return ServiceResult{Service: name, Err: fmt.Errorf("read body: %w", err)}, ctx.Err() == nil
 	}
 
 	if !json.Valid(body) {
-		return ServiceResult{Service: name, Err: fmt.Errorf("invalid JSON from %s", name)}
+		return ServiceResult{Service: name, Err: fmt.Errorf("invalid JSON from %s", name)}, false
 	}
 
-	return ServiceResult{Service: name, Data: json.RawMessage(body)}
+	return ServiceResult{Service: name, Data: json.RawMessage(body)}, false
 }
 
 // resolveTemplate substitutes {claim} placeholders in a URL template.
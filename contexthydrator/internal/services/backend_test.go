@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyUpstream fails the first n requests with status, then serves JSON.
+func flakyUpstream(t *testing.T, n int32, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= n {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func fetchOnce(b *Backend, url string, res ResourceConfig) ServiceResult {
+	res.URLTemplate = url
+	app := &AppConfig{AppID: "test-app", Resources: map[ServiceName]ResourceConfig{"profile": res}}
+	return <-b.StreamWithConfig(context.Background(), app, []ServiceName{"profile"}, nil)
+}
+
+func TestFetch_RetriesRetryableStatus(t *testing.T) {
+	srv, calls := flakyUpstream(t, 2, http.StatusServiceUnavailable)
+	b := NewBackend(BackendConfig{}, srv.Client())
+
+	res := fetchOnce(b, srv.URL, ResourceConfig{
+		Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, RetryableStatus: []int{503}},
+	})
+	if res.Err != nil {
+		t.Fatalf("unexpected error: %v", res.Err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("calls: got %d, want 3", got)
+	}
+}
+
+func TestFetch_DoesNotRetryOtherStatus(t *testing.T) {
+	srv, calls := flakyUpstream(t, 1, http.StatusNotFound)
+	b := NewBackend(BackendConfig{}, srv.Client())
+
+	res := fetchOnce(b, srv.URL, ResourceConfig{
+		Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, RetryableStatus: []int{503}},
+	})
+	if res.Err == nil {
+		t.Fatal("expected error for 404")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("calls: got %d, want 1", got)
+	}
+}
+
+func TestFetch_BreakerOpensAndProbes(t *testing.T) {
+	srv, calls := flakyUpstream(t, 2, http.StatusInternalServerError)
+	b := NewBackend(BackendConfig{}, srv.Client())
+	now := time.Now()
+	b.breakers.now = func() time.Time { return now }
+	res := ResourceConfig{Breaker: BreakerConfig{FailureThreshold: 2, OpenDuration: time.Minute}}
+
+	fetchOnce(b, srv.URL, res)
+	fetchOnce(b, srv.URL, res)
+
+	r := fetchOnce(b, srv.URL, res)
+	if !errors.Is(r.Err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", r.Err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("calls while open: got %d, want 2", got)
+	}
+
+	// After the open period a probe goes through and, succeeding, closes it.
+	now = now.Add(time.Minute)
+	if r := fetchOnce(b, srv.URL, res); r.Err != nil {
+		t.Fatalf("probe: unexpected error: %v", r.Err)
+	}
+	if r := fetchOnce(b, srv.URL, res); r.Err != nil {
+		t.Fatalf("after probe: unexpected error: %v", r.Err)
+	}
+}
+
+func TestBreakers_FailedProbeReopens(t *testing.T) {
+	b := newBreakers()
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	cfg := BreakerConfig{FailureThreshold: 1, OpenDuration: time.Second}
+
+	b.record("svc", cfg, callFailed)
+	if b.allow("svc", cfg) {
+		t.Fatal("breaker should be open")
+	}
+
+	now = now.Add(time.Second)
+	if !b.allow("svc", cfg) {
+		t.Fatal("probe should be allowed")
+	}
+	if b.allow("svc", cfg) {
+		t.Error("only one probe should be allowed at a time")
+	}
+	b.record("svc", cfg, callFailed)
+	if b.allow("svc", cfg) {
+		t.Error("failed probe should reopen the breaker")
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 5: 300 * time.Millisecond} {
+		for range 20 {
+			d := p.backoff(attempt)
+			if d < want/2 || d > want {
+				t.Fatalf("attempt %d: backoff %s outside [%s, %s]", attempt, d, want/2, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/yourorg/context-hydrator/internal/metrics"
+)
+
+// ErrCircuitOpen is returned for calls skipped because the upstream host's
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// breakers holds one circuit breaker per upstream host. Thresholds come from
+// the calling resource's BreakerConfig, so resources sharing a host share
+// its failure count.
+type breakers struct {
+	mu    sync.Mutex
+	hosts map[string]*breaker
+	now   func() time.Time
+}
+
+type breaker struct {
+	failures  int
+	openUntil time.Time
+	probing   bool // a half-open probe call is in flight
+}
+
+// callResult is what a finished call tells the breaker.
+type callResult int
+
+const (
+	callSucceeded callResult = iota
+	callFailed
+	callIgnored // e.g. cancelled by the caller; says nothing about the host
+)
+
+func newBreakers() *breakers {
+	return &breakers{hosts: make(map[string]*breaker), now: time.Now}
+}
+
+// allow reports whether a call to host may proceed. Once the open period
+// has elapsed, a single probe call is allowed through.
+func (b *breakers) allow(host string, cfg BreakerConfig) bool {
+	if cfg.FailureThreshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	br := b.hosts[host]
+	if br == nil || br.openUntil.IsZero() {
+		return true
+	}
+	if b.now().Before(br.openUntil) || br.probing {
+		return false
+	}
+	br.probing = true
+	return true
+}
+
+// record updates host's breaker with the result of an allowed call.
+func (b *breakers) record(host string, cfg BreakerConfig, res callResult) {
+	if cfg.FailureThreshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	br := b.hosts[host]
+	if br == nil {
+		br = &breaker{}
+		b.hosts[host] = br
+	}
+	wasProbe := br.probing
+	br.probing = false
+
+	switch res {
+	case callSucceeded:
+		br.failures = 0
+		br.openUntil = time.Time{}
+	case callFailed:
+		br.failures++
+		if wasProbe || br.failures >= cfg.FailureThreshold {
+			br.openUntil = b.now().Add(cfg.OpenDuration)
+		}
+	}
+	metrics.UpstreamCircuitOpen.WithLabelValues(host).Set(boolGauge(!br.openUntil.IsZero()))
+}
+
+func boolGauge(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
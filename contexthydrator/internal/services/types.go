@@ -2,6 +2,8 @@ package services
 
 import (
 	"encoding/json"
+	"math/rand/v2"
+	"slices"
 	"time"
 )
 
@@ -14,30 +16,169 @@ const (
 	ServiceResources   ServiceName = "resources"
 )
 
-var AllServices = []ServiceName{
-	ServiceProfile,
-	ServicePreferences,
-	ServicePermissions,
-	ServiceResources,
-}
-
 type ServiceResult struct {
 	Service ServiceName
 	Data    json.RawMessage
 	Err     error
+	// Elapsed is the time spent fetching, including retries.
+	Elapsed time.Duration
 }
 
 // ResourceConfig defines how to fetch and cache a single resource.
 type ResourceConfig struct {
-	URLTemplate string        // e.g. "http://svc/users/{user_id}/profile"
+	URLTemplate string // e.g. "http://svc/users/{user_id}/profile"
 	TTL         time.Duration
+
+	// Retry and Breaker guard the upstream call. Their zero values make a
+	// single attempt with no circuit breaker.
+	Retry   RetryPolicy
+	Breaker BreakerConfig
+
+	// ReadThrough makes a reader cache miss fetch the resource from the
+	// backend and cache it instead of returning a miss. Off by default: it
+	// lets callers of the reader trigger upstream calls, so the reader only
+	// honours it behind session authentication.
+	ReadThrough bool
+}
+
+// RetryPolicy retries failed upstream calls with exponential backoff and
+// jitter. Transport errors are always retryable; responses only if their
+// status code is listed. Retries stop early if the next backoff would pass
+// the hydration deadline.
+type RetryPolicy struct {
+	MaxAttempts     int           // total attempts including the first; 0 or 1 disables retries
+	InitialBackoff  time.Duration // wait before the second attempt, doubled for each further one
+	MaxBackoff      time.Duration // cap on a single wait
+	RetryableStatus []int         // e.g. 502, 503, 504
+}
+
+// backoff returns the wait before the attempt following the given one:
+// InitialBackoff doubled per attempt, capped at MaxBackoff, with the upper
+// half randomized so that callers retrying together spread out.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// BreakerConfig configures the circuit breaker of the resource's upstream
+// host. The breaker opens after FailureThreshold consecutive failures
+// (transport errors and 5xx) and skips calls for OpenDuration, then lets one
+// probe call through: success closes it, failure reopens it.
+type BreakerConfig struct {
+	FailureThreshold int // 0 disables the breaker
+	OpenDuration     time.Duration
 }
 
 // AppConfig holds per-app hydration configuration.
+// Resources is the authoritative set of valid resource names for the app.
 type AppConfig struct {
 	AppID     string
 	Resources map[ServiceName]ResourceConfig
 	Secret    []byte
+
+	// ContextKeyClaims are the claim names whose values, joined with ":",
+	// compose the app's context keys (e.g. ["user_id", "profile_id"]).
+	ContextKeyClaims []string
+
+	// RevalidateFraction triggers a background refresh on read when an entry's
+	// remaining TTL drops below this fraction of ResourceConfig.TTL. 0 disables.
+	RevalidateFraction float64
+
+	// Events controls where hydration-complete events are published.
+	Events EventsConfig
+
+	// RateLimit caps POST /hydrate requests for the app.
+	RateLimit RateLimitConfig
+
+	// AccessPatterns controls which resources are learned from reads.
+	AccessPatterns AccessPatternConfig
+}
+
+// AccessPatternConfig decides which resources a contextKey's hydrations
+// fetch once enough of its reads have been seen. Until then, and for apps
+// with learning off, hydrations fetch every resource.
+type AccessPatternConfig struct {
+	// MinReads is the (decayed) number of reads needed before a pattern is
+	// written.
+	MinReads float64
+	// MinConfidence is the share of reads a resource must appear in to be
+	// hydrated, e.g. 0.05.
+	MinConfidence float64
+	// AlwaysInclude resources are hydrated whatever the reads say.
+	AlwaysInclude []ServiceName
+}
+
+// EventsConfig selects the hydration-complete event sinks for an app.
+type EventsConfig struct {
+	Stream       bool  // XADD to hyd:stream:{appID} (persistent, replayable)
+	StreamMaxLen int64 // approximate MAXLEN trim for the stream
+	PubSub       bool  // PUBLISH to hyd:events:{appID} (fire-and-forget)
+}
+
+// RateLimitConfig holds per-minute request limits for POST /hydrate.
+// A zero limit is not enforced.
+type RateLimitConfig struct {
+	PerToken int // per hydration token (or user_id in base64json mode)
+	PerIP    int // per source IP
+	PerApp   int // across all callers of the app
+}
+
+// Defaults for registered apps that do not configure rate limits.
+const (
+	DefaultRateLimitPerToken = 10
+	DefaultRateLimitPerIP    = 100
+)
+
+// Defaults for registered apps that do not configure retries or a breaker.
+var (
+	DefaultRetryPolicy = RetryPolicy{
+		MaxAttempts:     2,
+		InitialBackoff:  50 * time.Millisecond,
+		MaxBackoff:      500 * time.Millisecond,
+		RetryableStatus: []int{502, 503, 504},
+	}
+	DefaultBreakerConfig = BreakerConfig{
+		FailureThreshold: 5,
+		OpenDuration:     30 * time.Second,
+	}
+)
+
+// Defaults for registered apps that do not configure access patterns.
+const (
+	DefaultAccessPatternMinReads      = 10
+	DefaultAccessPatternMinConfidence = 0.05
+)
+
+// DefaultEventStreamMaxLen bounds an app's event stream when not configured.
+const DefaultEventStreamMaxLen = 10000
+
+// DefaultRevalidateFraction refreshes entries in their last 20% of TTL.
+const DefaultRevalidateFraction = 0.2
+
+// ResourceNames returns the app's configured resource names in sorted order.
+func (c *AppConfig) ResourceNames() []ServiceName {
+	names := make([]ServiceName, 0, len(c.Resources))
+	for name := range c.Resources {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// HasResource reports whether name is a configured resource for the app.
+func (c *AppConfig) HasResource(name string) bool {
+	_, ok := c.Resources[ServiceName(name)]
+	return ok
 }
 
 // HydrationMapping maps an opaque hyd_token to a context key and claims.
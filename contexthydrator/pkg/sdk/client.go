@@ -0,0 +1,448 @@
+// Package sdk is the Go client for app teams integrating with the context
+// hydrator. It issues hydration tokens at login, sets the persistent hydration
+// cookie, triggers POST /hydrate and reads hydrated context from the reader.
+//
+//	client, err := sdk.NewClient(sdk.Config{
+//		AppID:            "payments-app",
+//		Secret:           secretFromAWS,
+//		ContextKeyClaims: []string{"user_id", "account_id"},
+//		Redis:            redisClient,
+//		HydrationURL:     "https://hydrator.platform.internal",
+//		ReaderURL:        "https://reader.platform.internal",
+//	})
+//
+//	// at login
+//	token, err := client.IssueToken(ctx, sdk.Claims{"user_id": userID, "account_id": accountID})
+//	client.SetCookie(w, token)
+//	err = client.Hydrate(ctx, token.JWT)
+//
+//	// post-auth, forwarding the user's session token
+//	ctx = sdk.WithSessionToken(ctx, sessionToken)
+//	profile, err := sdk.Get[Profile](ctx, client, token.ContextKey, "profile") // nil on cache miss
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/keyring"
+	redisc "github.com/yourorg/context-hydrator/internal/redis"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+// CookieName is the persistent hydration cookie read by POST /hydrate.
+const CookieName = "hyd"
+
+// Claims are the identity values a context key is built from, e.g.
+// {"user_id": "u1", "account_id": "acc-99"}. They are also substituted into
+// the app's resource URL templates.
+type Claims map[string]string
+
+// Config configures a Client.
+type Config struct {
+	AppID string
+	// Secret signs hydration tokens with HS256 and derives their hyd_token;
+	// it must match one of the hydration service's cookie keys for the app.
+	Secret []byte
+	// KeyID is set as the JWT's kid header, selecting the verification key
+	// while keys are being rotated.
+	KeyID string
+	// SigningKey, if set, signs hydration tokens instead of Secret: RS256
+	// for RSA keys, ES256 for P-256 ECDSA keys and EdDSA for Ed25519 keys.
+	// The hydration service verifies them with the app's JWKS.
+	SigningKey crypto.Signer
+	// Issuer and Audience are set as the iss and aud claims if non-empty.
+	Issuer   string
+	Audience string
+	// ContextKeyClaims lists, in order, the claims joined with ":" to form
+	// the context key. Defaults to ["user_id"].
+	ContextKeyClaims []string
+	// TokenTTL is the hydration cookie and mapping lifetime. Defaults to 30 days.
+	TokenTTL time.Duration
+
+	// Redis stores the hyd_token → context key mapping read by the
+	// hydration service: a *redis.Client, or a Sentinel or Cluster client.
+	// Required by IssueToken.
+	Redis redis.UniversalClient
+	// EncryptionKeysFile is the hydration service's ENCRYPTION_KEYS_FILE.
+	// If set, mappings are encrypted with the app's key, as the service
+	// encrypts cached resources. EncryptionKeysRefresh is how often it is
+	// re-read; defaults to 1 minute.
+	EncryptionKeysFile    string
+	EncryptionKeysRefresh time.Duration
+
+	// Base URLs of the hydration and context reader services.
+	HydrationURL string
+	ReaderURL    string
+	// HTTPClient is used for service calls. Defaults to a client with a 5s timeout.
+	HTTPClient *http.Client
+
+	// CookieDomain is set on the hydration cookie if non-empty.
+	CookieDomain string
+}
+
+// Client is safe for concurrent use.
+type Client struct {
+	cfg   Config
+	store *cache.Store
+}
+
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.AppID == "" {
+		return nil, errors.New("sdk: AppID is required")
+	}
+	if len(cfg.Secret) == 0 {
+		return nil, errors.New("sdk: Secret is required")
+	}
+	if cfg.SigningKey != nil {
+		if _, err := signingMethod(cfg.SigningKey); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.ContextKeyClaims) == 0 {
+		cfg.ContextKeyClaims = []string{"user_id"}
+	}
+	if cfg.TokenTTL == 0 {
+		cfg.TokenTTL = redisc.TTLMapping
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 5 * time.Second}
+	}
+	cfg.HydrationURL = strings.TrimRight(cfg.HydrationURL, "/")
+	cfg.ReaderURL = strings.TrimRight(cfg.ReaderURL, "/")
+
+	var opts []cache.Option
+	if cfg.EncryptionKeysFile != "" {
+		if cfg.EncryptionKeysRefresh == 0 {
+			cfg.EncryptionKeysRefresh = time.Minute
+		}
+		keys := keyring.NewFileProvider(cfg.EncryptionKeysFile, cfg.EncryptionKeysRefresh)
+		if err := keys.Load(); err != nil {
+			return nil, fmt.Errorf("sdk: load encryption keys: %w", err)
+		}
+		opts = append(opts, cache.WithEncryption(keys))
+	}
+
+	c := &Client{cfg: cfg}
+	if cfg.Redis != nil {
+		c.store = cache.NewStore(cache.NewRedisBackend(cfg.Redis), opts...)
+	}
+	return c, nil
+}
+
+// Token is an issued hydration token.
+type Token struct {
+	// ContextKey identifies the user's hydrated context, e.g. "u1:acc-99".
+	ContextKey string
+	// HydToken is the opaque HMAC of the context key carried in the JWT.
+	HydToken string
+	// JWT is the signed cookie value.
+	JWT       string
+	ExpiresAt time.Time
+}
+
+// ContextKey joins the configured context key claims with ":".
+func (c *Client) ContextKey(claims Claims) (string, error) {
+	parts := make([]string, 0, len(c.cfg.ContextKeyClaims))
+	for _, name := range c.cfg.ContextKeyClaims {
+		v := claims[name]
+		if v == "" {
+			return "", fmt.Errorf("sdk: missing claim %q", name)
+		}
+		parts = append(parts, v)
+	}
+	return strings.Join(parts, ":"), nil
+}
+
+// IssueToken derives the hyd_token for claims, stores the hyd_token →
+// {contextKey, claims} mapping in Redis and signs the hydration JWT.
+// Call it at login.
+func (c *Client) IssueToken(ctx context.Context, claims Claims) (*Token, error) {
+	if c.store == nil {
+		return nil, errors.New("sdk: Redis is required to issue tokens")
+	}
+	contextKey, err := c.ContextKey(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, c.cfg.Secret)
+	mac.Write([]byte(contextKey))
+	hydToken := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	mapping := &services.HydrationMapping{ContextKey: contextKey, Claims: claims}
+	if err := c.store.StoreMapping(ctx, c.cfg.AppID, hydToken, mapping); err != nil {
+		return nil, fmt.Errorf("sdk: store mapping: %w", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(c.cfg.TokenTTL)
+	jwtClaims := jwt.MapClaims{
+		"hyd_token": hydToken,
+		"app_id":    c.cfg.AppID,
+		"iat":       now.Unix(),
+		"exp":       expiresAt.Unix(),
+	}
+	if c.cfg.Issuer != "" {
+		jwtClaims["iss"] = c.cfg.Issuer
+	}
+	if c.cfg.Audience != "" {
+		jwtClaims["aud"] = c.cfg.Audience
+	}
+	signed, err := c.sign(jwtClaims)
+	if err != nil {
+		return nil, fmt.Errorf("sdk: sign token: %w", err)
+	}
+
+	return &Token{ContextKey: contextKey, HydToken: hydToken, JWT: signed, ExpiresAt: expiresAt}, nil
+}
+
+// sign signs claims with SigningKey, or with Secret when it is unset.
+func (c *Client) sign(claims jwt.MapClaims) (string, error) {
+	method, key := jwt.SigningMethod(jwt.SigningMethodHS256), any(c.cfg.Secret)
+	if c.cfg.SigningKey != nil {
+		method, _ = signingMethod(c.cfg.SigningKey)
+		key = c.cfg.SigningKey
+	}
+	token := jwt.NewWithClaims(method, claims)
+	if c.cfg.KeyID != "" {
+		token.Header["kid"] = c.cfg.KeyID
+	}
+	return token.SignedString(key)
+}
+
+// signingMethod returns the JWT algorithm for key's type.
+func signingMethod(key crypto.Signer) (jwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve.Params().Name != "P-256" {
+			return nil, fmt.Errorf("sdk: SigningKey: unsupported curve %s, want P-256", k.Curve.Params().Name)
+		}
+		return jwt.SigningMethodES256, nil
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("sdk: SigningKey: unsupported key type %T", key)
+}
+
+// SetCookie sets the persistent hydration cookie: HttpOnly, Secure,
+// SameSite=Strict and scoped to Path=/hydrate so it is only ever sent to the
+// hydration service.
+func (c *Client) SetCookie(w http.ResponseWriter, token *Token) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token.JWT,
+		Path:     "/hydrate",
+		Domain:   c.cfg.CookieDomain,
+		Expires:  token.ExpiresAt,
+		MaxAge:   int(time.Until(token.ExpiresAt).Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// Hydrate triggers POST /hydrate for a hydration JWT. It returns once the
+// request is accepted; hydration completes in the background.
+func (c *Client) Hydrate(ctx context.Context, jwt string) error {
+	resp, err := c.hydrate(ctx, jwt, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return statusError("hydrate", resp)
+	}
+	return nil
+}
+
+// HydrateWait triggers POST /hydrate?wait=true and returns the hydrated
+// context once hydration ends or timeout passes. Resources still being
+// fetched have Meta source "pending" and are cached in the background.
+// Like reads, it sends the session token set with WithSessionToken.
+func (c *Client) HydrateWait(ctx context.Context, jwt string, timeout time.Duration) (*Context, error) {
+	resp, err := c.hydrate(ctx, jwt, "?wait=true&timeout="+url.QueryEscape(timeout.String()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("hydrate", resp)
+	}
+
+	var out Context
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("sdk: decode context: %w", err)
+	}
+	return &out, nil
+}
+
+func (c *Client) hydrate(ctx context.Context, jwt, query string) (*http.Response, error) {
+	body, _ := json.Marshal(map[string]string{"cookie": jwt})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.HydrationURL+"/hydrate"+query, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("sdk: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-App-ID", c.cfg.AppID)
+	if token, ok := ctx.Value(sessionTokenKey{}).(string); ok && token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sdk: hydrate: %w", err)
+	}
+	return resp, nil
+}
+
+// GetData reads one cached resource. It returns nil, nil on a cache miss —
+// the caller decides whether to trigger Hydrate or fall back to the backend.
+func (c *Client) GetData(ctx context.Context, contextKey, resource string) (json.RawMessage, error) {
+	path := "/data/" + url.PathEscape(contextKey) + "/" + url.PathEscape(resource)
+	resp, err := c.read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("sdk: read %s: %w", resource, err)
+		}
+		return json.RawMessage(b), nil
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, statusError("read "+resource, resp)
+	}
+}
+
+// Get reads one cached resource into a T. It returns nil, nil on a cache miss.
+func Get[T any](ctx context.Context, c *Client, contextKey, resource string) (*T, error) {
+	raw, err := c.GetData(ctx, contextKey, resource)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	var v T
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, fmt.Errorf("sdk: decode %s: %w", resource, err)
+	}
+	return &v, nil
+}
+
+// ResourceMeta reports where a resource in a Context came from.
+type ResourceMeta struct {
+	Source string `json:"source"` // "cache" | "cache-stale" | "origin" | "pending" | "unavailable"
+	Error  string `json:"error,omitempty"`
+	// TTLMillis is how long the resource stays cached, in milliseconds; 0
+	// if unknown.
+	TTLMillis int64 `json:"ttl_ms,omitempty"`
+}
+
+// Context is the response of GET /context/{contextKey} and of
+// POST /hydrate?wait=true.
+type Context struct {
+	ContextKey string                     `json:"context_key"`
+	Data       map[string]json.RawMessage `json:"data"`
+	Meta       map[string]ResourceMeta    `json:"meta"`
+}
+
+// GetContext reads several resources in one call. No resources means all of
+// the app's resources. Missing resources are absent from Data and reported in Meta.
+func (c *Client) GetContext(ctx context.Context, contextKey string, resources ...string) (*Context, error) {
+	path := "/context/" + url.PathEscape(contextKey)
+	if len(resources) > 0 {
+		path += "?resources=" + url.QueryEscape(strings.Join(resources, ","))
+	}
+	resp, err := c.read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("read context", resp)
+	}
+
+	var out Context
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("sdk: decode context: %w", err)
+	}
+	return &out, nil
+}
+
+// Decode unmarshals one resource of a Context into a T. It returns nil, nil
+// if the resource is not present.
+func Decode[T any](c *Context, resource string) (*T, error) {
+	raw, ok := c.Data[resource]
+	if !ok {
+		return nil, nil
+	}
+	var v T
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, fmt.Errorf("sdk: decode %s: %w", resource, err)
+	}
+	return &v, nil
+}
+
+func (c *Client) read(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.ReaderURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sdk: %w", err)
+	}
+	req.Header.Set("X-App-ID", c.cfg.AppID)
+	if token, ok := ctx.Value(sessionTokenKey{}).(string); ok && token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sdk: read: %w", err)
+	}
+	return resp, nil
+}
+
+type sessionTokenKey struct{}
+
+// WithSessionToken returns a copy of ctx whose reads and waited hydrations
+// are authenticated with the user's session token.
+func WithSessionToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, sessionTokenKey{}, token)
+}
+
+// StatusError is returned when a service responds with an unexpected status.
+type StatusError struct {
+	Op         string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("sdk: %s: status %d: %s", e.Op, e.StatusCode, e.Body)
+}
+
+func statusError(op string, resp *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &StatusError{Op: op, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(b))}
+}
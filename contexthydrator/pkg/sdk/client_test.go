@@ -0,0 +1,243 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/yourorg/context-hydrator/internal/api"
+	"github.com/yourorg/context-hydrator/internal/cache"
+	"github.com/yourorg/context-hydrator/internal/cookie"
+	"github.com/yourorg/context-hydrator/internal/hydrator"
+	"github.com/yourorg/context-hydrator/internal/keyring"
+	"github.com/yourorg/context-hydrator/internal/observability"
+	"github.com/yourorg/context-hydrator/internal/registry"
+	"github.com/yourorg/context-hydrator/internal/services"
+)
+
+const testSecret = "app-secret"
+
+type profile struct {
+	Name string `json:"name"`
+}
+
+// newTestClient starts the combined server in JWT cookie mode against a fake
+// upstream and returns an SDK client pointed at it.
+func newTestClient(t *testing.T) (*Client, *miniredis.Miniredis) {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":"user ` + strings.TrimPrefix(r.URL.Path, "/users/") + `"}`))
+	}))
+	t.Cleanup(upstream.Close)
+
+	// The service and the SDK share the key file, as in production.
+	keyFile := filepath.Join(t.TempDir(), "keys.json")
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, keyring.KeySize))
+	if err := os.WriteFile(keyFile, []byte(`{"payments-app": {"current": 1, "keys": {"1": "`+key+`"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys := keyring.NewFileProvider(keyFile, time.Minute)
+	if err := keys.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := cache.NewStore(cache.NewRedisBackend(rdb), cache.WithEncryption(keys))
+	log := observability.NewLogger("error", "text")
+	app := &services.AppConfig{
+		AppID: "payments-app",
+		Resources: map[services.ServiceName]services.ResourceConfig{
+			services.ServiceProfile: {URLTemplate: upstream.URL + "/users/{user_id}", TTL: time.Hour},
+		},
+		ContextKeyClaims: []string{"user_id", "account_id"},
+	}
+	hyd := hydrator.New(store, services.NewBackend(services.BackendConfig{}, upstream.Client()), log, time.Second)
+	srv := api.NewServer(store, hyd, cookie.NewDecoder("jwt", testSecret), registry.New(store, time.Minute, app), log)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+
+	client, err := NewClient(Config{
+		AppID:              "payments-app",
+		Secret:             []byte(testSecret),
+		ContextKeyClaims:   []string{"user_id", "account_id"},
+		Redis:              rdb,
+		EncryptionKeysFile: keyFile,
+		HydrationURL:       ts.URL,
+		ReaderURL:          ts.URL,
+		HTTPClient:         ts.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, mr
+}
+
+func TestIssueHydrateAndRead(t *testing.T) {
+	client, mr := newTestClient(t)
+	ctx := context.Background()
+
+	token, err := client.IssueToken(ctx, Claims{"user_id": "u1", "account_id": "acc-99"})
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if token.ContextKey != "u1:acc-99" {
+		t.Errorf("context key: got %q", token.ContextKey)
+	}
+	if raw, err := mr.Get(cache.MappingKey("payments-app", token.HydToken)); err != nil {
+		t.Fatal("mapping not stored")
+	} else if strings.Contains(raw, "acc-99") {
+		t.Errorf("mapping stored in plaintext: %q", raw)
+	}
+
+	// Nothing hydrated yet: a miss is nil, not an error.
+	if p, err := Get[profile](ctx, client, token.ContextKey, "profile"); err != nil || p != nil {
+		t.Fatalf("before hydrate: got %v, %v", p, err)
+	}
+
+	if err := client.Hydrate(ctx, token.JWT); err != nil {
+		t.Fatalf("hydrate: %v", err)
+	}
+
+	var p *profile
+	for deadline := time.Now().Add(2 * time.Second); p == nil && time.Now().Before(deadline); {
+		if p, err = Get[profile](ctx, client, token.ContextKey, "profile"); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if p == nil || p.Name != "user u1" {
+		t.Fatalf("profile: got %+v", p)
+	}
+
+	c, err := client.GetContext(ctx, token.ContextKey, "profile")
+	if err != nil {
+		t.Fatalf("context: %v", err)
+	}
+	if c.Meta["profile"].Source != "cache" {
+		t.Errorf("meta: got %+v", c.Meta["profile"])
+	}
+	if p, err := Decode[profile](c, "profile"); err != nil || p.Name != "user u1" {
+		t.Errorf("decode: got %+v, %v", p, err)
+	}
+}
+
+func TestHydrateWait(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	token, err := client.IssueToken(ctx, Claims{"user_id": "u2", "account_id": "acc-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := client.HydrateWait(ctx, token.JWT, time.Second)
+	if err != nil {
+		t.Fatalf("hydrate: %v", err)
+	}
+	if c.ContextKey != "u2:acc-1" || c.Meta["profile"].Source != "cache" {
+		t.Fatalf("context: got %+v", c)
+	}
+	if p, err := Decode[profile](c, "profile"); err != nil || p.Name != "user u2" {
+		t.Errorf("decode: got %+v, %v", p, err)
+	}
+}
+
+func TestIssueToken_MissingClaim(t *testing.T) {
+	client, _ := newTestClient(t)
+	if _, err := client.IssueToken(context.Background(), Claims{"user_id": "u1"}); err == nil {
+		t.Fatal("expected an error for a missing account_id claim")
+	}
+}
+
+func TestHydrate_Rejected(t *testing.T) {
+	client, _ := newTestClient(t)
+	other, _ := NewClient(Config{AppID: "payments-app", Secret: []byte("wrong-secret"), Redis: client.cfg.Redis,
+		ContextKeyClaims: []string{"user_id", "account_id"}})
+	token, err := other.IssueToken(context.Background(), Claims{"user_id": "u1", "account_id": "acc-99"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Hydrate(context.Background(), token.JWT)
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a 400 StatusError, got %v", err)
+	}
+}
+
+func TestIssueToken_SigningKey(t *testing.T) {
+	client, _ := newTestClient(t)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer, err := NewClient(Config{AppID: "payments-app", Secret: []byte(testSecret), Redis: client.cfg.Redis,
+		SigningKey: key, KeyID: "2026-q4", Issuer: "https://payments.example.com", Audience: "context-hydrator"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := signer.IssueToken(context.Background(), Claims{"user_id": "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := jwt.Parse(token.JWT, func(*jwt.Token) (any, error) { return &key.PublicKey, nil },
+		jwt.WithValidMethods([]string{"ES256"}),
+		jwt.WithIssuer("https://payments.example.com"),
+		jwt.WithAudience("context-hydrator"))
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if kid := parsed.Header["kid"]; kid != "2026-q4" {
+		t.Errorf("kid: got %v", kid)
+	}
+
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if _, err := NewClient(Config{AppID: "payments-app", Secret: []byte(testSecret), SigningKey: p384}); err == nil {
+		t.Error("expected an error for a P-384 signing key")
+	}
+}
+
+func TestSetCookie(t *testing.T) {
+	client, _ := newTestClient(t)
+	token := &Token{JWT: "signed", ExpiresAt: time.Now().Add(time.Hour)}
+
+	w := httptest.NewRecorder()
+	client.SetCookie(w, token)
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected 1 cookie, got %d", len(cookies))
+	}
+	c := cookies[0]
+	if c.Name != CookieName || c.Value != "signed" || c.Path != "/hydrate" {
+		t.Errorf("unexpected cookie: %+v", c)
+	}
+	if !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteStrictMode {
+		t.Errorf("missing security flags: %+v", c)
+	}
+
+	// The hydration service accepts the cookie without a request body.
+	req, _ := http.NewRequest(http.MethodPost, client.cfg.HydrationURL+"/hydrate", nil)
+	issued, _ := client.IssueToken(context.Background(), Claims{"user_id": "u1", "account_id": "acc-99"})
+	req.AddCookie(&http.Cookie{Name: CookieName, Value: issued.JWT})
+	resp, err := client.cfg.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("hydrate with cookie: got %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+}